@@ -6,4 +6,5 @@ require (
 	github.com/gocql/gocql v0.0.0-20210425135552-909f2a77f46e
 	github.com/gorilla/mux v1.8.0
 	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -4,14 +4,50 @@
 
 package main
 
-import "github.com/heeus/reference-crud-app/service"
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/heeus/reference-crud-app/service"
+)
 
 func main() {
-	s := service.Service{}
+	if len(os.Args) > 1 && os.Args[1] == service.MigrateCommand {
+		if err := service.Migrate(os.Args[2:], os.LookupEnv, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	cfg, err := service.LoadConfig(os.Args[1:], os.LookupEnv)
 
-	if err := s.Init(); err != nil {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.Help {
+		service.PrintUsage(os.Stdout)
+		return
+	}
+
+	s, err := service.New(service.ConfigOptions(cfg)...)
+
+	if err != nil {
 		panic(err)
 	}
 
-	s.Start()
+	defer s.Close()
+
+	// Waiting for SIGINT (pkill -2)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := s.Run(ctx); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultAdminPort 0 - administrative routes are not served unless a port is configured
+const DefaultAdminPort = 0
+
+//DefaultAdminHost s.e.
+const DefaultAdminHost = "localhost"
+
+//DefaultAuditFileMaxSize is the size of the audit file in megabytes which makes it rotate
+const DefaultAuditFileMaxSize = 10
+
+//DefaultAuditFileBackups s.e.
+const DefaultAuditFileBackups = 3
+
+// Audited actions
+const (
+	AuditClean    = "clean"
+	AuditLogLevel = "log-level"
+	AuditDenied   = "denied"
+)
+
+//AdminConfig configures the listener of administrative routes
+type AdminConfig struct {
+	//Port 0 - administrative routes are not served by Run; AdminHandler serves them anyway, if they are
+	//protected
+	Port int64
+	Host string
+
+	//Token, when given, is required as "Authorization: Bearer <token>"; administrative routes are built
+	//only with a token or a client CA
+	Token string
+
+	//TLSCert and TLSKey make the listener serve HTTPS; with TLSClientCA clients must present certificates
+	//signed by the CA (mTLS)
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+
+	//AuditFile, when given, gets audit entries as JSON lines; audit entries are logged anyway
+	AuditFile string
+}
+
+//DefaultAdminConfig s.e.
+func DefaultAdminConfig() AdminConfig {
+	return AdminConfig{Port: DefaultAdminPort, Host: DefaultAdminHost}
+}
+
+//AuditEntry is a destructive administrative action or a denied administrative request
+type AuditEntry struct {
+	Time      time.Time
+	RequestID string
+	Remote    string
+	Subject   string
+	Method    string
+	Path      string
+	Action    string
+	Details   string `json:",omitempty"`
+	Status    int
+	Error     string `json:",omitempty"`
+}
+
+//admin holds TLS settings and the audit file of the administrative listener
+type admin struct {
+	cfg AdminConfig
+	tls *tls.Config
+
+	mu    sync.Mutex
+	audit *rotatingFile
+}
+
+func newAdmin(cfg AdminConfig) (*admin, error) {
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("admin port %v is out of range [0, 65535]", cfg.Port)
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, fmt.Errorf("admin TLS needs both certificate and key files")
+	}
+
+	if cfg.TLSClientCA != "" && cfg.TLSCert == "" {
+		return nil, fmt.Errorf("admin client certificates are verified over TLS; give certificate and key files")
+	}
+
+	if cfg.Port > 0 && !cfg.protected() {
+		return nil, fmt.Errorf("admin listener at %q is not protected; give a token or a client CA", cfg.Host)
+	}
+
+	a := &admin{cfg: cfg}
+
+	if cfg.TLSCert != "" {
+		tlsConfig, err := adminTLSConfig(cfg)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.tls = tlsConfig
+	}
+
+	if cfg.AuditFile != "" {
+		f, err := openRotatingFile(cfg.AuditFile, DefaultAuditFileMaxSize<<20, DefaultAuditFileBackups)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.audit = f
+	}
+
+	return a, nil
+}
+
+func adminTLSConfig(cfg AdminConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("admin TLS: %v", err)
+	}
+
+	c := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if cfg.TLSClientCA != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSClientCA)
+
+		if err != nil {
+			return nil, fmt.Errorf("admin client CA: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("admin client CA %v has no PEM certificates", cfg.TLSClientCA)
+		}
+
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return c, nil
+}
+
+//protected tells whether administrative requests are authenticated by a token or a client certificate
+func (cfg AdminConfig) protected() bool {
+	return cfg.Token != "" || cfg.TLSClientCA != ""
+}
+
+func (a *admin) addr() string {
+	return net.JoinHostPort(a.cfg.Host, strconv.FormatInt(a.cfg.Port, 10))
+}
+
+//authenticate checks the token and the client certificate, as configured; the subject is the common
+//name of the client certificate or "token"
+func (a *admin) authenticate(r *http.Request) (subject string, err error) {
+	subject = "anonymous"
+
+	if a.cfg.TLSClientCA != "" {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return subject, fmt.Errorf("client certificate is required")
+		}
+
+		subject = r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+
+	if a.cfg.Token != "" {
+		const prefix = "Bearer "
+
+		h := r.Header.Get("Authorization")
+
+		if !strings.HasPrefix(h, prefix) || subtle.ConstantTimeCompare([]byte(h[len(prefix):]), []byte(a.cfg.Token)) != 1 {
+			return subject, fmt.Errorf("admin token is missing or wrong")
+		}
+
+		if a.cfg.TLSClientCA == "" {
+			subject = "token"
+		}
+	}
+
+	return subject, nil
+}
+
+//write appends the entry to the audit file, if any
+func (a *admin) write(e *AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.audit == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	return a.audit.write(append(b, '\n'))
+}
+
+func (a *admin) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.audit == nil {
+		return nil
+	}
+
+	return a.audit.close()
+}
+
+type adminSubjectKey struct{}
+
+//adminAuth serves authenticated requests only; denied requests are audited
+func (s *Service) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, err := s.admin.authenticate(r)
+
+		if err != nil {
+			s.audit(r, subject, AuditDenied, "", http.StatusUnauthorized, err)
+
+			if s.admin.cfg.Token != "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			}
+
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(contextWithAdminSubject(r, subject)))
+	})
+}
+
+//handleAdminDisabled denies all requests when administrative routes are not protected, so they are
+//not built
+func (s *Service) handleAdminDisabled(w http.ResponseWriter, r *http.Request) {
+	err := fmt.Errorf("administrative routes are disabled: give an admin token or a client CA")
+
+	s.audit(r, "anonymous", AuditDenied, "", http.StatusForbidden, err)
+	http.Error(w, err.Error(), http.StatusForbidden)
+}
+
+//audit logs the action and appends it to the audit file
+func (s *Service) audit(r *http.Request, subject, action, details string, status int, err error) {
+	e := &AuditEntry{
+		Time:      time.Now(),
+		RequestID: requestID(r),
+		Remote:    r.RemoteAddr,
+		Subject:   subject,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Action:    action,
+		Details:   details,
+		Status:    status,
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	logger := s.logger.With("audit", action).With("subject", subject).With("remote", e.Remote)
+
+	if details != "" {
+		logger = logger.With("details", details)
+	}
+
+	if e.Error != "" {
+		logger = logger.With("error", e.Error)
+	}
+
+	logger.Warn("admin %v %v: %v", e.Method, e.Path, status)
+
+	if err := s.admin.write(e); err != nil {
+		s.logger.Error("audit file: %v", err)
+	}
+}
+
+func contextWithAdminSubject(r *http.Request, subject string) context.Context {
+	return context.WithValue(r.Context(), adminSubjectKey{}, subject)
+}
+
+//adminSubject returns the authenticated subject of the administrative request
+func adminSubject(r *http.Request) string {
+	subject, _ := r.Context().Value(adminSubjectKey{}).(string)
+
+	return subject
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newAdmin(t *testing.T) {
+	{
+		_, err := newAdmin(AdminConfig{Port: 8081, Host: "0.0.0.0"})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "not protected")
+
+		_, err = newAdmin(AdminConfig{Port: 8081})
+		assert.NotNil(t, err)
+
+		_, err = newAdmin(AdminConfig{Port: 0, Host: "0.0.0.0"})
+		assert.Nil(t, err)
+
+		a, err := newAdmin(DefaultAdminConfig())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), a.cfg.Port)
+
+		cfg, err := LoadConfig([]string{AdminPortAttribute, "8081"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(8081), cfg.Int(AdminPortParam))
+		assert.Equal(t, int64(0), DefaultConfig().Int(AdminPortParam))
+
+		_, err = newAdmin(AdminConfig{Port: 8081, Host: "0.0.0.0", Token: "t"})
+		assert.Nil(t, err)
+
+		// loopback hosts are reachable by all local processes
+		_, err = newAdmin(AdminConfig{Port: 8081, Host: "::1"})
+		assert.NotNil(t, err)
+	}
+
+	{
+		_, err := newAdmin(AdminConfig{Port: 70000, Host: DefaultAdminHost})
+		assert.NotNil(t, err)
+
+		_, err = newAdmin(AdminConfig{Port: 8081, Host: DefaultAdminHost, TLSCert: "cert.pem"})
+		assert.NotNil(t, err)
+
+		_, err = newAdmin(AdminConfig{Port: 8081, Host: DefaultAdminHost, TLSClientCA: "ca.pem"})
+		assert.NotNil(t, err)
+
+		_, err = newAdmin(AdminConfig{Port: 8081, Host: DefaultAdminHost, TLSCert: "missing.pem", TLSKey: "missing.pem"})
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_adminAuthenticate(t *testing.T) {
+	a := &admin{cfg: AdminConfig{TLSClientCA: "ca.pem", Token: "secret"}}
+
+	r := httptest.NewRequest("GET", "/api/vars", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+
+	_, err := a.authenticate(r)
+	assert.NotNil(t, err)
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ops"}}}}}
+
+	subject, err := a.authenticate(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "ops", subject)
+
+	r.Header.Set("Authorization", "Bearer secret2")
+
+	_, err = a.authenticate(r)
+	assert.NotNil(t, err)
+}
+
+func Test_AdminHandlerNotProtected(t *testing.T) {
+	s, err := New(WithDriver("mem"))
+	assert.Nil(t, err)
+	defer s.Close()
+
+	for _, path := range []string{"/api/driver/clean", "/api/vars", "/healthz"} {
+		rec := serve(s.AdminHandler(), "POST", path, "")
+		assert.Equal(t, 403, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "disabled", path)
+	}
+}
+
+func Test_AdminHandler(t *testing.T) {
+	audit := filepath.Join(t.TempDir(), "audit.json")
+
+	s, err := New(WithDriver("mem"), WithAdmin(AdminConfig{Port: 8081, Host: "0.0.0.0", Token: "secret", AuditFile: audit}))
+	assert.Nil(t, err)
+	defer s.Close()
+
+	{
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/vars", nil))
+		assert.Contains(t, rec.Body.String(), "404")
+
+		r := httptest.NewRequest("GET", "/api/vars", nil)
+		r.Header.Set(RequestIDHeader, "denied-1")
+
+		rec = httptest.NewRecorder()
+		s.AdminHandler().ServeHTTP(rec, r)
+		assert.Equal(t, 401, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	}
+
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		s.AdminHandler().ServeHTTP(rec, r)
+
+		return rec
+	}
+
+	var cleanID string
+
+	{
+		assert.Equal(t, 200, admin("GET", "/api/vars").Code)
+		assert.Equal(t, 405, admin("PUT", "/api/driver/clean").Code)
+
+		rec := admin("POST", "/api/driver/clean")
+		assert.Equal(t, 200, rec.Code)
+		cleanID = rec.Header().Get(RequestIDHeader)
+		assert.NotEmpty(t, cleanID)
+
+		// a safe method does not clean
+		rec = admin("GET", "/api/driver/clean")
+		assert.Equal(t, 405, rec.Code)
+		assert.Equal(t, "POST, DELETE", rec.Header().Get("Allow"))
+		assert.Equal(t, 200, admin("PUT", "/api/log?level=debug").Code)
+	}
+
+	{
+		b, err := ioutil.ReadFile(audit)
+		assert.Nil(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		assert.Equal(t, 3, len(lines))
+
+		var e AuditEntry
+
+		assert.Nil(t, json.Unmarshal([]byte(lines[0]), &e))
+		assert.Equal(t, AuditDenied, e.Action)
+		assert.Equal(t, 401, e.Status)
+		assert.Equal(t, "denied-1", e.RequestID)
+
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &e))
+		assert.Equal(t, AuditClean, e.Action)
+		assert.Equal(t, "token", e.Subject)
+		assert.Equal(t, "POST", e.Method)
+		assert.Equal(t, cleanID, e.RequestID)
+		assert.Equal(t, 200, e.Status)
+
+		assert.Nil(t, json.Unmarshal([]byte(lines[2]), &e))
+		assert.Equal(t, AuditLogLevel, e.Action)
+		assert.Equal(t, "info -> debug", e.Details)
+	}
+}
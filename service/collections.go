@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Collection operations of ViewMod.Ops
+const (
+	//OpAppend appends Values to a list
+	OpAppend = "append"
+	//OpPrepend prepends Values to a list
+	OpPrepend = "prepend"
+	//OpAdd adds Values to a set
+	OpAdd = "add"
+	//OpRemove removes Values from a set or a list
+	OpRemove = "remove"
+	//OpPut puts Entries into a map
+	OpPut = "put"
+	//OpRemoveKeys removes entries of the keys given in Values from a map
+	OpRemoveKeys = "removeKeys"
+)
+
+//CollectionOp mutates a collection field of a record; missing field is an empty collection
+type CollectionOp struct {
+	Op      string
+	Field   string
+	Values  []interface{}          `json:",omitempty"`
+	Entries map[string]interface{} `json:",omitempty"`
+}
+
+func (op *CollectionOp) check() error {
+	if op.Field == "" {
+		return fmt.Errorf("collection operation %v: field is not given", op.Op)
+	}
+
+	switch op.Op {
+	case OpAppend, OpPrepend, OpAdd, OpRemove, OpRemoveKeys:
+		if len(op.Values) == 0 {
+			return fmt.Errorf("collection operation %v of %v: no values given", op.Op, op.Field)
+		}
+	case OpPut:
+		if len(op.Entries) == 0 {
+			return fmt.Errorf("collection operation %v of %v: no entries given", op.Op, op.Field)
+		}
+	default:
+		return fmt.Errorf("collection operation %q of %v is unknown; available: %v, %v, %v, %v, %v, %v", op.Op, op.Field, OpAppend, OpPrepend, OpAdd, OpRemove, OpPut, OpRemoveKeys)
+	}
+
+	return nil
+}
+
+//applyCollectionOps applies operations to values in place; values should be a copy when
+//an error has to leave the record unchanged. Lists and sets are JSON arrays, maps are objects.
+func applyCollectionOps(values map[string]interface{}, ops []CollectionOp) error {
+	for _, op := range ops {
+		if err := op.check(); err != nil {
+			return err
+		}
+
+		if op.Op == OpPut || op.Op == OpRemoveKeys {
+			m, ok := toMap(values[op.Field])
+
+			if !ok {
+				return fmt.Errorf("collection operation %v: field %v is not a map", op.Op, op.Field)
+			}
+
+			for k, v := range op.Entries {
+				m[k] = v
+			}
+
+			for _, k := range op.Values {
+				delete(m, fmt.Sprint(k))
+			}
+
+			values[op.Field] = m
+
+			continue
+		}
+
+		l, ok := toList(values[op.Field])
+
+		if !ok {
+			return fmt.Errorf("collection operation %v: field %v is not a list", op.Op, op.Field)
+		}
+
+		switch op.Op {
+		case OpAppend:
+			l = append(l, op.Values...)
+		case OpPrepend:
+			l = append(append([]interface{}{}, op.Values...), l...)
+		case OpAdd:
+			for _, v := range op.Values {
+				if indexOfElement(l, v) < 0 {
+					l = append(l, v)
+				}
+			}
+		case OpRemove:
+			kept := make([]interface{}, 0, len(l))
+
+			for _, e := range l {
+				if indexOfElement(op.Values, e) < 0 {
+					kept = append(kept, e)
+				}
+			}
+
+			l = kept
+		}
+
+		values[op.Field] = l
+	}
+
+	return nil
+}
+
+//toList copies list value; nil is an empty list
+func toList(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return []interface{}{}, true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	l := make([]interface{}, rv.Len())
+
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+
+	return l, true
+}
+
+//toMap copies map value; nil is an empty map
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	if v == nil {
+		return map[string]interface{}{}, true
+	}
+
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	m := make(map[string]interface{}, rv.Len())
+
+	for _, k := range rv.MapKeys() {
+		m[k.String()] = rv.MapIndex(k).Interface()
+	}
+
+	return m, true
+}
+
+//indexOfElement compares numbers by value, so that 1 and 1.0 are equal elements
+func indexOfElement(l []interface{}, v interface{}) int {
+	vn, isNumber := numberValue(v)
+
+	for i, e := range l {
+		if en, ok := numberValue(e); ok && isNumber {
+			if en == vn {
+				return i
+			}
+		} else if reflect.DeepEqual(e, v) {
+			return i
+		}
+	}
+
+	return -1
+}
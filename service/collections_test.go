@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyCollectionOps(t *testing.T) {
+	{
+		values := map[string]interface{}{"tags": []interface{}{"a", "b"}, "n": 1}
+
+		err := applyCollectionOps(values, []CollectionOp{
+			{Op: OpAppend, Field: "tags", Values: []interface{}{"c"}},
+			{Op: OpPrepend, Field: "tags", Values: []interface{}{"z"}},
+			{Op: OpRemove, Field: "tags", Values: []interface{}{"b"}},
+			{Op: OpAdd, Field: "ids", Values: []interface{}{float64(1), 2, float64(2)}},
+			{Op: OpPut, Field: "attrs", Entries: map[string]interface{}{"x": 1, "y": 2}},
+			{Op: OpRemoveKeys, Field: "attrs", Values: []interface{}{"x"}},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"z", "a", "c"}, values["tags"])
+		assert.Equal(t, []interface{}{float64(1), 2}, values["ids"])
+		assert.Equal(t, map[string]interface{}{"y": 2}, values["attrs"])
+	}
+
+	{
+		values := map[string]interface{}{"n": 1, "m": map[string]interface{}{}}
+
+		assert.NotNil(t, applyCollectionOps(values, []CollectionOp{{Op: OpAppend, Field: "n", Values: []interface{}{1}}}))
+		assert.NotNil(t, applyCollectionOps(values, []CollectionOp{{Op: OpAppend, Field: "m", Values: []interface{}{1}}}))
+		assert.NotNil(t, applyCollectionOps(values, []CollectionOp{{Op: OpPut, Field: "m"}}))
+		assert.NotNil(t, applyCollectionOps(values, []CollectionOp{{Op: "pop", Field: "m"}}))
+		assert.NotNil(t, applyCollectionOps(values, []CollectionOp{{Op: OpAdd, Values: []interface{}{1}}}))
+	}
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Condition operators
+const (
+	//CondEq - field exists and equals Value
+	CondEq = "eq"
+	//CondNe - field does not exist or differs from Value
+	CondNe = "ne"
+	//CondLt - field exists and is less than Value
+	CondLt = "lt"
+	//CondLe - field exists and is less than or equal to Value
+	CondLe = "le"
+	//CondGt - field exists and is greater than Value
+	CondGt = "gt"
+	//CondGe - field exists and is greater than or equal to Value
+	CondGe = "ge"
+	//CondExists - field exists (is not null)
+	CondExists = "exists"
+	//CondNotExists - field does not exist (is null)
+	CondNotExists = "notExists"
+	//CondIn - field exists and equals one of Value elements (an array)
+	CondIn = "in"
+	//CondNotIn - field does not exist or differs from all Value elements
+	CondNotIn = "notIn"
+	//CondPrefix - field is a string starting with Value
+	CondPrefix = "prefix"
+	//CondNotPrefix - field does not exist or is not a string starting with Value
+	CondNotPrefix = "notPrefix"
+)
+
+//Condition is a predicate over current field values of a record: either a comparison (Field, Op, Value)
+//or a boolean combination of conditions (And, Or, Not)
+type Condition struct {
+	Field string      `json:",omitempty"`
+	Op    string      `json:",omitempty"`
+	Value interface{} `json:",omitempty"`
+
+	And []*Condition `json:",omitempty"`
+	Or  []*Condition `json:",omitempty"`
+	Not *Condition   `json:",omitempty"`
+}
+
+//conditionOps are the operators in the order of documentation
+var conditionOps = []string{CondEq, CondNe, CondLt, CondLe, CondGt, CondGe, CondExists, CondNotExists, CondIn, CondNotIn, CondPrefix, CondNotPrefix}
+
+//negatedOps: negation of a comparison is the opposite comparison; ordered ones also hold for missing fields,
+//see orderedOps
+var negatedOps = map[string]string{
+	CondEq:        CondNe,
+	CondNe:        CondEq,
+	CondLt:        CondGe,
+	CondGe:        CondLt,
+	CondGt:        CondLe,
+	CondLe:        CondGt,
+	CondExists:    CondNotExists,
+	CondNotExists: CondExists,
+	CondIn:        CondNotIn,
+	CondNotIn:     CondIn,
+	CondPrefix:    CondNotPrefix,
+	CondNotPrefix: CondPrefix,
+}
+
+//orderedOps are false for missing fields both ways, so their negation is "missing or opposite"
+var orderedOps = map[string]bool{CondLt: true, CondLe: true, CondGt: true, CondGe: true}
+
+//conditionError tells a conditional update is not applied; record holds current values
+type conditionError struct {
+	record *Record
+}
+
+func (e *conditionError) Error() string {
+	return fmt.Sprintf("condition failed for record %v", e.record.Key)
+}
+
+//notFoundError tells the updated record does not exist; updates do not create records
+type notFoundError struct {
+	partition int64
+	view      string
+	key       string
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("record %v does not exist in partition %v view %v", e.key, e.partition, e.view)
+}
+
+//updateResponse is the response of a failed update: conflict with current values when condition fails,
+//not found when the record does not exist
+func updateResponse(err error) *DBResponse {
+	switch e := err.(type) {
+	case *conditionError:
+		return &DBResponse{Status: 409, Error: e.Error(), Records: []*Record{e.record}}
+	case *notFoundError:
+		return &DBResponse{Status: 404, Error: e.Error()}
+	}
+
+	return &DBResponse{Status: 400, Error: err.Error()}
+}
+
+//normalize checks condition and pushes Not down to comparisons; Not is boolean negation for every
+//operator, missing fields included
+func (c *Condition) normalize(negate bool) (*Condition, error) {
+	given := 0
+
+	for _, ok := range []bool{c.Op != "", len(c.And) > 0, len(c.Or) > 0, c.Not != nil} {
+		if ok {
+			given++
+		}
+	}
+
+	if given != 1 {
+		return nil, fmt.Errorf("condition should have exactly one of Op, And, Or, Not")
+	}
+
+	switch {
+	case c.Not != nil:
+		return c.Not.normalize(!negate)
+	case c.Op != "":
+		op, ok := negatedOps[c.Op]
+
+		if !ok {
+			return nil, fmt.Errorf("condition operator %q is unknown; available: %v", c.Op, strings.Join(conditionOps, ", "))
+		}
+
+		if c.Field == "" {
+			return nil, fmt.Errorf("condition %v: field is not given", c.Op)
+		}
+
+		if c.Value == nil && c.Op != CondExists && c.Op != CondNotExists {
+			return nil, fmt.Errorf("condition %v of %v: value is not given; use %v or %v", c.Op, c.Field, CondExists, CondNotExists)
+		}
+
+		if _, ok := c.Value.([]interface{}); !ok && (c.Op == CondIn || c.Op == CondNotIn) {
+			return nil, fmt.Errorf("condition %v of %v: value should be an array", c.Op, c.Field)
+		}
+
+		if _, ok := c.Value.(string); !ok && (c.Op == CondPrefix || c.Op == CondNotPrefix) {
+			return nil, fmt.Errorf("condition %v of %v: value should be a string", c.Op, c.Field)
+		}
+
+		if !negate {
+			return &Condition{Field: c.Field, Op: c.Op, Value: c.Value}, nil
+		}
+
+		if orderedOps[c.Op] {
+			return &Condition{Or: []*Condition{{Field: c.Field, Op: CondNotExists}, {Field: c.Field, Op: op, Value: c.Value}}}, nil
+		}
+
+		return &Condition{Field: c.Field, Op: op, Value: c.Value}, nil
+	}
+
+	conds, and := c.And, !negate
+
+	if len(c.Or) > 0 {
+		conds, and = c.Or, negate
+	}
+
+	n := &Condition{}
+
+	for _, sub := range conds {
+		s, err := sub.normalize(negate)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if and {
+			n.And = append(n.And, s)
+		} else {
+			n.Or = append(n.Or, s)
+		}
+	}
+
+	return n, nil
+}
+
+//fields returns names of the fields the condition refers to
+func (c *Condition) fields() []string {
+	if c.Field != "" {
+		return []string{c.Field}
+	}
+
+	var fields []string
+
+	subs := make([]*Condition, 0, len(c.And)+len(c.Or))
+	subs = append(append(subs, c.And...), c.Or...)
+
+	for _, s := range subs {
+		fields = append(fields, s.fields()...)
+	}
+
+	if c.Not != nil {
+		fields = append(fields, c.Not.fields()...)
+	}
+
+	return fields
+}
+
+//evaluate evaluates normalized condition against record values
+func (c *Condition) evaluate(values map[string]interface{}) (bool, error) {
+	switch {
+	case len(c.And) > 0:
+		for _, s := range c.And {
+			if ok, err := s.evaluate(values); err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case len(c.Or) > 0:
+		for _, s := range c.Or {
+			if ok, err := s.evaluate(values); err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	}
+
+	v, exists := values[c.Field]
+	exists = exists && v != nil
+
+	switch c.Op {
+	case CondExists:
+		return exists, nil
+	case CondNotExists:
+		return !exists, nil
+	case CondEq:
+		return exists && equalValues(v, c.Value), nil
+	case CondNe:
+		return !exists || !equalValues(v, c.Value), nil
+	case CondIn, CondNotIn:
+		in := false
+
+		for _, e := range c.Value.([]interface{}) {
+			if exists && equalValues(v, e) {
+				in = true
+				break
+			}
+		}
+
+		return in == (c.Op == CondIn), nil
+	case CondPrefix, CondNotPrefix:
+		s, ok := v.(string)
+		prefixed := ok && strings.HasPrefix(s, c.Value.(string))
+
+		return prefixed == (c.Op == CondPrefix), nil
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	cmp, err := compareValues(v, c.Value)
+
+	if err != nil {
+		return false, fmt.Errorf("condition %v of %v: %v", c.Op, c.Field, err)
+	}
+
+	switch c.Op {
+	case CondLt:
+		return cmp < 0, nil
+	case CondLe:
+		return cmp <= 0, nil
+	case CondGt:
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+//equalValues compares numbers by value, other values deeply
+func equalValues(a, b interface{}) bool {
+	an, aok := numberValue(a)
+	bn, bok := numberValue(b)
+
+	if aok && bok {
+		return an == bn
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+//compareValues orders numbers and strings
+func compareValues(a, b interface{}) (int, error) {
+	an, aok := numberValue(a)
+	bn, bok := numberValue(b)
+
+	if aok && bok {
+		switch {
+		case an < bn:
+			return -1, nil
+		case an > bn:
+			return 1, nil
+		}
+
+		return 0, nil
+	}
+
+	as, aok := a.(string)
+	bs, bok := b.(string)
+
+	if aok && bok {
+		switch {
+		case as < bs:
+			return -1, nil
+		case as > bs:
+			return 1, nil
+		}
+
+		return 0, nil
+	}
+
+	return 0, fmt.Errorf("%v and %v can not be compared", a, b)
+}
+
+//checkCondition evaluates condition of the update against current values; nil condition holds
+func checkCondition(view *ViewMod, key string, values map[string]interface{}) error {
+	if view.If == nil {
+		return nil
+	}
+
+	c, err := view.If.normalize(false)
+
+	if err != nil {
+		return err
+	}
+
+	ok, err := c.evaluate(values)
+
+	if err != nil || ok {
+		return err
+	}
+
+	current := make(map[string]interface{}, len(values))
+
+	for k, v := range values {
+		current[k] = v
+	}
+
+	return &conditionError{record: &Record{Key: key, Values: current}}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Condition(t *testing.T) {
+	values := map[string]interface{}{"status": "open", "n": float64(3), "tags": []interface{}{"a"}}
+
+	eval := func(data string) (bool, error) {
+		c := &Condition{}
+		assert.Nil(t, json.Unmarshal([]byte(data), c))
+
+		n, err := c.normalize(false)
+
+		if err != nil {
+			return false, err
+		}
+
+		return n.evaluate(values)
+	}
+
+	{
+		for data, expected := range map[string]bool{
+			`{"Field": "status", "Op": "eq", "Value": "open"}`:                                                             true,
+			`{"Field": "status", "Op": "ne", "Value": "open"}`:                                                             false,
+			`{"Field": "missing", "Op": "ne", "Value": "open"}`:                                                            true,
+			`{"Field": "n", "Op": "eq", "Value": 3}`:                                                                       true,
+			`{"Field": "n", "Op": "lt", "Value": 5}`:                                                                       true,
+			`{"Field": "n", "Op": "ge", "Value": 5}`:                                                                       false,
+			`{"Field": "status", "Op": "gt", "Value": "close"}`:                                                            true,
+			`{"Field": "missing", "Op": "lt", "Value": 5}`:                                                                 false,
+			`{"Not": {"Field": "missing", "Op": "lt", "Value": 5}}`:                                                        true,
+			`{"Not": {"Field": "missing", "Op": "eq", "Value": 5}}`:                                                        true,
+			`{"Not": {"Field": "missing", "Op": "ne", "Value": 5}}`:                                                        false,
+			`{"Not": {"Field": "missing", "Op": "exists"}}`:                                                                true,
+			`{"Not": {"Field": "missing", "Op": "in", "Value": [5]}}`:                                                      true,
+			`{"Not": {"Field": "missing", "Op": "prefix", "Value": "x"}}`:                                                  true,
+			`{"Not": {"Not": {"Field": "missing", "Op": "ge", "Value": 5}}}`:                                               false,
+			`{"Not": {"Field": "n", "Op": "lt", "Value": 5}}`:                                                              false,
+			`{"Field": "tags", "Op": "exists"}`:                                                                            true,
+			`{"Field": "missing", "Op": "notExists"}`:                                                                      true,
+			`{"Field": "tags", "Op": "eq", "Value": ["a"]}`:                                                                true,
+			`{"And": [{"Field": "status", "Op": "eq", "Value": "open"}, {"Field": "n", "Op": "gt", "Value": 3}]}`:          false,
+			`{"Or": [{"Field": "status", "Op": "eq", "Value": "open"}, {"Field": "n", "Op": "gt", "Value": 3}]}`:           true,
+			`{"Not": {"Or": [{"Field": "status", "Op": "eq", "Value": "x"}, {"Field": "n", "Op": "gt", "Value": 3}]}}`:     true,
+			`{"Not": {"And": [{"Field": "status", "Op": "eq", "Value": "open"}, {"Field": "n", "Op": "le", "Value": 3}]}}`: false,
+			`{"Field": "n", "Op": "in", "Value": [1, 3]}`:                                                                  true,
+			`{"Field": "missing", "Op": "notIn", "Value": [1, 3]}`:                                                         true,
+			`{"Not": {"Field": "status", "Op": "in", "Value": ["open"]}}`:                                                  false,
+			`{"Field": "status", "Op": "prefix", "Value": "op"}`:                                                           true,
+			`{"Field": "n", "Op": "prefix", "Value": "3"}`:                                                                 false,
+			`{"Not": {"Field": "status", "Op": "prefix", "Value": "cl"}}`:                                                  true,
+		} {
+			ok, err := eval(data)
+			assert.Nil(t, err, data)
+			assert.Equal(t, expected, ok, data)
+		}
+	}
+
+	{
+		for _, data := range []string{
+			`{"Field": "n", "Op": "like", "Value": 3}`,
+			`{"Op": "eq", "Value": 3}`,
+			`{"Field": "n", "Op": "eq"}`,
+			`{"Field": "n", "Op": "lt", "Value": "x"}`,
+			`{"Field": "n", "Op": "in", "Value": 3}`,
+			`{"Field": "status", "Op": "prefix", "Value": 3}`,
+			`{"Field": "n", "Op": "eq", "Value": 3, "Not": {"Field": "n", "Op": "exists"}}`,
+			`{}`,
+		} {
+			_, err := eval(data)
+			assert.NotNil(t, err, data)
+		}
+	}
+
+	{
+		view := &ViewMod{If: &Condition{Field: "status", Op: CondEq, Value: "closed"}}
+		err := checkCondition(view, "k", values)
+
+		res := updateResponse(err)
+		assert.Equal(t, int64(409), res.Status)
+		assert.Equal(t, "open", res.Records[0].Values["status"])
+		assert.Nil(t, checkCondition(&ViewMod{}, "k", values))
+	}
+}
@@ -0,0 +1,476 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//ParamKind s.e.
+type ParamKind int
+
+const (
+	//KindString s.e.
+	KindString ParamKind = iota
+	//KindInt s.e.
+	KindInt
+	//KindBool s.e.
+	KindBool
+	//KindEnum value must be one of Param.Values
+	KindEnum
+	//KindList comma separated list of strings
+	KindList
+	//KindDuration value in time.ParseDuration format
+	KindDuration
+)
+
+//Source tells where the effective value of a parameter comes from
+type Source string
+
+const (
+	//SourceDefault s.e.
+	SourceDefault Source = "default"
+	//SourceFile s.e.
+	SourceFile Source = "file"
+	//SourceFlag s.e.
+	SourceFlag Source = "flag"
+	//SourceEnv s.e.
+	SourceEnv Source = "env"
+)
+
+const redacted = "******"
+
+//ConfigValue s.e.
+type ConfigValue struct {
+	Param  Param
+	Value  string
+	Source Source
+}
+
+//Config holds validated values of the service and drivers parameters.
+//Precedence from lowest to highest: defaults, config file, flags, environment variables.
+type Config struct {
+	params []Param
+	values map[string]*ConfigValue
+
+	//Help is set when -h or --help is given
+	Help bool
+	//File is the config file used, if any
+	File string
+}
+
+//LoadConfig resolves parameters from command line arguments (without program name),
+//environment and the config file given by -config or SERVICE_CONFIG
+func LoadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	if lookupEnv == nil {
+		lookupEnv = func(string) (string, bool) { return "", false }
+	}
+
+	c := &Config{
+		params: configParams(),
+		values: map[string]*ConfigValue{},
+	}
+
+	for _, p := range c.params {
+		c.values[p.Name] = &ConfigValue{Param: p, Value: p.Default, Source: SourceDefault}
+	}
+
+	flags, err := c.parseFlags(args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if v, exists := lookupEnv(ConfigEnvironmentProperty); exists && v != "" {
+		c.File = v
+	} else if v, exists := flags[ConfigAttribute]; exists {
+		c.File = v
+	}
+
+	if c.File != "" {
+		if err := c.loadFile(c.File); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range c.params {
+		if v, exists := flags[p.Attribute]; exists && p.Attribute != "" {
+			if err := c.set(p, v, SourceFlag); err != nil {
+				return nil, fmt.Errorf("argument %v malformed: %v", p.Attribute, err)
+			}
+		}
+
+		if p.Env == "" {
+			continue
+		}
+
+		if v, exists := lookupEnv(p.Env); exists && v != "" {
+			if err := c.set(p, v, SourceEnv); err != nil {
+				return nil, fmt.Errorf("environment variable %v malformed: %v", p.Env, err)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+//DefaultConfig returns config with default values only
+func DefaultConfig() *Config {
+	c, err := LoadConfig(nil, nil)
+
+	if err != nil {
+		panic("service: default config is invalid: " + err.Error())
+	}
+
+	return c
+}
+
+//String s.e.
+func (c *Config) String(name string) string {
+	if v, ok := c.values[name]; ok {
+		return v.Value
+	}
+
+	return ""
+}
+
+//Int s.e.
+func (c *Config) Int(name string) int64 {
+	i, _ := strconv.ParseInt(c.String(name), 10, 64)
+	return i
+}
+
+//Bool s.e.
+func (c *Config) Bool(name string) bool {
+	b, _ := strconv.ParseBool(c.String(name))
+	return b
+}
+
+//Duration s.e.
+func (c *Config) Duration(name string) time.Duration {
+	d, _ := time.ParseDuration(c.String(name))
+	return d
+}
+
+//List s.e.
+func (c *Config) List(name string) []string {
+	return splitList(c.String(name))
+}
+
+//Source s.e.
+func (c *Config) Source(name string) Source {
+	if v, ok := c.values[name]; ok {
+		return v.Source
+	}
+
+	return ""
+}
+
+//Set overrides parameter value; used by embedding code and tests
+func (c *Config) Set(name, value string) error {
+	v, ok := c.values[name]
+
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+
+	return c.set(v.Param, value, SourceFlag)
+}
+
+//Effective returns all values sorted by name with secrets redacted
+func (c *Config) Effective() []ConfigValue {
+	list := make([]ConfigValue, 0, len(c.values))
+
+	for _, v := range c.values {
+		e := *v
+
+		if e.Param.Secret && e.Value != "" {
+			e.Value = redacted
+		}
+
+		list = append(list, e)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Param.Name < list[j].Param.Name })
+
+	return list
+}
+
+func (c *Config) secretEnvs() map[string]bool {
+	envs := map[string]bool{}
+
+	for _, p := range c.params {
+		if p.Secret && p.Env != "" {
+			envs[p.Env] = true
+		}
+	}
+
+	return envs
+}
+
+//PrintUsage writes help for the service and all registered drivers
+func PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: reference-crud-app [arguments]\n")
+	fmt.Fprintf(w, "       reference-crud-app %v %v|%v [%v] [arguments] - schema migrations of the driver\n\n", MigrateCommand, MigrateUp, MigrateStatus, DryRunAttribute)
+	fmt.Fprintf(w, "Parameters are taken from defaults, the config file, arguments and environment variables, the latter wins.\n\n")
+	fmt.Fprintf(w, "Common arguments:\n\n")
+	fmt.Fprintf(w, "  %v (env.v. %v) - YAML or JSON config file; keys are parameter names\n", ConfigAttribute, ConfigEnvironmentProperty)
+	fmt.Fprintf(w, "  -h, --help - print this help\n")
+
+	for _, p := range serviceParams() {
+		fmt.Fprintf(w, "  %v\n", describeParam(p))
+	}
+
+	fmt.Fprintf(w, "\nDrivers:\n\n%v", describeDrivers())
+}
+
+func (c *Config) parseFlags(args []string) (map[string]string, error) {
+	known := map[string]bool{ConfigAttribute: true}
+
+	for _, p := range c.params {
+		if p.Attribute != "" {
+			known[p.Attribute] = true
+		}
+	}
+
+	flags := mapArgs(args)
+
+	for f := range flags {
+		if f == "-h" || f == "--help" {
+			c.Help = true
+			delete(flags, f)
+			continue
+		}
+
+		if !known[f] {
+			return nil, fmt.Errorf("unknown argument %v; see --help", f)
+		}
+	}
+
+	return flags, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]interface{}
+
+	// JSON is a subset of YAML so one parser serves both formats
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("config file %v malformed: %v", path, err)
+	}
+
+	flat := map[string]string{}
+
+	if err := flattenConfig("", doc, flat); err != nil {
+		return fmt.Errorf("config file %v malformed: %v", path, err)
+	}
+
+	for k, v := range flat {
+		cv, ok := c.values[k]
+
+		if !ok {
+			return fmt.Errorf("config file %v: unknown parameter %q", path, k)
+		}
+
+		if v == "" {
+			continue
+		}
+
+		if err := c.set(cv.Param, v, SourceFile); err != nil {
+			return fmt.Errorf("config file %v: parameter %q malformed: %v", path, k, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) set(p Param, value string, source Source) error {
+	v, err := validateParam(p, value)
+
+	if err != nil {
+		return err
+	}
+
+	c.values[p.Name] = &ConfigValue{Param: p, Value: v, Source: source}
+
+	return nil
+}
+
+func validateParam(p Param, value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch p.Kind {
+	case KindInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+			return "", fmt.Errorf("integer expected, got %q", value)
+		}
+
+		if i < p.Min || (p.Max != 0 && i > p.Max) {
+			if p.Max != 0 {
+				return "", fmt.Errorf("value %v out of range [%v, %v]", i, p.Min, p.Max)
+			}
+
+			return "", fmt.Errorf("value %v less than %v", i, p.Min)
+		}
+
+		return strconv.FormatInt(i, 10), nil
+	case KindBool:
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return "", fmt.Errorf("boolean expected, got %q", value)
+		}
+
+		return strconv.FormatBool(b), nil
+	case KindEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return value, nil
+			}
+		}
+
+		return "", fmt.Errorf("%q is not one of: %v", value, strings.Join(p.Values, ", "))
+	case KindDuration:
+		d, err := time.ParseDuration(value)
+
+		if err != nil {
+			return "", fmt.Errorf("duration expected (e.g. 500ms, 2s), got %q", value)
+		}
+
+		if p.MinDuration != 0 && d < p.MinDuration {
+			return "", fmt.Errorf("duration %v less than %v", d, p.MinDuration)
+		}
+
+		return value, nil
+	case KindList:
+		list := splitList(value)
+
+		if len(list) == 0 {
+			return "", fmt.Errorf("comma separated list expected, got %q", value)
+		}
+
+		return strings.Join(list, ","), nil
+	default:
+		return value, nil
+	}
+}
+
+func flattenConfig(prefix string, node interface{}, flat map[string]string) error {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			name := k
+
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+
+			if err := flattenConfig(name, child, flat); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+
+		flat[prefix] = strings.Join(items, ",")
+	case nil:
+		flat[prefix] = ""
+	default:
+		flat[prefix] = fmt.Sprint(v)
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func configParams() []Param {
+	params := serviceParams()
+	seen := map[string]bool{}
+
+	for _, p := range params {
+		seen[p.Name] = true
+	}
+
+	for _, r := range Drivers() {
+		for _, p := range r.Params {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				params = append(params, p)
+			}
+		}
+	}
+
+	return params
+}
+
+func serviceParams() []Param {
+	return []Param{
+		{Name: ServiceDriverParam, Attribute: ServiceDriverAttribute, Env: ServiceDriverEnvironmentProperty, Default: DefaultDriver, Kind: KindEnum, Values: DriverNames(), Description: "driver selection"},
+		{Name: ServicePortParam, Attribute: ServicePortAttribute, Env: ServicePortEnvironmentProperty, Default: strconv.Itoa(DefaultPort), Kind: KindInt, Min: 1, Max: 65535, Description: "port number for listening"},
+		{Name: PathPatternParam, Attribute: PathPatternAttribute, Env: PathPatternEnvironmentProperty, Default: DefaultPathPattern, Description: "handler path pattern"},
+		{Name: ServiceInsertFuncParam, Attribute: ServiceInsertFuncAttribute, Env: ServiceInsertFuncEnvironmentProperty, Default: InsertDefaultFunc, Description: "insert function name"},
+		{Name: ServiceReadFuncParam, Attribute: ServiceReadFuncAttribute, Env: ServiceReadFuncEnvironmentProperty, Default: ReadDefaultFunc, Description: "read function name"},
+		{Name: ServiceUpdateFuncParam, Attribute: ServiceUpdateFuncAttribute, Env: ServiceUpdateFuncEnvironmentProperty, Default: UpdateDefaultFunc, Description: "update function name"},
+		{Name: ServiceScanFuncParam, Attribute: ServiceScanFuncAttribute, Env: ServiceScanFuncEnvironmentProperty, Default: ScanDefaultFunc, Description: "scan function name"},
+		{Name: ServiceDeleteFuncParam, Attribute: ServiceDeleteFuncAttribute, Env: ServiceDeleteFuncEnvironmentProperty, Default: DeleteDefaultFunc, Description: "delete function name"},
+		{Name: ServiceIncrementFuncParam, Attribute: ServiceIncrementFuncAttribute, Env: ServiceIncrementFuncEnvironmentProperty, Default: IncrementDefaultFunc, Description: "increment function name"},
+		{Name: ServiceLookupFuncParam, Attribute: ServiceLookupFuncAttribute, Env: ServiceLookupFuncEnvironmentProperty, Default: LookupDefaultFunc, Description: "index lookup function name"},
+		{Name: LoggerLevelParam, Attribute: LoggerLevelAttribute, Env: LoggerLevelEnvironmentProperty, Default: InfoLevel.String(), Kind: KindEnum, Values: append(append([]string{}, levelNames...), "0", "1"), Description: "logger level; former 0 and 1 mean info and debug"},
+		{Name: LoggerFormatParam, Attribute: LoggerFormatAttribute, Env: LoggerFormatEnvironmentProperty, Default: LogFormatText, Kind: KindEnum, Values: []string{LogFormatText, LogFormatJSON}, Description: "logger format"},
+		{Name: SchemeParam, Attribute: SchemeAttribute, Env: SchemeEnvironmentProperty, Default: DefaultSchemeFile, Description: "views scheme file"},
+		{Name: NoopServiceParam, Attribute: NoopServiceAttribute, Env: NoopServiceEnvironmentProperty, Default: "false", Kind: KindBool, Description: "answer data requests without calling the driver"},
+		{Name: HotTopParam, Attribute: HotTopAttribute, Env: HotTopEnvironmentProperty, Default: strconv.Itoa(DefaultHotTop), Kind: KindInt, Max: 1000, Description: "number of the hottest partitions and keys tracked per window; 0 - tracking is off"},
+		{Name: HotWindowsParam, Attribute: HotWindowsAttribute, Env: HotWindowsEnvironmentProperty, Default: DefaultHotWindows, Kind: KindList, Description: "durations of hot keys windows"},
+		{Name: SlowThresholdParam, Attribute: SlowThresholdAttribute, Env: SlowThresholdEnvironmentProperty, Default: DefaultSlowThreshold.String(), Kind: KindDuration, Description: "driver calls longer than this are logged as slow; 0 - the slow log is off"},
+		{Name: SlowFuncThresholdsParam, Attribute: SlowFuncThresholdsAttribute, Env: SlowFuncThresholdsEnvironmentProperty, Description: "slow thresholds per function, e.g. YcsbScan=500ms,YcsbView=20ms"},
+		{Name: SlowSizeParam, Attribute: SlowSizeAttribute, Env: SlowSizeEnvironmentProperty, Default: strconv.Itoa(DefaultSlowBufferSize), Kind: KindInt, Min: 1, Max: 100000, Description: "number of slow operations kept in memory"},
+		{Name: SlowSampleParam, Attribute: SlowSampleAttribute, Env: SlowSampleEnvironmentProperty, Default: strconv.Itoa(DefaultSlowSampleSize), Kind: KindInt, Description: "max number of request body bytes kept per slow operation"},
+		{Name: SlowFileParam, Attribute: SlowFileAttribute, Env: SlowFileEnvironmentProperty, Description: "file slow operations are appended to as JSON lines"},
+		{Name: SlowFileMaxSizeParam, Attribute: SlowFileMaxSizeAttribute, Env: SlowFileMaxSizeEnvironmentProperty, Default: strconv.Itoa(DefaultSlowFileMaxSize), Kind: KindInt, Min: 1, Description: "size of the slow operations file in megabytes which makes it rotate"},
+		{Name: SlowFileBackupsParam, Attribute: SlowFileBackupsAttribute, Env: SlowFileBackupsEnvironmentProperty, Default: strconv.Itoa(DefaultSlowFileBackups), Kind: KindInt, Description: "number of rotated slow operations files kept"},
+		{Name: TraceExporterParam, Attribute: TraceExporterAttribute, Env: TraceExporterEnvironmentProperty, Default: TraceExporterNone, Kind: KindEnum, Values: []string{TraceExporterNone, TraceExporterFile, TraceExporterOTLP}, Description: "exporter of request traces"},
+		{Name: TraceFileParam, Attribute: TraceFileAttribute, Env: TraceFileEnvironmentProperty, Default: DefaultTraceFile, Description: "file spans are appended to as JSON lines by file exporter"},
+		{Name: TraceEndpointParam, Attribute: TraceEndpointAttribute, Env: TraceEndpointEnvironmentProperty, Default: DefaultTraceEndpoint, Description: "OTLP/HTTP traces endpoint of otlp exporter"},
+		{Name: TraceSampleParam, Attribute: TraceSampleAttribute, Env: TraceSampleEnvironmentProperty, Default: "100", Kind: KindInt, Max: 100, Description: "percent of traced requests which come without sampled traceparent"},
+		{Name: HealthTimeoutParam, Attribute: HealthTimeoutAttribute, Env: HealthTimeoutEnvironmentProperty, Default: DefaultHealthTimeout.String(), Kind: KindDuration, Description: "timeout of the driver ping of health and readiness checks"},
+		{Name: AdminPortParam, Attribute: AdminPortAttribute, Env: AdminPortEnvironmentProperty, Default: strconv.Itoa(DefaultAdminPort), Kind: KindInt, Max: 65535, Description: "port of administrative routes; 0 - they are not served"},
+		{Name: AdminHostParam, Attribute: AdminHostAttribute, Env: AdminHostEnvironmentProperty, Default: DefaultAdminHost, Description: "host the admin listener binds"},
+		{Name: AdminTokenParam, Attribute: AdminTokenAttribute, Env: AdminTokenEnvironmentProperty, Secret: true, Description: "bearer token required by administrative routes"},
+		{Name: AdminTLSCertParam, Attribute: AdminTLSCertAttribute, Env: AdminTLSCertEnvironmentProperty, Description: "certificate file (PEM) of the admin listener; enables HTTPS"},
+		{Name: AdminTLSKeyParam, Attribute: AdminTLSKeyAttribute, Env: AdminTLSKeyEnvironmentProperty, Description: "private key file (PEM) of the admin listener"},
+		{Name: AdminTLSClientCAParam, Attribute: AdminTLSClientCAAttribute, Env: AdminTLSClientCAEnvironmentProperty, Description: "CA certificate file (PEM) of admin clients; enables mTLS"},
+		{Name: AdminAuditFileParam, Attribute: AdminAuditFileAttribute, Env: AdminAuditFileEnvironmentProperty, Description: "file destructive admin actions and denied admin requests are appended to as JSON lines"},
+	}
+}
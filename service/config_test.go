@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yml")
+	data := "driver: mem\nport: 8081\ncas:\n  hosts: [10.0.0.1, 10.0.0.2]\n  password: secret\n"
+	assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0600))
+
+	env := map[string]string{
+		ServicePortEnvironmentProperty: "8083",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	{
+		cfg, err := LoadConfig([]string{"-config", file, "-p", "8082", "--lwt", "2"}, lookupEnv)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "mem", cfg.String(ServiceDriverParam))
+		assert.Equal(t, SourceFile, cfg.Source(ServiceDriverParam))
+		assert.Equal(t, int64(8083), cfg.Int(ServicePortParam))
+		assert.Equal(t, SourceEnv, cfg.Source(ServicePortParam))
+		assert.Equal(t, int64(2), cfg.Int(LightWeightTransactionParam))
+		assert.Equal(t, SourceFlag, cfg.Source(LightWeightTransactionParam))
+		assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cfg.List(HostsParam))
+		assert.Equal(t, DefaultKeyspaceName, cfg.String(KeyspaceParam))
+		assert.Equal(t, SourceDefault, cfg.Source(KeyspaceParam))
+
+		for _, v := range cfg.Effective() {
+			if v.Param.Name == PasswordParam {
+				assert.Equal(t, redacted, v.Value)
+			}
+		}
+	}
+
+	{
+		_, err := LoadConfig([]string{"-p", "port"}, nil)
+		assert.NotNil(t, err)
+
+		_, err = LoadConfig([]string{"-d", "unknown"}, nil)
+		assert.NotNil(t, err)
+
+		_, err = LoadConfig([]string{"--lwt", "3"}, nil)
+		assert.NotNil(t, err)
+
+		_, err = LoadConfig([]string{"--unknown", "3"}, nil)
+		assert.NotNil(t, err)
+
+		for _, interval := range []string{"0", "-1s", "500us"} {
+			_, err = LoadConfig([]string{"-d", "mem", ReapIntervalAttribute, interval}, nil)
+			assert.NotNil(t, err, interval)
+		}
+
+		_, err = LoadConfig([]string{"-d", "mem", ReapIntervalAttribute, "10ms"}, nil)
+		assert.Nil(t, err)
+	}
+
+	{
+		cfg, err := LoadConfig([]string{"--help"}, nil)
+		assert.Nil(t, err)
+		assert.True(t, cfg.Help)
+	}
+}
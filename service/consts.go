@@ -9,6 +9,21 @@ const MaxReconnectCount = 100
 //DefaultPort s.e.
 const DefaultPort = 80
 
+//DefaultDriver s.e.
+const DefaultDriver = "cas"
+
+//NopDriverName s.e.
+const NopDriverName = "nop"
+
+//DefaultSchemeFile s.e.
+const DefaultSchemeFile = "data/scheme.yml"
+
+//DefaultScanLimit s.e.
+const DefaultScanLimit = 100
+
+//MaxAggregatePages is the most pages a scan with aggregates reads; scans needing more fail
+const MaxAggregatePages = 100
+
 //DefaultKeyspaceName s.e.
 const DefaultKeyspaceName = "heeustst"
 
@@ -39,6 +54,12 @@ const ScanDefaultFunc = "YcsbScan"
 //DeleteDefaultFunc s.e.
 const DeleteDefaultFunc = "YcsbDel"
 
+//IncrementDefaultFunc s.e.
+const IncrementDefaultFunc = "YcsbInc"
+
+//LookupDefaultFunc s.e.
+const LookupDefaultFunc = "YcsbLookup"
+
 //PathPatternEnvironmentProperty s.e
 const ServiceDriverEnvironmentProperty = "SERVICE_DRIVER"
 
@@ -63,9 +84,21 @@ const ServiceScanFuncEnvironmentProperty = "SERVICE_SCAN_FUNC_NAME"
 //ServiceDeleteFuncEnvironmentProperty s.e
 const ServiceDeleteFuncEnvironmentProperty = "SERVICE_DELETE_FUNC_NAME"
 
+//ServiceIncrementFuncEnvironmentProperty s.e
+const ServiceIncrementFuncEnvironmentProperty = "SERVICE_INCREMENT_FUNC_NAME"
+
+//ServiceLookupFuncEnvironmentProperty s.e
+const ServiceLookupFuncEnvironmentProperty = "SERVICE_LOOKUP_FUNC_NAME"
+
+//SchemeEnvironmentProperty s.e.
+const SchemeEnvironmentProperty = "SERVICE_SCHEME"
+
 //LoggerLevelEnvironmentProperty s.e.
 const LoggerLevelEnvironmentProperty = "SERVICE_LOGGER_LEVEL"
 
+//LoggerFormatEnvironmentProperty s.e.
+const LoggerFormatEnvironmentProperty = "SERVICE_LOGGER_FORMAT"
+
 //KeyspaceEnvironmentProperty s.e.
 const KeyspaceEnvironmentProperty = "DB_KEYSPACE"
 
@@ -86,11 +119,128 @@ const ConsistencyEnvironmentProperty = "DB_CAS_CONSISTENCY"
 //ReplicationFactorEnvironmentProperty s.e.
 const ReplicationFactorEnvironmentProperty = "DB_REP_FACTOR"
 
+//ReplicationEnvironmentProperty s.e.
+const ReplicationEnvironmentProperty = "DB_CAS_REPLICATION"
+
 //LightWeightTransactionAttribute s.e.
 const LightWeightTransactionEnvironmentProperty = "DB_LWT"
 
+//TLSEnvironmentProperty s.e.
+const TLSEnvironmentProperty = "DB_TLS"
+
+//TLSCAEnvironmentProperty s.e.
+const TLSCAEnvironmentProperty = "DB_TLS_CA"
+
+//TLSCertEnvironmentProperty s.e.
+const TLSCertEnvironmentProperty = "DB_TLS_CERT"
+
+//TLSKeyEnvironmentProperty s.e.
+const TLSKeyEnvironmentProperty = "DB_TLS_KEY"
+
+//TLSVerifyHostEnvironmentProperty s.e.
+const TLSVerifyHostEnvironmentProperty = "DB_TLS_VERIFY_HOST"
+
+//LocalDCEnvironmentProperty s.e.
+const LocalDCEnvironmentProperty = "DB_CAS_LOCAL_DC"
+
+//PoolSizeEnvironmentProperty s.e.
+const PoolSizeEnvironmentProperty = "DB_CAS_POOL_SIZE"
+
+//TimeoutEnvironmentProperty s.e.
+const TimeoutEnvironmentProperty = "DB_CAS_TIMEOUT"
+
+//ConnectTimeoutEnvironmentProperty s.e.
+const ConnectTimeoutEnvironmentProperty = "DB_CAS_CONNECT_TIMEOUT"
+
+//CompressionEnvironmentProperty s.e.
+const CompressionEnvironmentProperty = "DB_CAS_COMPRESSION"
+
+//RetriesEnvironmentProperty s.e.
+const RetriesEnvironmentProperty = "DB_CAS_RETRIES"
+
+//SpeculativeAttemptsEnvironmentProperty s.e.
+const SpeculativeAttemptsEnvironmentProperty = "DB_CAS_SPEC_ATTEMPTS"
+
+//SpeculativeDelayEnvironmentProperty s.e.
+const SpeculativeDelayEnvironmentProperty = "DB_CAS_SPEC_DELAY"
+
+//ReapIntervalEnvironmentProperty s.e.
+const ReapIntervalEnvironmentProperty = "MEM_REAP_INTERVAL"
+
+//MigrateEnvironmentProperty s.e.
+const MigrateEnvironmentProperty = "DB_CAS_MIGRATE"
+
+//SchemaAgreementEnvironmentProperty s.e.
+const SchemaAgreementEnvironmentProperty = "DB_CAS_SCHEMA_AGREEMENT"
+
+//PartitionStatsEnvironmentProperty s.e.
+const PartitionStatsEnvironmentProperty = "DB_CAS_PARTITION_STATS"
+
 const NoopServiceEnvironmentProperty = "SERVICE_NOP"
 
+//HotTopEnvironmentProperty s.e.
+const HotTopEnvironmentProperty = "SERVICE_HOT_TOP"
+
+//HotWindowsEnvironmentProperty s.e.
+const HotWindowsEnvironmentProperty = "SERVICE_HOT_WINDOWS"
+
+//SlowThresholdEnvironmentProperty s.e.
+const SlowThresholdEnvironmentProperty = "SERVICE_SLOW_THRESHOLD"
+
+//SlowFuncThresholdsEnvironmentProperty s.e.
+const SlowFuncThresholdsEnvironmentProperty = "SERVICE_SLOW_FUNC_THRESHOLDS"
+
+//SlowSizeEnvironmentProperty s.e.
+const SlowSizeEnvironmentProperty = "SERVICE_SLOW_SIZE"
+
+//SlowSampleEnvironmentProperty s.e.
+const SlowSampleEnvironmentProperty = "SERVICE_SLOW_SAMPLE"
+
+//SlowFileEnvironmentProperty s.e.
+const SlowFileEnvironmentProperty = "SERVICE_SLOW_FILE"
+
+//SlowFileMaxSizeEnvironmentProperty s.e.
+const SlowFileMaxSizeEnvironmentProperty = "SERVICE_SLOW_FILE_MAX_SIZE"
+
+//SlowFileBackupsEnvironmentProperty s.e.
+const SlowFileBackupsEnvironmentProperty = "SERVICE_SLOW_FILE_BACKUPS"
+
+//TraceExporterEnvironmentProperty s.e.
+const TraceExporterEnvironmentProperty = "SERVICE_TRACE_EXPORTER"
+
+//TraceFileEnvironmentProperty s.e.
+const TraceFileEnvironmentProperty = "SERVICE_TRACE_FILE"
+
+//TraceEndpointEnvironmentProperty s.e.
+const TraceEndpointEnvironmentProperty = "SERVICE_TRACE_ENDPOINT"
+
+//TraceSampleEnvironmentProperty s.e.
+const TraceSampleEnvironmentProperty = "SERVICE_TRACE_SAMPLE"
+
+//HealthTimeoutEnvironmentProperty s.e.
+const HealthTimeoutEnvironmentProperty = "SERVICE_HEALTH_TIMEOUT"
+
+//AdminPortEnvironmentProperty s.e.
+const AdminPortEnvironmentProperty = "SERVICE_ADMIN_PORT"
+
+//AdminHostEnvironmentProperty s.e.
+const AdminHostEnvironmentProperty = "SERVICE_ADMIN_HOST"
+
+//AdminTokenEnvironmentProperty s.e.
+const AdminTokenEnvironmentProperty = "SERVICE_ADMIN_TOKEN"
+
+//AdminTLSCertEnvironmentProperty s.e.
+const AdminTLSCertEnvironmentProperty = "SERVICE_ADMIN_TLS_CERT"
+
+//AdminTLSKeyEnvironmentProperty s.e.
+const AdminTLSKeyEnvironmentProperty = "SERVICE_ADMIN_TLS_KEY"
+
+//AdminTLSClientCAEnvironmentProperty s.e.
+const AdminTLSClientCAEnvironmentProperty = "SERVICE_ADMIN_TLS_CLIENT_CA"
+
+//AdminAuditFileEnvironmentProperty s.e.
+const AdminAuditFileEnvironmentProperty = "SERVICE_ADMIN_AUDIT_FILE"
+
 //ServiceDriverAttribute s.e
 const ServiceDriverAttribute = "-d"
 
@@ -113,12 +263,66 @@ const ClassAttribute = "--cs"
 //ReplicationFactorAttribute s.e.
 const ReplicationFactorAttribute = "--rf"
 
+//ReplicationAttribute s.e.
+const ReplicationAttribute = "--replication"
+
 //ConsistencyAttribute s.e.
 const ConsistencyAttribute = "--c"
 
 //LightWeightTransactionAttribute s.e.
 const LightWeightTransactionAttribute = "--lwt"
 
+//TLSAttribute s.e.
+const TLSAttribute = "--tls"
+
+//TLSCAAttribute s.e.
+const TLSCAAttribute = "--tls-ca"
+
+//TLSCertAttribute s.e.
+const TLSCertAttribute = "--tls-cert"
+
+//TLSKeyAttribute s.e.
+const TLSKeyAttribute = "--tls-key"
+
+//TLSVerifyHostAttribute s.e.
+const TLSVerifyHostAttribute = "--tls-verify-host"
+
+//LocalDCAttribute s.e.
+const LocalDCAttribute = "--dc"
+
+//PoolSizeAttribute s.e.
+const PoolSizeAttribute = "--pool"
+
+//TimeoutAttribute s.e.
+const TimeoutAttribute = "--timeout"
+
+//ConnectTimeoutAttribute s.e.
+const ConnectTimeoutAttribute = "--connect-timeout"
+
+//CompressionAttribute s.e.
+const CompressionAttribute = "--compression"
+
+//RetriesAttribute s.e.
+const RetriesAttribute = "--retries"
+
+//SpeculativeAttemptsAttribute s.e.
+const SpeculativeAttemptsAttribute = "--spec-attempts"
+
+//SpeculativeDelayAttribute s.e.
+const SpeculativeDelayAttribute = "--spec-delay"
+
+//ReapIntervalAttribute s.e.
+const ReapIntervalAttribute = "--reap-interval"
+
+//MigrateAttribute s.e.
+const MigrateAttribute = "--migrate"
+
+//SchemaAgreementAttribute s.e.
+const SchemaAgreementAttribute = "--schema-agreement"
+
+//PartitionStatsAttribute s.e.
+const PartitionStatsAttribute = "--partition-stats"
+
 const PathPatternAttribute = "-pp"
 
 //ServiceInsertFuncAttribute s.e
@@ -136,10 +340,276 @@ const ServiceScanFuncAttribute = "-sfn"
 //ServiceDeleteFuncAttribute s.e
 const ServiceDeleteFuncAttribute = "-dfn"
 
+//ServiceIncrementFuncAttribute s.e
+const ServiceIncrementFuncAttribute = "-incfn"
+
+//ServiceLookupFuncAttribute s.e
+const ServiceLookupFuncAttribute = "-lfn"
+
 //ServiceDeleteFuncAttribute s.e
 const LoggerLevelAttribute = "-ll"
 
+//LoggerFormatAttribute s.e.
+const LoggerFormatAttribute = "-lf"
+
+//SchemeAttribute s.e.
+const SchemeAttribute = "-scheme"
+
 const NoopServiceAttribute = "-nop"
 
+//HotTopAttribute s.e.
+const HotTopAttribute = "-hot-top"
+
+//HotWindowsAttribute s.e.
+const HotWindowsAttribute = "-hot-windows"
+
+//SlowThresholdAttribute s.e.
+const SlowThresholdAttribute = "-slow-threshold"
+
+//SlowFuncThresholdsAttribute s.e.
+const SlowFuncThresholdsAttribute = "-slow-func-thresholds"
+
+//SlowSizeAttribute s.e.
+const SlowSizeAttribute = "-slow-size"
+
+//SlowSampleAttribute s.e.
+const SlowSampleAttribute = "-slow-sample"
+
+//SlowFileAttribute s.e.
+const SlowFileAttribute = "-slow-file"
+
+//SlowFileMaxSizeAttribute s.e.
+const SlowFileMaxSizeAttribute = "-slow-file-max-size"
+
+//SlowFileBackupsAttribute s.e.
+const SlowFileBackupsAttribute = "-slow-file-backups"
+
+//TraceExporterAttribute s.e.
+const TraceExporterAttribute = "-trace"
+
+//TraceFileAttribute s.e.
+const TraceFileAttribute = "-trace-file"
+
+//TraceEndpointAttribute s.e.
+const TraceEndpointAttribute = "-trace-endpoint"
+
+//TraceSampleAttribute s.e.
+const TraceSampleAttribute = "-trace-sample"
+
+//HealthTimeoutAttribute s.e.
+const HealthTimeoutAttribute = "-health-timeout"
+
+//AdminPortAttribute s.e.
+const AdminPortAttribute = "-admin-port"
+
+//AdminHostAttribute s.e.
+const AdminHostAttribute = "-admin-host"
+
+//AdminTokenAttribute s.e.
+const AdminTokenAttribute = "-admin-token"
+
+//AdminTLSCertAttribute s.e.
+const AdminTLSCertAttribute = "-admin-tls-cert"
+
+//AdminTLSKeyAttribute s.e.
+const AdminTLSKeyAttribute = "-admin-tls-key"
+
+//AdminTLSClientCAAttribute s.e.
+const AdminTLSClientCAAttribute = "-admin-tls-client-ca"
+
+//AdminAuditFileAttribute s.e.
+const AdminAuditFileAttribute = "-admin-audit-file"
+
+//ConfigAttribute s.e.
+const ConfigAttribute = "-config"
+
+//ConfigEnvironmentProperty s.e.
+const ConfigEnvironmentProperty = "SERVICE_CONFIG"
+
+// Parameter names, also used as config file keys
+
+//ServiceDriverParam s.e.
+const ServiceDriverParam = "driver"
+
+//ServicePortParam s.e.
+const ServicePortParam = "port"
+
+//PathPatternParam s.e.
+const PathPatternParam = "path-pattern"
+
+//ServiceInsertFuncParam s.e.
+const ServiceInsertFuncParam = "func.insert"
+
+//ServiceReadFuncParam s.e.
+const ServiceReadFuncParam = "func.read"
+
+//ServiceUpdateFuncParam s.e.
+const ServiceUpdateFuncParam = "func.update"
+
+//ServiceScanFuncParam s.e.
+const ServiceScanFuncParam = "func.scan"
+
+//ServiceDeleteFuncParam s.e.
+const ServiceDeleteFuncParam = "func.delete"
+
+//ServiceIncrementFuncParam s.e.
+const ServiceIncrementFuncParam = "func.increment"
+
+//ServiceLookupFuncParam s.e.
+const ServiceLookupFuncParam = "func.lookup"
+
+//LoggerLevelParam s.e.
+const LoggerLevelParam = "log.level"
+
+//LoggerFormatParam s.e.
+const LoggerFormatParam = "log.format"
+
+//SchemeParam s.e.
+const SchemeParam = "scheme"
+
+//NoopServiceParam s.e.
+const NoopServiceParam = "noop"
+
+//HotTopParam s.e.
+const HotTopParam = "hot.top"
+
+//HotWindowsParam s.e.
+const HotWindowsParam = "hot.windows"
+
+//SlowThresholdParam s.e.
+const SlowThresholdParam = "slow.threshold"
+
+//SlowFuncThresholdsParam s.e.
+const SlowFuncThresholdsParam = "slow.func-thresholds"
+
+//SlowSizeParam s.e.
+const SlowSizeParam = "slow.size"
+
+//SlowSampleParam s.e.
+const SlowSampleParam = "slow.sample"
+
+//SlowFileParam s.e.
+const SlowFileParam = "slow.file"
+
+//SlowFileMaxSizeParam s.e.
+const SlowFileMaxSizeParam = "slow.file-max-size"
+
+//SlowFileBackupsParam s.e.
+const SlowFileBackupsParam = "slow.file-backups"
+
+//TraceExporterParam s.e.
+const TraceExporterParam = "trace.exporter"
+
+//TraceFileParam s.e.
+const TraceFileParam = "trace.file"
+
+//TraceEndpointParam s.e.
+const TraceEndpointParam = "trace.endpoint"
+
+//TraceSampleParam s.e.
+const TraceSampleParam = "trace.sample"
+
+//HealthTimeoutParam s.e.
+const HealthTimeoutParam = "health.timeout"
+
+//AdminPortParam s.e.
+const AdminPortParam = "admin.port"
+
+//AdminHostParam s.e.
+const AdminHostParam = "admin.host"
+
+//AdminTokenParam s.e.
+const AdminTokenParam = "admin.token"
+
+//AdminTLSCertParam s.e.
+const AdminTLSCertParam = "admin.tls-cert"
+
+//AdminTLSKeyParam s.e.
+const AdminTLSKeyParam = "admin.tls-key"
+
+//AdminTLSClientCAParam s.e.
+const AdminTLSClientCAParam = "admin.tls-client-ca"
+
+//AdminAuditFileParam s.e.
+const AdminAuditFileParam = "admin.audit-file"
+
+//HostsParam s.e.
+const HostsParam = "cas.hosts"
+
+//KeyspaceParam s.e.
+const KeyspaceParam = "cas.keyspace"
+
+//UserParam s.e.
+const UserParam = "cas.user"
+
+//PasswordParam s.e.
+const PasswordParam = "cas.password"
+
+//ClassParam s.e.
+const ClassParam = "cas.class"
+
+//ReplicationFactorParam s.e.
+const ReplicationFactorParam = "cas.replication-factor"
+
+//ReplicationParam s.e.
+const ReplicationParam = "cas.replication"
+
+//ConsistencyParam s.e.
+const ConsistencyParam = "cas.consistency"
+
+//LightWeightTransactionParam s.e.
+const LightWeightTransactionParam = "cas.lwt"
+
+//TLSParam s.e.
+const TLSParam = "cas.tls.enabled"
+
+//TLSCAParam s.e.
+const TLSCAParam = "cas.tls.ca"
+
+//TLSCertParam s.e.
+const TLSCertParam = "cas.tls.cert"
+
+//TLSKeyParam s.e.
+const TLSKeyParam = "cas.tls.key"
+
+//TLSVerifyHostParam s.e.
+const TLSVerifyHostParam = "cas.tls.verify-host"
+
+//LocalDCParam s.e.
+const LocalDCParam = "cas.local-dc"
+
+//PoolSizeParam s.e.
+const PoolSizeParam = "cas.pool-size"
+
+//TimeoutParam s.e.
+const TimeoutParam = "cas.timeout"
+
+//ConnectTimeoutParam s.e.
+const ConnectTimeoutParam = "cas.connect-timeout"
+
+//CompressionParam s.e.
+const CompressionParam = "cas.compression"
+
+//RetriesParam s.e.
+const RetriesParam = "cas.retries"
+
+//SpeculativeAttemptsParam s.e.
+const SpeculativeAttemptsParam = "cas.speculative.attempts"
+
+//SpeculativeDelayParam s.e.
+const SpeculativeDelayParam = "cas.speculative.delay"
+
+//ReapIntervalParam s.e.
+const ReapIntervalParam = "mem.reap-interval"
+
+//MigrateParam s.e.
+const MigrateParam = "cas.migrate"
+
+//SchemaAgreementParam s.e.
+const SchemaAgreementParam = "cas.schema-agreement"
+
+//PartitionStatsParam s.e.
+const PartitionStatsParam = "cas.partition-stats"
+
 //HTTPMethods s.e.
 var HTTPMethods = []string{"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS", "PATCH"}
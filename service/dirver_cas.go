@@ -5,23 +5,42 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
-	"time"
+	"io"
 
 	"github.com/gocql/gocql"
 )
 
 const LWRepeatCount = 10
 
+var casandraParams = append([]Param{
+	{Name: HostsParam, Attribute: HostsAttribute, Env: HostsEnvironmentProperty, Default: DefaultHost, Kind: KindList, Description: "hosts IPs separated with comma"},
+	{Name: KeyspaceParam, Attribute: KeyspaceAttribute, Env: KeyspaceEnvironmentProperty, Default: DefaultKeyspaceName, Description: "keyspace name"},
+	{Name: UserParam, Attribute: UserAttribute, Env: UserEnvironmentProperty, Description: "user login; enables password authentication"},
+	{Name: PasswordParam, Attribute: PasswordAttribute, Env: PasswordEnvironmentProperty, Secret: true, Description: "user password"},
+	{Name: ClassParam, Attribute: ClassAttribute, Env: ClassEnvironmentProperty, Default: DefaultClass, Kind: KindEnum, Values: []string{casandraSimpleStrategy, casandraNetworkTopologyStrategy}, Description: "strategy class"},
+	{Name: ReplicationFactorParam, Attribute: ReplicationFactorAttribute, Env: ReplicationFactorEnvironmentProperty, Default: fmt.Sprint(DefaultReplicationFactor), Kind: KindInt, Min: 1, Description: "replication factor of SimpleStrategy"},
+	{Name: ReplicationParam, Attribute: ReplicationAttribute, Env: ReplicationEnvironmentProperty, Description: "replication factor per datacenter of NetworkTopologyStrategy, e.g. dc1:3,dc2:2; default is <local dc>:<replication factor>"},
+	{Name: ConsistencyParam, Attribute: ConsistencyAttribute, Env: ConsistencyEnvironmentProperty, Default: "all", Kind: KindEnum, Values: []string{"any", "one", "two", "three", "quorum", "all", "lquorum", "equorum", "lone"}, Description: "consistency level"},
+	{Name: LightWeightTransactionParam, Attribute: LightWeightTransactionAttribute, Env: LightWeightTransactionEnvironmentProperty, Default: "0", Kind: KindInt, Min: 0, Max: 2, Description: "update mode: 0 - plain, 1 - versioned light weight transactions, 2 - conditional on weight"},
+}, append(append(casandraSecurityParams, casandraTuningParams...), casandraMigrationParams...)...)
+
+func init() {
+	RegisterDriver("cas", func(logger *Logger) DBDriver {
+		return &CasandraDriver{logger: logger}
+	}, "cassandra driver; one table partitioned by record key", casandraParams...)
+}
+
 //CasandraDriver s.e.
 type CasandraDriver struct {
 	cluster *gocql.ClusterConfig
-	session *gocql.Session
+	session *casandraSession
+
+	stmt casandraStatements
 
-	args map[string]string
+	cfg *Config
 
 	hosts             []string
 	keyspace          string
@@ -29,6 +48,9 @@ type CasandraDriver struct {
 	consistency       gocql.Consistency
 	replicationFactor int64
 	lightWeight       int64
+	ttls              viewTTLs
+	indexes           viewIndexes
+	index             *casandraIndex
 
 	logger *Logger
 
@@ -50,6 +72,9 @@ func (d *CasandraDriver) Info() string {
 	str += fmt.Sprintf("Consistency: %v\n", d.consistency)
 	str += fmt.Sprintf("Replication factor: %v\n", d.replicationFactor)
 	str += fmt.Sprintf("LightWeight mode: %v\n", d.lightWeight)
+	str += fmt.Sprintf("Partition stats: %v\n", d.stmt.stats)
+
+	str += casandraSecurityInfo(d.cfg)
 
 	str += "\n\n --- end --- \n\n"
 
@@ -57,8 +82,8 @@ func (d *CasandraDriver) Info() string {
 }
 
 //Init s.e.
-func (d *CasandraDriver) Init(args map[string]string) error {
-	d.args = args
+func (d *CasandraDriver) Init(cfg *Config) error {
+	d.cfg = cfg
 
 	err := d.initParams()
 
@@ -66,73 +91,35 @@ func (d *CasandraDriver) Init(args map[string]string) error {
 		return err
 	}
 
-	cluster := gocql.NewCluster(d.hosts...)
-	cluster.Keyspace = "system"
-
-	session, err := cluster.CreateSession()
-	defer session.Close()
+	scheme, err := loadOptionalScheme(cfg)
 
 	if err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	q := fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %v WITH replication = {'class': '%s', 'replication_factor' : %v}", d.keyspace, d.class, d.replicationFactor)
-	reconnectCount := 0
+	d.ttls = schemeTTLs(scheme)
+	d.indexes = schemeIndexes(scheme)
 
-	for {
-		if err = session.Query(q).Exec(); err != nil {
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			break
-		}
-
-		reconnectCount++
-
-		if reconnectCount >= MaxReconnectCount {
-			break
-		}
-	}
+	d.session, d.cluster, err = openCasandraSession(d.cfg, d.hosts, d.consistency, d.logger)
 
 	if err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	d.cluster = gocql.NewCluster(d.hosts...)
-	d.cluster.Consistency = d.consistency
-	d.cluster.Keyspace = d.keyspace
-
-	d.session, err = d.cluster.CreateSession()
+	m := newCasandraMigrator(d.session, d.cfg, casandraRecordsLayout, d.migrations(), d.logger)
 
-	if err != nil {
+	if err = m.start(d.cfg.String(MigrateParam)); err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	q = "CREATE TABLE IF NOT EXISTS records ( key text PRIMARY KEY, partition int, version int, values blob, type text, weight int )"
-	reconnectCount = 0
-
-	for {
-		if err := d.session.Query(q).Exec(); err != nil {
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			break
-		}
-
-		reconnectCount++
-
-		if reconnectCount >= MaxReconnectCount {
-			break
-		}
-	}
-
-	if err != nil {
-		d.logger.Error(err.Error())
-		return err
-	}
+	d.stmt = newCasandraStatements(d.keyspace, casandraRecordsTable, false)
+	d.stmt.stats = d.cfg.Bool(PartitionStatsParam)
+	d.index = newCasandraIndex(d.keyspace, casandraRecordsTable, d.indexes)
 
-	d.logger.Log("casandra driver initialized!")
+	d.logger.Info("casandra driver initialized!")
 
 	d.logger.Debug("Cassandra hosts: %v", d.hosts)
 	d.logger.Debug("Cassandra replication factor: %v", d.replicationFactor)
@@ -143,19 +130,40 @@ func (d *CasandraDriver) Init(args map[string]string) error {
 	return nil
 }
 
+//Migrate s.e.
+func (d *CasandraDriver) Migrate(cfg *Config, command string, dryRun bool, w io.Writer) error {
+	return migrateCasandra(cfg, casandraRecordsLayout, d.migrations(), command, dryRun, w, d.logger)
+}
+
+func (d *CasandraDriver) migrations() []casandraMigration {
+	return casandraRecordsMigrations(casandraRecordsTable, casandraLegacyRecordsTable, "((partition, type, key))")
+}
+
 //Free s.e.
 func (d *CasandraDriver) Free() error {
-	d.logger.Log("Casandra driver freed")
+	d.logger.Info("Casandra driver freed")
 
 	d.session.Close()
 
 	return nil
 }
 
+//Ping s.e.
+func (d *CasandraDriver) Ping(ctx context.Context) error {
+	return d.session.ping(ctx)
+}
+
+//Hosts s.e.
+func (d *CasandraDriver) Hosts() []HostStatus {
+	return d.session.hosts.list()
+}
+
 //Clean s.e.
 func (d *CasandraDriver) Clean(r *DBRequest) *DBResponse {
-	if err := d.session.Query(`TRUNCATE records;`).Exec(); err != nil {
-		return &DBResponse{Error: err.Error()}
+	for _, q := range []string{d.stmt.truncate, d.index.truncate, d.stmt.statsTruncate} {
+		if err := d.session.query(q).Exec(); err != nil {
+			return &DBResponse{Error: err.Error()}
+		}
 	}
 
 	return &DBResponse{Status: 200}
@@ -163,7 +171,12 @@ func (d *CasandraDriver) Clean(r *DBRequest) *DBResponse {
 
 //Read s.e.
 func (d *CasandraDriver) Read(r *DBRequest) *DBResponse {
-	var records []*Record
+	d = d.traced(r)
+
+	var (
+		records []*Record
+		saved   int64
+	)
 
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
@@ -180,12 +193,13 @@ func (d *CasandraDriver) Read(r *DBRequest) *DBResponse {
 			}
 
 			if rec != nil {
+				saved += projectRecord(rec, v.Fields)
 				records[i] = rec
 			}
 		}
 	}
 
-	return &DBResponse{Status: 200, Records: records}
+	return &DBResponse{Status: 200, Records: records, SavedBytes: saved}
 }
 
 func (d *CasandraDriver) read(partition int64, view *ViewView) (*Record, error) {
@@ -212,12 +226,16 @@ func (d *CasandraDriver) read(partition int64, view *ViewView) (*Record, error)
 
 //Insert s.e.
 func (d *CasandraDriver) Insert(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
 
 	if len(r.ViewMods) > 0 {
 		for _, v := range r.ViewMods {
+			d.logger.Request(r).Debug("insert request: %v", v)
+
 			err := d.insert(r.Partition, &v)
 
 			if err != nil {
@@ -230,8 +248,6 @@ func (d *CasandraDriver) Insert(r *DBRequest) *DBResponse {
 }
 
 func (d *CasandraDriver) insert(partition int64, view *ViewMod) error {
-	d.logger.Debug("insert request: %v", view)
-
 	if view.ViewType == "" {
 		return fmt.Errorf("record ViewType name malformed")
 	}
@@ -242,11 +258,21 @@ func (d *CasandraDriver) insert(partition int64, view *ViewMod) error {
 		return err
 	}
 
-	return d.set(key, partition, view.ViewType, view.Values)
+	ttl, err := d.ttls.ttl(view)
+
+	if err != nil {
+		return err
+	}
+
+	return casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, view.Values, ttl, func() error {
+		return d.set(key, partition, view.ViewType, view.Values, ttl)
+	})
 }
 
 //Update s.e.
 func (d *CasandraDriver) Update(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	var err error
 	var key string
 
@@ -263,17 +289,25 @@ func (d *CasandraDriver) Update(r *DBRequest) *DBResponse {
 				return &DBResponse{Status: 400, Error: err.Error()}
 			}
 
-			switch d.lightWeight {
-			case 2:
-				_, err = d.updLwL(key, r.Partition, v.ViewType, v.Values)
-			case 1:
-				_, err = d.updLw(key, r.Partition, v.ViewType, v.Values)
+			if err := checkTTL(v.TTL); err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+
+			if err := checkUpdate(&v); err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+
+			switch {
+			case len(v.Ops) > 0 || v.If != nil || d.lightWeight == 1:
+				err = casandraUpdateRecord(d.session, d.stmt, d.index, d.ttls, r.Partition, key, &v)
+			case d.lightWeight == 2:
+				_, err = d.updLwL(key, r.Partition, &v)
 			default:
-				_, err = d.upd(key, r.Partition, v.ViewType, v.Values)
+				_, err = d.upd(key, r.Partition, &v)
 			}
 
 			if err != nil {
-				return &DBResponse{Status: 400, Error: err.Error()}
+				return updateResponse(err)
 			}
 		}
 	}
@@ -283,12 +317,33 @@ func (d *CasandraDriver) Update(r *DBRequest) *DBResponse {
 
 //Scan s.e.
 func (d *CasandraDriver) Scan(r *DBRequest) *DBResponse {
-	// not implemented yet
-	return &DBResponse{Status: 200}
+	return casandraScan(d.session.traced(r), d.stmt, r)
+}
+
+//Increment s.e.
+func (d *CasandraDriver) Increment(r *DBRequest) *DBResponse {
+	return casandraIncrement(d.session.traced(r), d.stmt, d.index, d.ttls, r)
+}
+
+//Lookup s.e.
+func (d *CasandraDriver) Lookup(r *DBRequest) *DBResponse {
+	return casandraLookup(d.session.traced(r), d.stmt, d.index, r)
+}
+
+//PartitionStats s.e.
+func (d *CasandraDriver) PartitionStats(partition int64) (*PartitionStats, error) {
+	return casandraPartitionStats(d.session, d.stmt, partition)
+}
+
+//PartitionsStats s.e.
+func (d *CasandraDriver) PartitionsStats() ([]*PartitionStats, error) {
+	return casandraPartitionsStats(d.session, d.stmt, nil)
 }
 
 //Delete s.e.
 func (d *CasandraDriver) Delete(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
@@ -328,22 +383,21 @@ func (d *CasandraDriver) delete(key string, partition int64, vtype string) error
 
 	*/
 
-	if err := d.session.Query(`DELETE FROM records WHERE key = ? and partition = ? and type = ?`, key, partition, vtype).Exec(); err != nil {
-		return err
-	}
-
-	return nil
+	return casandraIndexedWrite(d.session, d.stmt, d.index, partition, vtype, key, nil, 0, func() error {
+		return d.session.idempotent(d.stmt.delete, partition, vtype, key).Exec()
+	})
 }
 
 func (d *CasandraDriver) get(key string, partition int64, vtype string) (*Record, error) {
 	var values []byte
 	var version int
+	var ttl int64
 
-	if err := d.session.Query(`SELECT values, version FROM records WHERE key = ?`, key).Scan(&values, &version); err != nil {
+	if err := d.session.idempotent(d.stmt.get, partition, vtype, key).Scan(&values, &version, &ttl); err != nil {
 		return nil, err
 	}
 
-	r := Record{Key: key, Version: version}
+	r := Record{Key: key, Version: version, TTL: ttl}
 
 	if err := json.Unmarshal(values, &r.Values); err != nil {
 		return nil, err
@@ -352,14 +406,14 @@ func (d *CasandraDriver) get(key string, partition int64, vtype string) (*Record
 	return &r, nil
 }
 
-func (d *CasandraDriver) set(key string, partition int64, vtype string, values map[string]interface{}) error {
+func (d *CasandraDriver) set(key string, partition int64, vtype string, values map[string]interface{}, ttl int64) error {
 	b, e := json.Marshal(values)
 
 	if e != nil {
 		return e
 	}
 
-	if err := d.session.Query(`INSERT INTO records (key, partition, version, type, values, weight) VALUES (?, ?, ?, ?, ?, ?)`, key, partition, 0, vtype, b, 0).Exec(); err != nil {
+	if err := d.session.idempotent(d.stmt.set, partition, vtype, key, 0, b, 0, ttl).Exec(); err != nil {
 		d.logger.Error("Set error %v", err.Error())
 		return err
 	}
@@ -367,199 +421,53 @@ func (d *CasandraDriver) set(key string, partition int64, vtype string, values m
 	return nil
 }
 
-func (d *CasandraDriver) upd(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	b, e := json.Marshal(values)
-
-	if e != nil {
-		return false, e
-	}
-
-	var q = d.session.Query(`UPDATE records SET version=?, values=? WHERE key = ?`, 0, b, key)
+func (d *CasandraDriver) upd(key string, partition int64, view *ViewMod) (bool, error) {
+	record, err := casandraPatchedRecord(d.session, d.stmt, d.ttls, partition, key, view)
 
-	if err := q.Exec(); err != nil {
+	if err != nil {
 		return false, err
 	}
 
-	return true, nil
-}
-
-func (d *CasandraDriver) updLw(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	repeatCount := 0
-	version := 0
-
-	for {
-		record, err := d.get(key, partition, vtype)
-
-		if err != nil {
-			return false, err
-		}
-
-		version = record.Version
-
-		for k, v := range values {
-			record.Values[k] = v
-		}
-
-		b, e := json.Marshal(values)
-
-		if e != nil {
-			return false, e
-		}
-
-		var q = d.session.Query(`UPDATE records SET version=?, values=? WHERE key = ? if version = ?`, version+1, b, key, version)
-
-		err = q.Exec()
-
-		if err != nil {
-			repeatCount++
-		} else {
-			break
-		}
-
-		if repeatCount >= LWRepeatCount {
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
-func (d *CasandraDriver) updLwL(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	b, e := json.Marshal(values)
+	b, e := json.Marshal(record.Values)
 
 	if e != nil {
 		return false, e
 	}
 
-	var q = d.session.Query(`UPDATE records SET values=? WHERE key = ? if weight = ?`, b, key, 0)
-
-	if err := q.Exec(); err != nil {
-		return false, err
-	}
-
-	return true, nil
-}
-
-func (d *CasandraDriver) initParams() error {
-	if err := d.initHosts(); err != nil {
-		return err
-	}
-
-	if err := d.initKeyspace(); err != nil {
-		return err
-	}
-
-	if err := d.initConsistensy(); err != nil {
-		return err
-	}
-
-	if err := d.initClass(); err != nil {
-		return err
-	}
-
-	if err := d.initReplicationFactor(); err != nil {
-		return err
-	}
-
-	if err := d.initLWTMode(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *CasandraDriver) initHosts() error {
-	if h, exists := os.LookupEnv(HostsEnvironmentProperty); exists {
-		hosts := strings.Split(strings.TrimSpace(h), ",")
-
-		if len(hosts) >= 1 {
-			d.hosts = hosts
-			return nil
-		}
-
-		return fmt.Errorf("environment variable %v malformed: string of IPs expected (comma as delimiter)", HostsEnvironmentProperty)
-	}
-
-	if h, exists := d.args[HostsAttribute]; exists {
-		hosts := strings.Split(strings.TrimSpace(h), ",")
-
-		if len(hosts) >= 1 {
-			d.hosts = hosts
-			return nil
-		}
-
-		return fmt.Errorf("attribute %v malformed: string of IPs expected (comma as delimiter)", HostsAttribute)
-	}
-
-	d.hosts = []string{DefaultHost}
+	err = casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, record.Values, record.TTL, func() error {
+		return d.session.idempotent(d.stmt.upd, record.TTL, record.Version, b, partition, view.ViewType, key).Exec()
+	})
 
-	return nil
+	return err == nil, err
 }
 
-func (d *CasandraDriver) initKeyspace() error {
-	if keyspace, exists := os.LookupEnv(KeyspaceEnvironmentProperty); exists {
-		if len(keyspace) > 0 {
-			d.keyspace = strings.TrimSpace(keyspace)
-			return nil
-		}
+func (d *CasandraDriver) updLwL(key string, partition int64, view *ViewMod) (bool, error) {
+	record, err := casandraPatchedRecord(d.session, d.stmt, d.ttls, partition, key, view)
 
-		return fmt.Errorf("environment variable %v malformed: not empty string expected", KeyspaceEnvironmentProperty)
-	}
-
-	if keyspace, exists := d.args[KeyspaceAttribute]; exists {
-		if len(keyspace) > 0 {
-			d.keyspace = strings.TrimSpace(keyspace)
-			return nil
-		}
-
-		return fmt.Errorf("argument %v malformed: not empty string expected", KeyspaceEnvironmentProperty)
-	}
-
-	d.keyspace = DefaultKeyspaceName
-
-	return nil
-}
-
-func (d *CasandraDriver) initConsistensy() error {
-	if con, exists := os.LookupEnv(ConsistencyEnvironmentProperty); exists {
-		d.consistency = d.getConsistency(con)
-		return nil
-	}
-
-	if con, exists := d.args[ConsistencyAttribute]; exists {
-		d.consistency = d.getConsistency(con)
-		return nil
+	if err != nil {
+		return false, err
 	}
 
-	d.consistency = gocql.All
-
-	return nil
-}
-
-func (d *CasandraDriver) initClass() error {
-	d.class = initStringParam(d.args, ClassEnvironmentProperty, ClassAttribute, DefaultClass)
+	b, e := json.Marshal(record.Values)
 
-	return nil
-}
-
-func (d *CasandraDriver) initReplicationFactor() error {
-	rf := initIntParam(d.args, ReplicationFactorEnvironmentProperty, ReplicationFactorAttribute, DefaultReplicationFactor)
-
-	if rf <= 0 {
-		rf = DefaultReplicationFactor
+	if e != nil {
+		return false, e
 	}
 
-	d.replicationFactor = rf
+	err = casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, record.Values, record.TTL, func() error {
+		return d.session.query(d.stmt.updLwL, record.TTL, b, partition, view.ViewType, key, 0).Exec()
+	})
 
-	return nil
+	return err == nil, err
 }
 
-func (d *CasandraDriver) initLWTMode() error {
-	lwt := initIntParam(d.args, LightWeightTransactionEnvironmentProperty, LightWeightTransactionAttribute, 0)
-
-	if lwt >= 0 && lwt <= 2 {
-		d.lightWeight = lwt
-	}
+func (d *CasandraDriver) initParams() error {
+	d.hosts = d.cfg.List(HostsParam)
+	d.keyspace = d.cfg.String(KeyspaceParam)
+	d.consistency = d.getConsistency(d.cfg.String(ConsistencyParam))
+	d.class = d.cfg.String(ClassParam)
+	d.replicationFactor = d.cfg.Int(ReplicationFactorParam)
+	d.lightWeight = d.cfg.Int(LightWeightTransactionParam)
 
 	return nil
 }
@@ -588,3 +496,17 @@ func (d *CasandraDriver) getConsistency(s string) gocql.Consistency {
 		return gocql.All
 	}
 }
+
+//traced returns the driver whose queries are traced within the span of the request, if any
+func (d *CasandraDriver) traced(r *DBRequest) *CasandraDriver {
+	session := d.session.traced(r)
+
+	if session == d.session {
+		return d
+	}
+
+	c := *d
+	c.session = session
+
+	return &c
+}
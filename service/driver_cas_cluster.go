@@ -0,0 +1,434 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Cluster settings shared by cassandra drivers
+
+var casandraSecurityParams = []Param{
+	{Name: TLSParam, Attribute: TLSAttribute, Env: TLSEnvironmentProperty, Default: "false", Kind: KindBool, Description: "enables client TLS; implied when any of TLS files is given"},
+	{Name: TLSCAParam, Attribute: TLSCAAttribute, Env: TLSCAEnvironmentProperty, Description: "CA certificate file (PEM) to verify cassandra nodes"},
+	{Name: TLSCertParam, Attribute: TLSCertAttribute, Env: TLSCertEnvironmentProperty, Description: "client certificate file (PEM)"},
+	{Name: TLSKeyParam, Attribute: TLSKeyAttribute, Env: TLSKeyEnvironmentProperty, Description: "client private key file (PEM)"},
+	{Name: TLSVerifyHostParam, Attribute: TLSVerifyHostAttribute, Env: TLSVerifyHostEnvironmentProperty, Default: "true", Kind: KindBool, Description: "verify that node certificate matches its host"},
+}
+
+var casandraTuningParams = []Param{
+	{Name: LocalDCParam, Attribute: LocalDCAttribute, Env: LocalDCEnvironmentProperty, Description: "local datacenter; enables DC aware host selection"},
+	{Name: PoolSizeParam, Attribute: PoolSizeAttribute, Env: PoolSizeEnvironmentProperty, Default: "2", Kind: KindInt, Min: 1, Description: "connections per host"},
+	{Name: TimeoutParam, Attribute: TimeoutAttribute, Env: TimeoutEnvironmentProperty, Default: "600ms", Kind: KindDuration, Description: "query timeout"},
+	{Name: ConnectTimeoutParam, Attribute: ConnectTimeoutAttribute, Env: ConnectTimeoutEnvironmentProperty, Default: "600ms", Kind: KindDuration, Description: "connection timeout"},
+	{Name: CompressionParam, Attribute: CompressionAttribute, Env: CompressionEnvironmentProperty, Default: "none", Kind: KindEnum, Values: []string{"none", "snappy"}, Description: "frame compression"},
+	{Name: RetriesParam, Attribute: RetriesAttribute, Env: RetriesEnvironmentProperty, Default: "0", Kind: KindInt, Description: "retries of a failed query"},
+	{Name: SpeculativeAttemptsParam, Attribute: SpeculativeAttemptsAttribute, Env: SpeculativeAttemptsEnvironmentProperty, Default: "0", Kind: KindInt, Description: "speculative executions of a slow idempotent query; 0 disables"},
+	{Name: SpeculativeDelayParam, Attribute: SpeculativeDelayAttribute, Env: SpeculativeDelayEnvironmentProperty, Default: "100ms", Kind: KindDuration, Description: "delay before a speculative execution"},
+	{Name: PartitionStatsParam, Attribute: PartitionStatsAttribute, Env: PartitionStatsEnvironmentProperty, Default: "false", Kind: KindBool, Description: "track partition statistics in counter tables; costs reads before and after writes"},
+}
+
+//casandraSession applies settings which gocql configures per query rather than per cluster
+type casandraSession struct {
+	*gocql.Session
+
+	spec        gocql.SpeculativeExecutionPolicy
+	replication *casandraReplication
+	hosts       *casandraHosts
+
+	//ctx is given to queries, so that casandraQueryObserver traces them within the request span
+	ctx context.Context
+}
+
+//idempotent returns query which is safe to retry and speculatively execute
+func (s *casandraSession) idempotent(stmt string, values ...interface{}) *gocql.Query {
+	return s.query(stmt, values...).Idempotent(true).SetSpeculativeExecutionPolicy(s.spec)
+}
+
+//query returns query of the session context
+func (s *casandraSession) query(stmt string, values ...interface{}) *gocql.Query {
+	q := s.Query(stmt, values...)
+
+	if s.ctx != nil {
+		q = q.WithContext(s.ctx)
+	}
+
+	return q
+}
+
+//traced returns the session giving context of the request to queries, if the request is traced
+func (s *casandraSession) traced(r *DBRequest) *casandraSession {
+	if r == nil || spanFromContext(r.Context()) == nil {
+		return s
+	}
+
+	c := *s
+	c.ctx = r.Context()
+
+	return &c
+}
+
+//ping queries the local node table of a node
+func (s *casandraSession) ping(ctx context.Context) error {
+	if s == nil || s.Closed() {
+		return fmt.Errorf("cassandra session is closed")
+	}
+
+	var version string
+
+	return s.Query("SELECT release_version FROM system.local").WithContext(ctx).Consistency(gocql.One).Scan(&version)
+}
+
+//casandraHosts keeps the result of the latest connection to each host; gocql calls it on every dial
+type casandraHosts struct {
+	mu    sync.Mutex
+	hosts map[string]*casandraHost
+}
+
+type casandraHost struct {
+	info   *gocql.HostInfo
+	status HostStatus
+}
+
+func newCasandraHosts() *casandraHosts {
+	return &casandraHosts{hosts: map[string]*casandraHost{}}
+}
+
+func (h *casandraHosts) ObserveConnect(c gocql.ObservedConnect) {
+	if c.Host == nil {
+		return
+	}
+
+	addr := c.Host.ConnectAddress().String()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	host, ok := h.hosts[addr]
+
+	if !ok {
+		host = &casandraHost{status: HostStatus{Host: addr}}
+		h.hosts[addr] = host
+	}
+
+	host.info = c.Host
+	host.status.LastConnect = c.End
+	host.status.ConnectNs = c.End.Sub(c.Start).Nanoseconds()
+	host.status.Connects++
+	host.status.LastError = ""
+
+	if c.Err != nil {
+		host.status.Failures++
+		host.status.LastError = c.Err.Error()
+	}
+}
+
+//list returns hosts sorted by address; a host is up when gocql does not consider it down and its latest
+//connection succeeded
+func (h *casandraHosts) list() []HostStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	list := make([]HostStatus, 0, len(h.hosts))
+
+	for _, host := range h.hosts {
+		status := host.status
+		status.DC = host.info.DataCenter()
+		status.Rack = host.info.Rack()
+
+		if v := host.info.Version(); v.Major > 0 {
+			status.Version = v.String()
+		}
+
+		status.Up = host.info.IsUp() && status.LastError == ""
+		list = append(list, status)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+
+	return list
+}
+
+//casandraQueryObserver records a span of each query of traced requests, retries and pages included
+type casandraQueryObserver struct{}
+
+func (casandraQueryObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	parent := spanFromContext(ctx)
+
+	if parent == nil {
+		return
+	}
+
+	name := "cassandra"
+
+	if words := strings.Fields(q.Statement); len(words) > 0 {
+		name += " " + strings.ToUpper(words[0])
+	}
+
+	s := parent.child(name)
+	s.Start = q.Start
+	s.set("db.system", "cassandra")
+	s.set("db.statement", q.Statement)
+	s.set("db.cassandra.keyspace", q.Keyspace)
+	s.set("db.cassandra.attempt", q.Attempt)
+	s.set("db.cassandra.rows", q.Rows)
+
+	if q.Host != nil {
+		s.set("net.peer.name", q.Host.ConnectAddress().String())
+	}
+
+	if q.Err != nil {
+		s.fail(q.Err.Error())
+	}
+
+	s.finishAt(q.End)
+}
+
+//openCasandraSession connects to the cluster without keyspace, so that one session serves both
+//keyspace DDL and data queries; statements should use qualified table names.
+//gocql prepares every statement with bind markers on first use and caches it for the session.
+func openCasandraSession(cfg *Config, hosts []string, consistency gocql.Consistency, logger *Logger) (*casandraSession, *gocql.ClusterConfig, error) {
+	replication, err := newCasandraReplication(cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	localDC := replication.localDC(cfg)
+
+	if casandraLocalConsistency(consistency) && localDC == "" {
+		return nil, nil, fmt.Errorf("consistency %v is evaluated in the local datacenter; give %v or %v %v", consistency, LocalDCAttribute, casandraNetworkTopologyStrategy, ReplicationAttribute)
+	}
+
+	cluster, err := newCasandraCluster(cfg, hosts, localDC)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cluster.Consistency = consistency
+
+	hostsObserver := newCasandraHosts()
+	cluster.ConnectObserver = hostsObserver
+
+	var session *gocql.Session
+
+	for reconnectCount := 0; ; reconnectCount++ {
+		if session, err = cluster.CreateSession(); err == nil {
+			break
+		}
+
+		if reconnectCount >= MaxReconnectCount {
+			return nil, nil, err
+		}
+
+		logger.Debug("Cassandra is not available yet: %v", err)
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	s := &casandraSession{Session: session, spec: &gocql.NonSpeculativeExecution{}, replication: replication, hosts: hostsObserver}
+
+	if attempts := cfg.Int(SpeculativeAttemptsParam); attempts > 0 {
+		s.spec = &gocql.SimpleSpeculativeExecution{
+			NumAttempts:  int(attempts),
+			TimeoutDelay: cfg.Duration(SpeculativeDelayParam),
+		}
+	}
+
+	return s, cluster, nil
+}
+
+const (
+	casandraRecordsTable                  = "records_v2"
+	casandraPartitionedRecordsTable       = "records_p_v2"
+	casandraLegacyRecordsTable            = "records"
+	casandraLegacyPartitionedRecordsTable = "records_p"
+)
+
+//casandraStatements holds CQL of a driver; built once on Init with qualified table names
+type casandraStatements struct {
+	get      string
+	set      string
+	setNx    string
+	upd      string
+	updLw    string
+	updLwL   string
+	delete   string
+	truncate string
+
+	// scan reads records of a view from a key in order of keys; empty when key is a part of the partition
+	// key, as the range would filter all the table
+	scan string
+
+	// stats tells partition statistics are tracked in counter table <table>_stats
+	stats         bool
+	statsAdd      string
+	statsGet      string
+	statsAll      string
+	statsTruncate string
+}
+
+func newCasandraStatements(keyspace, table string, ordered bool) casandraStatements {
+	t := keyspace + "." + table
+	where := "WHERE partition = ? AND type = ? AND key = ?"
+	st := keyspace + "." + casandraStatsTable(table)
+	scan := ""
+
+	if ordered {
+		scan = fmt.Sprintf("SELECT key, values, version, TTL(values) FROM %v WHERE partition = ? AND type = ? AND key >= ?", t)
+	}
+
+	return casandraStatements{
+		get:      fmt.Sprintf("SELECT values, version, TTL(values) FROM %v %v", t, where),
+		set:      fmt.Sprintf("INSERT INTO %v (partition, type, key, version, values, weight) VALUES (?, ?, ?, ?, ?, ?) USING TTL ?", t),
+		setNx:    fmt.Sprintf("INSERT INTO %v (partition, type, key, version, values, weight) VALUES (?, ?, ?, ?, ?, ?) IF NOT EXISTS USING TTL ?", t),
+		upd:      fmt.Sprintf("UPDATE %v USING TTL ? SET version = ?, values = ? %v", t, where),
+		updLw:    fmt.Sprintf("UPDATE %v USING TTL ? SET version = ?, values = ? %v IF version = ?", t, where),
+		updLwL:   fmt.Sprintf("UPDATE %v USING TTL ? SET values = ? %v IF weight = ?", t, where),
+		delete:   fmt.Sprintf("DELETE FROM %v %v", t, where),
+		truncate: fmt.Sprintf("TRUNCATE %v", t),
+		scan:     scan,
+
+		statsAdd:      fmt.Sprintf("UPDATE %v SET records = records + ?, bytes = bytes + ? WHERE partition = ? AND type = ?", st),
+		statsGet:      fmt.Sprintf("SELECT partition, type, records, bytes FROM %v WHERE partition = ?", st),
+		statsAll:      fmt.Sprintf("SELECT partition, type, records, bytes FROM %v", st),
+		statsTruncate: fmt.Sprintf("TRUNCATE %v", st),
+	}
+}
+
+//casandraCopyColumns are columns of legacy records tables, copied into the same columns of the new tables;
+//legacy partition is int, it widens to bigint
+const casandraCopyColumns = "partition, type, key, version, values, weight"
+
+//casandraCopyStatements returns the select of legacy rows and the insert of them into the new table
+func casandraCopyStatements(from, to string) (string, string) {
+	return fmt.Sprintf("SELECT %v FROM %v", casandraCopyColumns, from),
+		fmt.Sprintf("INSERT INTO %v (%v) VALUES (?, ?, ?, ?, ?, ?)", to, casandraCopyColumns)
+}
+
+func copyCasandraRecords(session *casandraSession, from, to string, logger *Logger) error {
+	var (
+		key, vtype      string
+		partition       int64
+		version, weight int
+		values          []byte
+		count           int
+	)
+
+	logger.Info("Copying records from legacy table %v to %v", from, to)
+
+	selectRows, insert := casandraCopyStatements(from, to)
+	iter := session.Query(selectRows).Iter()
+
+	for iter.Scan(&partition, &vtype, &key, &version, &values, &weight) {
+		if err := session.idempotent(insert, partition, vtype, key, version, values, weight).Exec(); err != nil {
+			iter.Close()
+			return err
+		}
+
+		count++
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("%v records copied to %v", count, to)
+
+	return nil
+}
+
+//newCasandraCluster routes queries token aware; with local datacenter replicas of that datacenter
+//are preferred, so that local consistency levels are coordinated there
+func newCasandraCluster(cfg *Config, hosts []string, localDC string) (*gocql.ClusterConfig, error) {
+	cluster := gocql.NewCluster(hosts...)
+
+	cluster.NumConns = int(cfg.Int(PoolSizeParam))
+	cluster.Timeout = cfg.Duration(TimeoutParam)
+	cluster.ConnectTimeout = cfg.Duration(ConnectTimeoutParam)
+	cluster.MaxWaitSchemaAgreement = cfg.Duration(SchemaAgreementParam)
+	cluster.QueryObserver = casandraQueryObserver{}
+
+	if localDC != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(localDC))
+	} else {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	}
+
+	if cfg.String(CompressionParam) == "snappy" {
+		cluster.Compressor = &gocql.SnappyCompressor{}
+	}
+
+	if retries := cfg.Int(RetriesParam); retries > 0 {
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: int(retries)}
+	}
+
+	if user := cfg.String(UserParam); user != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: user,
+			Password: cfg.String(PasswordParam),
+		}
+	} else if cfg.String(PasswordParam) != "" {
+		return nil, fmt.Errorf("%v is given without %v", PasswordAttribute, UserAttribute)
+	}
+
+	if casandraTLSEnabled(cfg) {
+		cert, key := cfg.String(TLSCertParam), cfg.String(TLSKeyParam)
+
+		if (cert == "") != (key == "") {
+			return nil, fmt.Errorf("%v and %v should be given together", TLSCertAttribute, TLSKeyAttribute)
+		}
+
+		cluster.SslOpts = &gocql.SslOptions{
+			CaPath:                 cfg.String(TLSCAParam),
+			CertPath:               cert,
+			KeyPath:                key,
+			EnableHostVerification: cfg.Bool(TLSVerifyHostParam),
+		}
+	}
+
+	return cluster, nil
+}
+
+func casandraTLSEnabled(cfg *Config) bool {
+	return cfg.Bool(TLSParam) || cfg.String(TLSCAParam) != "" || cfg.String(TLSCertParam) != "" || cfg.String(TLSKeyParam) != ""
+}
+
+func casandraSecurityInfo(cfg *Config) string {
+	str := ""
+
+	if r, err := newCasandraReplication(cfg); err == nil {
+		str += fmt.Sprintf("Replication: %v\n", r)
+		str += fmt.Sprintf("Local DC: %q\n", r.localDC(cfg))
+	}
+
+	str += fmt.Sprintf("Pool size: %v, timeout: %v, connect timeout: %v, compression: %v\n",
+		cfg.Int(PoolSizeParam), cfg.Duration(TimeoutParam), cfg.Duration(ConnectTimeoutParam), cfg.String(CompressionParam))
+	str += fmt.Sprintf("Retries: %v, speculative executions: %v after %v\n",
+		cfg.Int(RetriesParam), cfg.Int(SpeculativeAttemptsParam), cfg.Duration(SpeculativeDelayParam))
+
+	if user := cfg.String(UserParam); user != "" {
+		str += fmt.Sprintf("User: %v\n", user)
+		str += fmt.Sprintf("Password: %v\n", redacted)
+	} else {
+		str += "Authentication: none\n"
+	}
+
+	if casandraTLSEnabled(cfg) {
+		str += fmt.Sprintf("TLS: on; CA: %q, certificate: %q, key: %q, host verification: %v\n",
+			cfg.String(TLSCAParam), cfg.String(TLSCertParam), cfg.String(TLSKeyParam), cfg.Bool(TLSVerifyHostParam))
+	} else {
+		str += "TLS: off\n"
+	}
+
+	return str
+}
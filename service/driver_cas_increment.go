@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+//casandraIncrement serves Increment of cas and casp drivers
+func casandraIncrement(session *casandraSession, stmt casandraStatements, x *casandraIndex, ttls viewTTLs, r *DBRequest) *DBResponse {
+	if err := checkIncrement(r); err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	records := make([]*Record, len(r.ViewMods))
+
+	for i, v := range r.ViewMods {
+		key, err := buildKey(v.PartitionKey, v.ClusterKey)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		if err := checkTTL(v.TTL); err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		if records[i], err = casandraIncrementRecord(session, stmt, x, ttls, r.Partition, key, &v); err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records}
+}
+
+//casandraIncrementRecord adds deltas (view values) to the fields of a blob record; see casandraModifyRecord
+func casandraIncrementRecord(session *casandraSession, stmt casandraStatements, x *casandraIndex, ttls viewTTLs, partition int64, key string, view *ViewMod) (*Record, error) {
+	return casandraModifyRecord(session, stmt, x, ttls, partition, key, view, func(values map[string]interface{}, exists bool) (map[string]interface{}, error) {
+		return incrementValues(values, view.Values)
+	})
+}
+
+//casandraModifyRecord changes blob record with light weight transactions: record is read, modified and
+//written back on condition its version is not changed (or inserted if not exists); attempts are repeated
+//on concurrent changes. Writes which are not light weight do not change version, so concurrent plain
+//updates of the record may be lost. Modify returns values for the response record. The record keeps its
+//remaining TTL unless the view gives one, see viewTTLs.modifyTTL. Index entries of the new values are
+//written before the record, stale ones are deleted after it is written; partition statistics are
+//counted after the write.
+func casandraModifyRecord(session *casandraSession, stmt casandraStatements, x *casandraIndex, ttls viewTTLs, partition int64, key string, view *ViewMod, modify func(values map[string]interface{}, exists bool) (map[string]interface{}, error)) (*Record, error) {
+	vtype := view.ViewType
+
+	for attempt := 0; attempt < LWRepeatCount; attempt++ {
+		var (
+			b         []byte
+			version   int
+			remaining int64
+			applied   bool
+		)
+
+		values := map[string]interface{}{}
+		err := session.query(stmt.get, partition, vtype, key).Scan(&b, &version, &remaining)
+		exists := err == nil
+
+		if err != nil && err != gocql.ErrNotFound {
+			return nil, err
+		}
+
+		if len(b) > 0 {
+			if err := json.Unmarshal(b, &values); err != nil {
+				return nil, err
+			}
+		}
+
+		old, size := x.indexes.values(vtype, values), int64(len(b))
+		result, err := modify(values, exists)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ttl, err := ttls.modifyTTL(view, exists, remaining)
+
+		if err != nil {
+			return nil, err
+		}
+
+		encoded := x.indexes.values(vtype, values)
+
+		if err := x.write(session, partition, vtype, key, encoded, ttl); err != nil {
+			return nil, err
+		}
+
+		if b, err = json.Marshal(values); err != nil {
+			return nil, err
+		}
+
+		if exists {
+			version++
+			applied, err = session.query(stmt.updLw, ttl, version, b, partition, vtype, key, version-1).MapScanCAS(map[string]interface{}{})
+		} else {
+			applied, err = session.query(stmt.setNx, partition, vtype, key, version, b, 0, ttl).MapScanCAS(map[string]interface{}{})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if applied {
+			if err := casandraCountWrite(session, stmt, partition, vtype, exists, size, values); err != nil {
+				return nil, err
+			}
+
+			return &Record{Key: key, Values: result, Version: version, TTL: ttl}, x.clean(session, partition, vtype, key, old, encoded)
+		}
+	}
+
+	return nil, fmt.Errorf("record %v in partition %v view %v is changed concurrently; it is not modified in %v attempts", key, partition, vtype, LWRepeatCount)
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+//casandraIndex maintains the index table of cas and casp layouts: an entry per indexed field value of
+//a record. Entries of new values are written before the record and stale entries are deleted after it,
+//so lookups do not miss records; entries left by failed writes are skipped by lookups, which check
+//the found records.
+type casandraIndex struct {
+	indexes  viewIndexes
+	insert   string
+	delete   string
+	lookup   string
+	truncate string
+}
+
+func casandraIndexTable(table string) string {
+	return table + "_index"
+}
+
+func newCasandraIndex(keyspace, table string, indexes viewIndexes) *casandraIndex {
+	t := keyspace + "." + casandraIndexTable(table)
+
+	return &casandraIndex{
+		indexes:  indexes,
+		insert:   fmt.Sprintf("INSERT INTO %v (partition, type, field, value, key) VALUES (?, ?, ?, ?, ?) USING TTL ?", t),
+		delete:   fmt.Sprintf("DELETE FROM %v WHERE partition = ? AND type = ? AND field = ? AND value = ? AND key = ?", t),
+		lookup:   fmt.Sprintf("SELECT key FROM %v WHERE partition = ? AND type = ? AND field = ? AND value = ?", t),
+		truncate: fmt.Sprintf("TRUNCATE %v", t),
+	}
+}
+
+//write adds index entries of the values; entries live as long as the written record
+func (x *casandraIndex) write(session *casandraSession, partition int64, vtype, key string, values map[string]string, ttl int64) error {
+	for f, v := range values {
+		if err := session.idempotent(x.insert, partition, vtype, f, v, key, ttl).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//clean deletes index entries of old values which the record does not have any more
+func (x *casandraIndex) clean(session *casandraSession, partition int64, vtype, key string, old, values map[string]string) error {
+	for f, v := range old {
+		if values[f] == v {
+			continue
+		}
+
+		if err := session.idempotent(x.delete, partition, vtype, f, v, key).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//casandraIndexedWrite makes a write of the record (nil values for deletes) and maintains its index entries
+//and partition statistics; the stored record is read for them only
+func casandraIndexedWrite(session *casandraSession, stmt casandraStatements, x *casandraIndex, partition int64, vtype, key string, values map[string]interface{}, ttl int64, write func() error) error {
+	var (
+		old     map[string]string
+		existed bool
+		size    int64
+	)
+
+	if len(x.indexes[vtype]) > 0 || stmt.stats {
+		current, b, err := casandraReadRecord(session, stmt, partition, vtype, key)
+
+		if err != nil {
+			return err
+		}
+
+		old, existed, size = x.indexes.values(vtype, current), b != nil, int64(len(b))
+	}
+
+	encoded := x.indexes.values(vtype, values)
+
+	if err := x.write(session, partition, vtype, key, encoded, ttl); err != nil {
+		return err
+	}
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	if err := casandraCountWrite(session, stmt, partition, vtype, existed, size, values); err != nil {
+		return err
+	}
+
+	return x.clean(session, partition, vtype, key, old, encoded)
+}
+
+//casandraReadRecord reads record values and the blob; nil blob for missing record
+func casandraReadRecord(session *casandraSession, stmt casandraStatements, partition int64, vtype, key string) (map[string]interface{}, []byte, error) {
+	var (
+		b         []byte
+		version   int
+		remaining int64
+	)
+
+	values := map[string]interface{}{}
+	err := session.idempotent(stmt.get, partition, vtype, key).Scan(&b, &version, &remaining)
+
+	if err == gocql.ErrNotFound {
+		return values, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b == nil {
+		b = []byte{}
+	}
+
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return values, b, nil
+}
+
+//casandraLookup serves Lookup of cas and casp drivers: a page of index entries is read and records
+//are checked, so a page may hold less records than the limit
+func casandraLookup(session *casandraSession, stmt casandraStatements, x *casandraIndex, r *DBRequest) *DBResponse {
+	view, err := checkLookup(r, x.indexes)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	pageState, err := decodePageState(view.PageState)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	value, _ := indexValue(view.IndexValue)
+
+	// setting page state (even empty) turns automatic paging off, so one page is read
+	iter := session.idempotent(x.lookup, r.Partition, view.ViewType, view.Index, value).PageSize(pageLimit(view)).PageState(pageState).Iter()
+	next := iter.PageState()
+
+	var (
+		keys    []string
+		key     string
+		records []*Record
+		saved   int64
+	)
+
+	for iter.Scan(&key) {
+		keys = append(keys, key)
+	}
+
+	if err := iter.Close(); err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	for _, k := range keys {
+		var (
+			b       []byte
+			version int
+			ttl     int64
+		)
+
+		err := session.idempotent(stmt.get, r.Partition, view.ViewType, k).Scan(&b, &version, &ttl)
+
+		if err == gocql.ErrNotFound {
+			continue
+		}
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		rec := &Record{Key: k, Values: map[string]interface{}{}, Version: version, TTL: ttl}
+
+		if len(b) > 0 {
+			if err := json.Unmarshal(b, &rec.Values); err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+		}
+
+		if ok, s := lookupMatch(rec, view); ok {
+			saved += s
+			records = append(records, rec)
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records, PageState: encodePageState(next), SavedBytes: saved}
+}
@@ -0,0 +1,386 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Versioned schema migrations of cassandra drivers
+
+var casandraMigrationParams = []Param{
+	{Name: MigrateParam, Attribute: MigrateAttribute, Env: MigrateEnvironmentProperty, Default: MigrateAuto, Kind: KindEnum, Values: []string{MigrateAuto, MigrateCheck}, Description: "schema migrations on start: auto - apply pending ones, check - refuse to start while some are pending"},
+	{Name: SchemaAgreementParam, Attribute: SchemaAgreementAttribute, Env: SchemaAgreementEnvironmentProperty, Default: "60s", Kind: KindDuration, Description: "max wait for nodes to agree on the schema after a change"},
+}
+
+const (
+	//MigrateAuto applies pending migrations when driver starts
+	MigrateAuto = "auto"
+	//MigrateCheck fails driver start while there are pending migrations
+	MigrateCheck = "check"
+)
+
+const casandraMigrationsTable = "schema_migrations"
+
+const (
+	casandraRecordsLayout            = "records"
+	casandraPartitionedRecordsLayout = "records_p"
+)
+
+//casandraMigration is a step of a layout schema; CQL statements are applied in order, then run (data
+//steps) if given. Statements take the keyspace as %[1]v and should be repeatable (IF NOT EXISTS), since
+//a step interrupted before it is recorded is applied again.
+type casandraMigration struct {
+	version int
+	name    string
+	cql     []string
+	run     func(m *casandraMigrator) error
+	runInfo string
+}
+
+//casandraMigrator applies migrations of one layout (table set) and records them in schema_migrations
+type casandraMigrator struct {
+	session    *casandraSession
+	keyspace   string
+	layout     string
+	migrations []casandraMigration
+	agreement  time.Duration
+	logger     *Logger
+}
+
+type casandraAppliedMigration struct {
+	name      string
+	checksum  int64
+	appliedAt time.Time
+}
+
+func newCasandraMigrator(session *casandraSession, cfg *Config, layout string, migrations []casandraMigration, logger *Logger) *casandraMigrator {
+	return &casandraMigrator{
+		session:    session,
+		keyspace:   cfg.String(KeyspaceParam),
+		layout:     layout,
+		migrations: migrations,
+		agreement:  cfg.Duration(SchemaAgreementParam),
+		logger:     logger,
+	}
+}
+
+//start prepares schema on driver Init according to the migrate mode
+func (m *casandraMigrator) start(mode string) error {
+	if mode == MigrateCheck {
+		pending, err := m.pending()
+
+		if err != nil {
+			return err
+		}
+
+		if len(pending) > 0 {
+			return fmt.Errorf("%v schema migrations of %v are pending (first: %v %v); run 'migrate %v'", len(pending), m.layout, pending[0].version, pending[0].name, MigrateUp)
+		}
+
+		return nil
+	}
+
+	return m.up(false, nil)
+}
+
+//up applies pending migrations; in dry run statements are written to w instead
+func (m *casandraMigrator) up(dryRun bool, w io.Writer) error {
+	if err := m.ensureKeyspace(dryRun, w); err != nil {
+		return err
+	}
+
+	if err := m.exec(dryRun, w, m.bookkeepingDDL()); err != nil {
+		return err
+	}
+
+	pending, err := m.pending()
+
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 && w != nil {
+		fmt.Fprintf(w, "%v: schema is up to date\n", m.layout)
+	}
+
+	for _, mg := range pending {
+		if dryRun {
+			fmt.Fprintf(w, "-- %v %v: %v\n", m.layout, mg.version, mg.name)
+		} else {
+			m.logger.Info("Applying schema migration %v %v: %v", m.layout, mg.version, mg.name)
+		}
+
+		for _, stmt := range mg.cql {
+			if err := m.exec(dryRun, w, fmt.Sprintf(stmt, m.keyspace)); err != nil {
+				return fmt.Errorf("migration %v %v: %v", m.layout, mg.version, err)
+			}
+		}
+
+		if mg.run != nil {
+			if dryRun {
+				fmt.Fprintf(w, "-- data step: %v\n", mg.runInfo)
+			} else if err := mg.run(m); err != nil {
+				return fmt.Errorf("migration %v %v: %v", m.layout, mg.version, err)
+			}
+		}
+
+		if dryRun {
+			continue
+		}
+
+		q := fmt.Sprintf("INSERT INTO %v.%v (layout, version, name, checksum, applied_at) VALUES (?, ?, ?, ?, ?)", m.keyspace, casandraMigrationsTable)
+
+		if err := m.session.Query(q, m.layout, mg.version, mg.name, mg.checksum(), time.Now()).Exec(); err != nil {
+			return err
+		}
+
+		if w != nil {
+			fmt.Fprintf(w, "%v %v: %v applied\n", m.layout, mg.version, mg.name)
+		}
+	}
+
+	return nil
+}
+
+//status writes every known migration with its state; edited migrations are reported by checksum
+func (m *casandraMigrator) status(w io.Writer) error {
+	applied, err := m.applied()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Keyspace: %v, layout: %v\n", m.keyspace, m.layout)
+
+	if ks, err := m.session.KeyspaceMetadata(m.keyspace); err == nil && !m.session.replication.matches(ks) {
+		fmt.Fprintf(w, "Replication: %v, configured %v\n", describeCasandraReplication(ks), m.session.replication)
+	}
+
+	for _, mg := range m.migrations {
+		a, ok := applied[mg.version]
+
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "  %3d %-40v pending\n", mg.version, mg.name)
+		case a.checksum != mg.checksum():
+			fmt.Fprintf(w, "  %3d %-40v applied %v; changed since\n", mg.version, mg.name, a.appliedAt.Format(time.RFC3339))
+		default:
+			fmt.Fprintf(w, "  %3d %-40v applied %v\n", mg.version, mg.name, a.appliedAt.Format(time.RFC3339))
+		}
+
+		delete(applied, mg.version)
+	}
+
+	for v, a := range applied {
+		fmt.Fprintf(w, "  %3d %-40v applied %v; unknown to this build\n", v, a.name, a.appliedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (m *casandraMigrator) pending() ([]casandraMigration, error) {
+	applied, err := m.applied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []casandraMigration
+
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.version]; !ok {
+			pending = append(pending, mg)
+		}
+	}
+
+	return pending, nil
+}
+
+//applied reads bookkeeping table; missing keyspace or table means nothing is applied
+func (m *casandraMigrator) applied() (map[int]casandraAppliedMigration, error) {
+	applied := map[int]casandraAppliedMigration{}
+
+	if exists, err := m.tableExists(casandraMigrationsTable); err != nil || !exists {
+		return applied, err
+	}
+
+	q := fmt.Sprintf("SELECT version, name, checksum, applied_at FROM %v.%v WHERE layout = ?", m.keyspace, casandraMigrationsTable)
+	iter := m.session.Query(q, m.layout).Iter()
+
+	var (
+		version int
+		a       casandraAppliedMigration
+	)
+
+	for iter.Scan(&version, &a.name, &a.checksum, &a.appliedAt) {
+		applied[version] = a
+	}
+
+	return applied, iter.Close()
+}
+
+func (m *casandraMigrator) tableExists(table string) (bool, error) {
+	ks, err := m.session.KeyspaceMetadata(m.keyspace)
+
+	if err == gocql.ErrKeyspaceDoesNotExist {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	_, exists := ks.Tables[table]
+
+	return exists, nil
+}
+
+//exec applies schema statement and waits until all nodes see the change, so next statements
+//and metadata reads do not race with schema propagation
+func (m *casandraMigrator) exec(dryRun bool, w io.Writer, stmt string) error {
+	if dryRun {
+		fmt.Fprintf(w, "%v;\n", stmt)
+		return nil
+	}
+
+	m.logger.Debug("Schema: %v", stmt)
+
+	if err := m.session.Query(stmt).Exec(); err != nil {
+		return err
+	}
+
+	return m.awaitAgreement()
+}
+
+func (m *casandraMigrator) awaitAgreement() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.agreement)
+	defer cancel()
+
+	if err := m.session.AwaitSchemaAgreement(ctx); err != nil {
+		return fmt.Errorf("schema agreement not reached in %v: %v", m.agreement, err)
+	}
+
+	return nil
+}
+
+//ensureKeyspace creates keyspace or alters its replication when configured one differs
+func (m *casandraMigrator) ensureKeyspace(dryRun bool, w io.Writer) error {
+	replication := m.session.replication
+	ks, err := m.session.KeyspaceMetadata(m.keyspace)
+
+	if err == gocql.ErrKeyspaceDoesNotExist {
+		return m.exec(dryRun, w, fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %v WITH replication = %v", m.keyspace, replication))
+	}
+
+	if err != nil || replication.matches(ks) {
+		return err
+	}
+
+	if !dryRun {
+		m.logger.Info("Keyspace %v replication %v is altered to %v; run 'nodetool repair' so existing data reaches new replicas", m.keyspace, describeCasandraReplication(ks), replication)
+	}
+
+	return m.exec(dryRun, w, fmt.Sprintf("ALTER KEYSPACE %v WITH replication = %v", m.keyspace, replication))
+}
+
+func (m *casandraMigrator) bookkeepingDDL() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v.%v ( layout text, version int, name text, checksum bigint, applied_at timestamp, PRIMARY KEY (layout, version) )", m.keyspace, casandraMigrationsTable)
+}
+
+func (mg casandraMigration) checksum() int64 {
+	return int64(crc32.ChecksumIEEE([]byte(strings.Join(append(mg.cql, mg.runInfo), ";"))))
+}
+
+//casandraRecordsMigrations is the schema of cas and casp layouts: records table keyed by the given
+//primary key. View type became a part of the key in version 1; primary key of a cassandra table can
+//not be altered, so rows of the legacy table are copied into the new one while it is empty.
+func casandraRecordsMigrations(table, legacyTable, primaryKey string) []casandraMigration {
+	return []casandraMigration{
+		{
+			version: 1,
+			name:    "create " + table,
+			cql: []string{
+				"CREATE TABLE IF NOT EXISTS %[1]v." + table + " ( partition bigint, type text, key text, version int, values blob, weight int, PRIMARY KEY " + primaryKey + " )",
+			},
+		},
+		{
+			version: 2,
+			name:    "copy " + legacyTable + " into " + table,
+			run: func(m *casandraMigrator) error {
+				return copyCasandraLegacyRecords(m, legacyTable, table)
+			},
+			runInfo: fmt.Sprintf("copy rows of %v into %v unless the latter has rows", legacyTable, table),
+		},
+		{
+			version: 3,
+			name:    "create " + casandraIndexTable(table),
+			cql: []string{
+				"CREATE TABLE IF NOT EXISTS %[1]v." + casandraIndexTable(table) + " ( partition bigint, type text, field text, value text, key text, PRIMARY KEY ((partition, type, field, value), key) )",
+			},
+		},
+		{
+			version: 4,
+			name:    "create " + casandraStatsTable(table),
+			cql: []string{
+				"CREATE TABLE IF NOT EXISTS %[1]v." + casandraStatsTable(table) + " ( partition bigint, type text, records counter, bytes counter, PRIMARY KEY (partition, type) )",
+			},
+		},
+	}
+}
+
+func copyCasandraLegacyRecords(m *casandraMigrator, legacyTable, table string) error {
+	exists, err := m.tableExists(legacyTable)
+
+	if err != nil || !exists {
+		return err
+	}
+
+	var partition int64
+
+	iter := m.session.Query(fmt.Sprintf("SELECT partition FROM %v.%v LIMIT 1", m.keyspace, table)).Iter()
+	hasRows := iter.Scan(&partition)
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	if hasRows {
+		m.logger.Info("%v has rows; legacy %v is not copied", table, legacyTable)
+		return nil
+	}
+
+	return copyCasandraRecords(m.session, m.keyspace+"."+legacyTable, m.keyspace+"."+table, m.logger)
+}
+
+//migrateCasandra runs migrate command of cas and casp drivers
+func migrateCasandra(cfg *Config, layout string, migrations []casandraMigration, command string, dryRun bool, w io.Writer, logger *Logger) error {
+	consistency := (&CasandraDriver{}).getConsistency(cfg.String(ConsistencyParam))
+	session, _, err := openCasandraSession(cfg, cfg.List(HostsParam), consistency, logger)
+
+	if err != nil {
+		return err
+	}
+
+	defer session.Close()
+
+	m := newCasandraMigrator(session, cfg, layout, migrations, logger)
+
+	switch command {
+	case MigrateUp:
+		return m.up(dryRun, w)
+	case MigrateStatus:
+		return m.status(w)
+	default:
+		return fmt.Errorf("unknown migrate command %q; available: %v, %v", command, MigrateUp, MigrateStatus)
+	}
+}
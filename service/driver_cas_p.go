@@ -5,21 +5,28 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
-	"time"
+	"io"
 
 	"github.com/gocql/gocql"
 )
 
+func init() {
+	RegisterDriver("casp", func(logger *Logger) DBDriver {
+		return &CasandraPartitionedDriver{logger: logger}
+	}, "cassandra driver; one table partitioned by workspace (wsid)", casandraParams...)
+}
+
 //CasandraPartitionedDriver s.e.
 type CasandraPartitionedDriver struct {
 	cluster *gocql.ClusterConfig
-	session *gocql.Session
+	session *casandraSession
+
+	stmt casandraStatements
 
-	args map[string]string
+	cfg *Config
 
 	hosts             []string
 	keyspace          string
@@ -27,6 +34,9 @@ type CasandraPartitionedDriver struct {
 	consistency       gocql.Consistency
 	replicationFactor int64
 	lightWeight       int64
+	ttls              viewTTLs
+	indexes           viewIndexes
+	index             *casandraIndex
 
 	logger *Logger
 }
@@ -47,14 +57,16 @@ func (d *CasandraPartitionedDriver) Info() string {
 	str += fmt.Sprintf("Replication factor: %v\n", d.replicationFactor)
 	str += fmt.Sprintf("lightWeight mode: %v\n", d.lightWeight)
 
+	str += casandraSecurityInfo(d.cfg)
+
 	str += "\n\n --- end --- \n\n"
 
 	return str
 }
 
 //Init s.e.
-func (d *CasandraPartitionedDriver) Init(args map[string]string) error {
-	d.args = args
+func (d *CasandraPartitionedDriver) Init(cfg *Config) error {
+	d.cfg = cfg
 
 	err := d.initParams()
 
@@ -62,73 +74,35 @@ func (d *CasandraPartitionedDriver) Init(args map[string]string) error {
 		return err
 	}
 
-	cluster := gocql.NewCluster(d.hosts...)
-	cluster.Keyspace = "system"
-
-	session, err := cluster.CreateSession()
-	defer session.Close()
+	scheme, err := loadOptionalScheme(cfg)
 
 	if err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	q := fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %v WITH replication = {'class': '%s', 'replication_factor' : %v}", d.keyspace, d.class, d.replicationFactor)
-	reconnectCount := 0
+	d.ttls = schemeTTLs(scheme)
+	d.indexes = schemeIndexes(scheme)
 
-	for {
-		if err = session.Query(q).Exec(); err != nil {
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			break
-		}
-
-		reconnectCount++
-
-		if reconnectCount >= MaxReconnectCount {
-			break
-		}
-	}
+	d.session, d.cluster, err = openCasandraSession(d.cfg, d.hosts, d.consistency, d.logger)
 
 	if err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	d.cluster = gocql.NewCluster(d.hosts...)
-	d.cluster.Consistency = d.consistency
-	d.cluster.Keyspace = d.keyspace
-
-	d.session, err = d.cluster.CreateSession()
+	m := newCasandraMigrator(d.session, d.cfg, casandraPartitionedRecordsLayout, d.migrations(), d.logger)
 
-	if err != nil {
+	if err = m.start(d.cfg.String(MigrateParam)); err != nil {
 		d.logger.Error(err.Error())
 		return err
 	}
 
-	q = "CREATE TABLE IF NOT EXISTS records_p ( key text, partition int, version int, values blob, type text, weight int, PRIMARY KEY (partition, key))"
-	reconnectCount = 0
-
-	for {
-		if err := d.session.Query(q).Exec(); err != nil {
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			break
-		}
-
-		reconnectCount++
-
-		if reconnectCount >= MaxReconnectCount {
-			break
-		}
-	}
-
-	if err != nil {
-		d.logger.Error(err.Error())
-		return err
-	}
+	d.stmt = newCasandraStatements(d.keyspace, casandraPartitionedRecordsTable, true)
+	d.stmt.stats = d.cfg.Bool(PartitionStatsParam)
+	d.index = newCasandraIndex(d.keyspace, casandraPartitionedRecordsTable, d.indexes)
 
-	d.logger.Log("Casandra sharded driver initialized")
+	d.logger.Info("Casandra sharded driver initialized")
 
 	d.logger.Debug("Cassandra hosts: %v", d.hosts)
 	d.logger.Debug("Cassandra replication factor: %v", d.replicationFactor)
@@ -139,19 +113,40 @@ func (d *CasandraPartitionedDriver) Init(args map[string]string) error {
 	return nil
 }
 
+//Migrate s.e.
+func (d *CasandraPartitionedDriver) Migrate(cfg *Config, command string, dryRun bool, w io.Writer) error {
+	return migrateCasandra(cfg, casandraPartitionedRecordsLayout, d.migrations(), command, dryRun, w, d.logger)
+}
+
+func (d *CasandraPartitionedDriver) migrations() []casandraMigration {
+	return casandraRecordsMigrations(casandraPartitionedRecordsTable, casandraLegacyPartitionedRecordsTable, "(partition, type, key)")
+}
+
 //Free s.e.
 func (d *CasandraPartitionedDriver) Free() error {
-	d.logger.Log("Casandra sharded driver freed")
+	d.logger.Info("Casandra sharded driver freed")
 
 	d.session.Close()
 
 	return nil
 }
 
+//Ping s.e.
+func (d *CasandraPartitionedDriver) Ping(ctx context.Context) error {
+	return d.session.ping(ctx)
+}
+
+//Hosts s.e.
+func (d *CasandraPartitionedDriver) Hosts() []HostStatus {
+	return d.session.hosts.list()
+}
+
 //Clean s.e.
 func (d *CasandraPartitionedDriver) Clean(r *DBRequest) *DBResponse {
-	if err := d.session.Query(`TRUNCATE records_p;`).Exec(); err != nil {
-		return &DBResponse{Error: err.Error()}
+	for _, q := range []string{d.stmt.truncate, d.index.truncate, d.stmt.statsTruncate} {
+		if err := d.session.query(q).Exec(); err != nil {
+			return &DBResponse{Error: err.Error()}
+		}
 	}
 
 	return &DBResponse{Status: 200}
@@ -159,7 +154,12 @@ func (d *CasandraPartitionedDriver) Clean(r *DBRequest) *DBResponse {
 
 //Read s.e.
 func (d *CasandraPartitionedDriver) Read(r *DBRequest) *DBResponse {
-	var records []*Record
+	d = d.traced(r)
+
+	var (
+		records []*Record
+		saved   int64
+	)
 
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
@@ -176,12 +176,13 @@ func (d *CasandraPartitionedDriver) Read(r *DBRequest) *DBResponse {
 			}
 
 			if rec != nil {
+				saved += projectRecord(rec, v.Fields)
 				records[i] = rec
 			}
 		}
 	}
 
-	return &DBResponse{Status: 200, Records: records}
+	return &DBResponse{Status: 200, Records: records, SavedBytes: saved}
 }
 
 func (d *CasandraPartitionedDriver) read(partition int64, view *ViewView) (*Record, error) {
@@ -208,12 +209,16 @@ func (d *CasandraPartitionedDriver) read(partition int64, view *ViewView) (*Reco
 
 //Insert s.e.
 func (d *CasandraPartitionedDriver) Insert(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
 
 	if len(r.ViewMods) > 0 {
 		for _, v := range r.ViewMods {
+			d.logger.Request(r).Debug("insert request: %v", v)
+
 			err := d.insert(r.Partition, &v)
 
 			if err != nil {
@@ -226,8 +231,6 @@ func (d *CasandraPartitionedDriver) Insert(r *DBRequest) *DBResponse {
 }
 
 func (d *CasandraPartitionedDriver) insert(partition int64, view *ViewMod) error {
-	d.logger.Debug("insert request: %v", view)
-
 	if view.ViewType == "" {
 		return fmt.Errorf("record ViewType name malformed")
 	}
@@ -238,11 +241,21 @@ func (d *CasandraPartitionedDriver) insert(partition int64, view *ViewMod) error
 		return err
 	}
 
-	return d.set(key, partition, view.ViewType, view.Values)
+	ttl, err := d.ttls.ttl(view)
+
+	if err != nil {
+		return err
+	}
+
+	return casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, view.Values, ttl, func() error {
+		return d.set(key, partition, view.ViewType, view.Values, ttl)
+	})
 }
 
 //Update s.e.
 func (d *CasandraPartitionedDriver) Update(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	var err error
 	var key string
 
@@ -259,17 +272,25 @@ func (d *CasandraPartitionedDriver) Update(r *DBRequest) *DBResponse {
 				return &DBResponse{Status: 400, Error: err.Error()}
 			}
 
-			switch d.lightWeight {
-			case 2:
-				_, err = d.updLwL(key, r.Partition, v.ViewType, v.Values)
-			case 1:
-				_, err = d.updLw(key, r.Partition, v.ViewType, v.Values)
+			if err := checkTTL(v.TTL); err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+
+			if err := checkUpdate(&v); err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+
+			switch {
+			case len(v.Ops) > 0 || v.If != nil || d.lightWeight == 1:
+				err = casandraUpdateRecord(d.session, d.stmt, d.index, d.ttls, r.Partition, key, &v)
+			case d.lightWeight == 2:
+				_, err = d.updLwL(key, r.Partition, &v)
 			default:
-				_, err = d.upd(key, r.Partition, v.ViewType, v.Values)
+				_, err = d.upd(key, r.Partition, &v)
 			}
 
 			if err != nil {
-				return &DBResponse{Status: 400, Error: err.Error()}
+				return updateResponse(err)
 			}
 		}
 	}
@@ -279,12 +300,33 @@ func (d *CasandraPartitionedDriver) Update(r *DBRequest) *DBResponse {
 
 //Scan s.e.
 func (d *CasandraPartitionedDriver) Scan(r *DBRequest) *DBResponse {
-	// not implemented yet
-	return &DBResponse{Status: 200}
+	return casandraScan(d.session.traced(r), d.stmt, r)
+}
+
+//Increment s.e.
+func (d *CasandraPartitionedDriver) Increment(r *DBRequest) *DBResponse {
+	return casandraIncrement(d.session.traced(r), d.stmt, d.index, d.ttls, r)
+}
+
+//Lookup s.e.
+func (d *CasandraPartitionedDriver) Lookup(r *DBRequest) *DBResponse {
+	return casandraLookup(d.session.traced(r), d.stmt, d.index, r)
+}
+
+//PartitionStats s.e.
+func (d *CasandraPartitionedDriver) PartitionStats(partition int64) (*PartitionStats, error) {
+	return casandraPartitionStats(d.session, d.stmt, partition)
+}
+
+//PartitionsStats s.e.
+func (d *CasandraPartitionedDriver) PartitionsStats() ([]*PartitionStats, error) {
+	return casandraPartitionsStats(d.session, d.stmt, nil)
 }
 
 //Delete s.e.
 func (d *CasandraPartitionedDriver) Delete(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
@@ -324,16 +366,15 @@ func (d *CasandraPartitionedDriver) delete(key string, partition int64, vtype st
 
 	*/
 
-	if err := d.session.Query(`DELETE FROM records_p WHERE key = ? and partition = ? and type = ?`, key, partition, vtype).Exec(); err != nil {
-		return err
-	}
-
-	return nil
+	return casandraIndexedWrite(d.session, d.stmt, d.index, partition, vtype, key, nil, 0, func() error {
+		return d.session.idempotent(d.stmt.delete, partition, vtype, key).Exec()
+	})
 }
 
 func (d *CasandraPartitionedDriver) get(key string, partition int64, vtype string) (*Record, error) {
 	var values []byte
 	var version int
+	var ttl int64
 
 	/*
 
@@ -347,11 +388,11 @@ func (d *CasandraPartitionedDriver) get(key string, partition int64, vtype strin
 
 	*/
 
-	if err := d.session.Query(`SELECT values, version FROM records_p WHERE key = ? and partition = ?`, key, partition).Scan(&values, &version); err != nil {
+	if err := d.session.idempotent(d.stmt.get, partition, vtype, key).Scan(&values, &version, &ttl); err != nil {
 		return nil, err
 	}
 
-	r := Record{Key: key, Version: version}
+	r := Record{Key: key, Version: version, TTL: ttl}
 
 	if err := json.Unmarshal(values, &r.Values); err != nil {
 		return nil, err
@@ -360,14 +401,14 @@ func (d *CasandraPartitionedDriver) get(key string, partition int64, vtype strin
 	return &r, nil
 }
 
-func (d *CasandraPartitionedDriver) set(key string, partition int64, vtype string, values map[string]interface{}) error {
+func (d *CasandraPartitionedDriver) set(key string, partition int64, vtype string, values map[string]interface{}, ttl int64) error {
 	b, e := json.Marshal(values)
 
 	if e != nil {
 		return e
 	}
 
-	if err := d.session.Query(`INSERT INTO records_p (key, partition, version, type, values, weight) VALUES (?, ?, ?, ?, ?, ?)`, key, partition, 0, vtype, b, 0).Exec(); err != nil {
+	if err := d.session.idempotent(d.stmt.set, partition, vtype, key, 0, b, 0, ttl).Exec(); err != nil {
 		d.logger.Error("Set error %v", err.Error())
 		return err
 	}
@@ -375,198 +416,53 @@ func (d *CasandraPartitionedDriver) set(key string, partition int64, vtype strin
 	return nil
 }
 
-func (d *CasandraPartitionedDriver) upd(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	b, e := json.Marshal(values)
-
-	if e != nil {
-		return false, e
-	}
+func (d *CasandraPartitionedDriver) upd(key string, partition int64, view *ViewMod) (bool, error) {
+	record, err := casandraPatchedRecord(d.session, d.stmt, d.ttls, partition, key, view)
 
-	var q = d.session.Query(`UPDATE records_p SET version=?, values=? WHERE key = ? and partition = ?`, 0, b, key, partition)
-
-	if err := q.Exec(); err != nil {
+	if err != nil {
 		return false, err
 	}
 
-	return true, nil
-}
-
-func (d *CasandraPartitionedDriver) updLw(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	repeatCount := 0
-	version := 0
-
-	for {
-		record, err := d.get(key, partition, vtype)
-
-		if err != nil {
-			return false, err
-		}
-
-		version = record.Version
-
-		for k, v := range values {
-			record.Values[k] = v
-		}
-
-		b, e := json.Marshal(record.Values)
-
-		if e != nil {
-			return false, e
-		}
-
-		var q = d.session.Query(`UPDATE records_p SET version=?, values=? WHERE key = ? and partition = ? if version = ?`, version+1, b, key, partition, version)
-
-		err = q.Exec()
-
-		if err != nil {
-			repeatCount++
-		} else {
-			break
-		}
-
-		if repeatCount >= LWRepeatCount {
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
-func (d *CasandraPartitionedDriver) updLwL(key string, partition int64, vtype string, values map[string]interface{}) (bool, error) {
-	b, e := json.Marshal(values)
+	b, e := json.Marshal(record.Values)
 
 	if e != nil {
 		return false, e
 	}
 
-	var q = d.session.Query(`UPDATE records_p SET values=? WHERE key = ? and partition = ? if weight = ?`, b, key, partition, 0)
-
-	if err := q.Exec(); err != nil {
-		return false, err
-	}
-
-	return true, nil
-}
-
-func (d *CasandraPartitionedDriver) initParams() error {
-	if err := d.initHosts(); err != nil {
-		return err
-	}
-
-	if err := d.initKeyspace(); err != nil {
-		return err
-	}
-
-	if err := d.initConsistensy(); err != nil {
-		return err
-	}
-
-	if err := d.initClass(); err != nil {
-		return err
-	}
-
-	if err := d.initReplicationFactor(); err != nil {
-		return err
-	}
-
-	if err := d.initLWTMode(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *CasandraPartitionedDriver) initHosts() error {
-	if h, exists := os.LookupEnv(HostsEnvironmentProperty); exists {
-		hosts := strings.Split(strings.TrimSpace(h), ",")
-
-		if len(hosts) >= 1 {
-			d.hosts = hosts
-			return nil
-		}
-
-		return fmt.Errorf("environment variable %v malformed: string of IPs expected (comma as delimiter)", HostsEnvironmentProperty)
-	}
-
-	if h, exists := d.args[HostsAttribute]; exists {
-		hosts := strings.Split(strings.TrimSpace(h), ",")
-
-		if len(hosts) >= 1 {
-			d.hosts = hosts
-			return nil
-		}
-
-		return fmt.Errorf("attribute %v malformed: string of IPs expected (comma as delimiter)", HostsAttribute)
-	}
-
-	d.hosts = []string{DefaultHost}
+	err = casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, record.Values, record.TTL, func() error {
+		return d.session.idempotent(d.stmt.upd, record.TTL, record.Version, b, partition, view.ViewType, key).Exec()
+	})
 
-	return nil
+	return err == nil, err
 }
 
-func (d *CasandraPartitionedDriver) initKeyspace() error {
-	if keyspace, exists := os.LookupEnv(KeyspaceEnvironmentProperty); exists {
-		if len(keyspace) > 0 {
-			d.keyspace = strings.TrimSpace(keyspace)
-			return nil
-		}
+func (d *CasandraPartitionedDriver) updLwL(key string, partition int64, view *ViewMod) (bool, error) {
+	record, err := casandraPatchedRecord(d.session, d.stmt, d.ttls, partition, key, view)
 
-		return fmt.Errorf("environment variable %v malformed: not empty string expected", KeyspaceEnvironmentProperty)
-	}
-
-	if keyspace, exists := d.args[KeyspaceAttribute]; exists {
-		if len(keyspace) > 0 {
-			d.keyspace = strings.TrimSpace(keyspace)
-			return nil
-		}
-
-		return fmt.Errorf("argument %v malformed: not empty string expected", KeyspaceEnvironmentProperty)
-	}
-
-	d.keyspace = DefaultKeyspaceName
-
-	return nil
-}
-
-func (d *CasandraPartitionedDriver) initConsistensy() error {
-	if con, exists := os.LookupEnv(ConsistencyEnvironmentProperty); exists {
-		d.consistency = d.getConsistency(con)
-		return nil
-	}
-
-	if con, exists := d.args[ConsistencyAttribute]; exists {
-		d.consistency = d.getConsistency(con)
-		return nil
+	if err != nil {
+		return false, err
 	}
 
-	d.consistency = gocql.All
-
-	return nil
-}
-
-func (d *CasandraPartitionedDriver) initClass() error {
-	d.class = initStringParam(d.args, ClassEnvironmentProperty, ClassAttribute, DefaultClass)
+	b, e := json.Marshal(record.Values)
 
-	return nil
-}
-
-func (d *CasandraPartitionedDriver) initReplicationFactor() error {
-	rf := initIntParam(d.args, ReplicationFactorEnvironmentProperty, ReplicationFactorAttribute, DefaultReplicationFactor)
-
-	if rf <= 0 {
-		rf = DefaultReplicationFactor
+	if e != nil {
+		return false, e
 	}
 
-	d.replicationFactor = rf
+	err = casandraIndexedWrite(d.session, d.stmt, d.index, partition, view.ViewType, key, record.Values, record.TTL, func() error {
+		return d.session.query(d.stmt.updLwL, record.TTL, b, partition, view.ViewType, key, 0).Exec()
+	})
 
-	return nil
+	return err == nil, err
 }
-func (d *CasandraPartitionedDriver) initLWTMode() error {
-	lwt := initIntParam(d.args, LightWeightTransactionEnvironmentProperty, LightWeightTransactionAttribute, 0)
 
-	if lwt >= 0 && lwt <= 2 {
-		d.lightWeight = lwt
-	}
+func (d *CasandraPartitionedDriver) initParams() error {
+	d.hosts = d.cfg.List(HostsParam)
+	d.keyspace = d.cfg.String(KeyspaceParam)
+	d.consistency = d.getConsistency(d.cfg.String(ConsistencyParam))
+	d.class = d.cfg.String(ClassParam)
+	d.replicationFactor = d.cfg.Int(ReplicationFactorParam)
+	d.lightWeight = d.cfg.Int(LightWeightTransactionParam)
 
 	return nil
 }
@@ -595,3 +491,17 @@ func (d *CasandraPartitionedDriver) getConsistency(s string) gocql.Consistency {
 		return gocql.All
 	}
 }
+
+//traced returns the driver whose queries are traced within the span of the request, if any
+func (d *CasandraPartitionedDriver) traced(r *DBRequest) *CasandraPartitionedDriver {
+	session := d.session.traced(r)
+
+	if session == d.session {
+		return d
+	}
+
+	c := *d
+	c.session = session
+
+	return &c
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	casandraSimpleStrategy          = "SimpleStrategy"
+	casandraNetworkTopologyStrategy = "NetworkTopologyStrategy"
+)
+
+//casandraReplication is the keyspace replication: factor for SimpleStrategy, factor per
+//datacenter (in the order of the spec) for NetworkTopologyStrategy
+type casandraReplication struct {
+	class  string
+	factor int64
+	dcs    []casandraDCReplication
+}
+
+type casandraDCReplication struct {
+	dc     string
+	factor int64
+}
+
+//newCasandraReplication builds replication from class, replication factor and spec "dc1:3,dc2:2";
+//NetworkTopologyStrategy without spec replicates into the local datacenter only
+func newCasandraReplication(cfg *Config) (*casandraReplication, error) {
+	r := &casandraReplication{
+		class:  cfg.String(ClassParam),
+		factor: cfg.Int(ReplicationFactorParam),
+	}
+
+	spec := cfg.String(ReplicationParam)
+
+	if r.class != casandraNetworkTopologyStrategy {
+		if spec != "" {
+			return nil, fmt.Errorf("%v is given for %v; use %v", ReplicationAttribute, r.class, ReplicationFactorAttribute)
+		}
+
+		return r, nil
+	}
+
+	if spec == "" {
+		dc := cfg.String(LocalDCParam)
+
+		if dc == "" {
+			return nil, fmt.Errorf("%v needs %v (e.g. dc1:3,dc2:2) or %v", casandraNetworkTopologyStrategy, ReplicationAttribute, LocalDCAttribute)
+		}
+
+		spec = fmt.Sprintf("%v:%v", dc, r.factor)
+	}
+
+	seen := map[string]bool{}
+
+	for _, item := range splitList(spec) {
+		i := strings.LastIndex(item, ":")
+
+		if i <= 0 {
+			return nil, fmt.Errorf("replication %q malformed: datacenter:factor expected", item)
+		}
+
+		dc := strings.TrimSpace(item[:i])
+		factor, err := strconv.ParseInt(strings.TrimSpace(item[i+1:]), 10, 64)
+
+		if err != nil || factor < 0 {
+			return nil, fmt.Errorf("replication %q malformed: factor should be a non negative integer", item)
+		}
+
+		if seen[dc] {
+			return nil, fmt.Errorf("replication: datacenter %v is given twice", dc)
+		}
+
+		seen[dc] = true
+		r.dcs = append(r.dcs, casandraDCReplication{dc: dc, factor: factor})
+	}
+
+	if dc := cfg.String(LocalDCParam); dc != "" && !seen[dc] {
+		return nil, fmt.Errorf("local datacenter %v is not replicated by %v", dc, spec)
+	}
+
+	return r, nil
+}
+
+//localDC is the datacenter queries are routed to: the given one or the first of the spec
+func (r *casandraReplication) localDC(cfg *Config) string {
+	if dc := cfg.String(LocalDCParam); dc != "" {
+		return dc
+	}
+
+	if len(r.dcs) > 0 {
+		return r.dcs[0].dc
+	}
+
+	return ""
+}
+
+//String renders CQL replication map
+func (r *casandraReplication) String() string {
+	if r.class != casandraNetworkTopologyStrategy {
+		return fmt.Sprintf("{'class': '%v', 'replication_factor': %v}", r.class, r.factor)
+	}
+
+	items := []string{fmt.Sprintf("'class': '%v'", r.class)}
+
+	for _, d := range r.dcs {
+		items = append(items, fmt.Sprintf("'%v': %v", d.dc, d.factor))
+	}
+
+	return "{" + strings.Join(items, ", ") + "}"
+}
+
+//matches compares replication with the one of an existing keyspace
+func (r *casandraReplication) matches(ks *gocql.KeyspaceMetadata) bool {
+	if !strings.HasSuffix(ks.StrategyClass, r.class) {
+		return false
+	}
+
+	options := r.options()
+
+	if len(options) != len(ks.StrategyOptions) {
+		return false
+	}
+
+	for k, v := range options {
+		if fmt.Sprint(ks.StrategyOptions[k]) != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *casandraReplication) options() map[string]string {
+	if r.class != casandraNetworkTopologyStrategy {
+		return map[string]string{"replication_factor": fmt.Sprint(r.factor)}
+	}
+
+	options := map[string]string{}
+
+	for _, d := range r.dcs {
+		options[d.dc] = fmt.Sprint(d.factor)
+	}
+
+	return options
+}
+
+//casandraLocalConsistency tells consistency levels which are evaluated in the local datacenter
+func casandraLocalConsistency(c gocql.Consistency) bool {
+	return c == gocql.LocalQuorum || c == gocql.LocalOne
+}
+
+func describeCasandraReplication(ks *gocql.KeyspaceMetadata) string {
+	keys := make([]string, 0, len(ks.StrategyOptions))
+
+	for k := range ks.StrategyOptions {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	items := []string{fmt.Sprintf("'class': '%v'", ks.StrategyClass)}
+
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("'%v': %v", k, ks.StrategyOptions[k]))
+	}
+
+	return "{" + strings.Join(items, ", ") + "}"
+}
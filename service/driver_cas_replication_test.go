@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_casandraReplication(t *testing.T) {
+	replication := func(args ...string) (*casandraReplication, *Config, error) {
+		cfg, err := LoadConfig(args, nil)
+		assert.Nil(t, err)
+
+		r, err := newCasandraReplication(cfg)
+
+		return r, cfg, err
+	}
+
+	{
+		r, cfg, err := replication("--rf", "3")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "{'class': 'SimpleStrategy', 'replication_factor': 3}", r.String())
+		assert.Equal(t, "", r.localDC(cfg))
+		assert.True(t, r.matches(&gocql.KeyspaceMetadata{
+			StrategyClass:   "org.apache.cassandra.locator.SimpleStrategy",
+			StrategyOptions: map[string]interface{}{"replication_factor": "3"},
+		}))
+	}
+
+	{
+		r, cfg, err := replication("--cs", "NetworkTopologyStrategy", "--replication", "dc2:2, dc1:3")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "{'class': 'NetworkTopologyStrategy', 'dc2': 2, 'dc1': 3}", r.String())
+		assert.Equal(t, "dc2", r.localDC(cfg))
+
+		ks := &gocql.KeyspaceMetadata{
+			StrategyClass:   "org.apache.cassandra.locator.NetworkTopologyStrategy",
+			StrategyOptions: map[string]interface{}{"dc1": "3", "dc2": "2"},
+		}
+		assert.True(t, r.matches(ks))
+
+		ks.StrategyOptions["dc2"] = "1"
+		assert.False(t, r.matches(ks))
+	}
+
+	{
+		r, cfg, err := replication("--cs", "NetworkTopologyStrategy", "--dc", "east", "--rf", "2")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "{'class': 'NetworkTopologyStrategy', 'east': 2}", r.String())
+		assert.Equal(t, "east", r.localDC(cfg))
+	}
+
+	{
+		_, _, err := replication("--cs", "NetworkTopologyStrategy")
+		assert.NotNil(t, err)
+
+		_, _, err = replication("--replication", "dc1:3")
+		assert.NotNil(t, err)
+
+		_, _, err = replication("--cs", "NetworkTopologyStrategy", "--replication", "dc1")
+		assert.NotNil(t, err)
+
+		_, _, err = replication("--cs", "NetworkTopologyStrategy", "--replication", "dc1:3,dc1:2")
+		assert.NotNil(t, err)
+
+		_, _, err = replication("--cs", "NetworkTopologyStrategy", "--replication", "dc1:3", "--dc", "dc2")
+		assert.NotNil(t, err)
+	}
+}
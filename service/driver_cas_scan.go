@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//casandraScan serves Scan of cas and casp drivers: records of the view whose keys start with the partition
+//key and are not less than the key of the cluster key, in order of keys. cas answers 501: its key is a part
+//of the partition key, so a range of keys can not be read without filtering all the table.
+func casandraScan(session *casandraSession, stmt casandraStatements, r *DBRequest) *DBResponse {
+	view, err := checkScan(r)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	if stmt.scan == "" {
+		return &DBResponse{Status: 501, Error: fmt.Sprintf("scan of view %v: records are not clustered by key", view.ViewType)}
+	}
+
+	prefix, _ := buildKey(view.PartitionKey, nil)
+	start, _ := buildKey(view.PartitionKey, view.ClusterKey)
+
+	return executeScan(view, func(fields []string, pageState []byte, limit int) ([]*Record, []byte, error) {
+		var (
+			records []*Record
+			key     string
+			b       []byte
+			version int
+			ttl     int64
+		)
+
+		// setting page state (even empty) turns automatic paging off, so one page is read
+		iter := session.idempotent(stmt.scan, r.Partition, view.ViewType, start).PageSize(limit).PageState(pageState).Iter()
+		next := iter.PageState()
+
+		for iter.Scan(&key, &b, &version, &ttl) {
+			if !strings.HasPrefix(key, prefix) {
+				next = nil
+				break
+			}
+
+			rec := &Record{Key: key, Version: version, TTL: ttl}
+
+			if err := json.Unmarshal(b, &rec.Values); err != nil {
+				iter.Close()
+				return nil, nil, err
+			}
+
+			records = append(records, rec)
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		return records, next, nil
+	})
+}
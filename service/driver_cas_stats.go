@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+func casandraStatsTable(table string) string {
+	return table + "_stats"
+}
+
+//casandraCountWrite adds the change of record count and size by a write to partition statistics of cas
+//and casp layouts. Counters are estimates: concurrent writes of a record may be counted twice and
+//expired records are not subtracted.
+func casandraCountWrite(session *casandraSession, stmt casandraStatements, partition int64, vtype string, existed bool, size int64, values map[string]interface{}) error {
+	if !stmt.stats {
+		return nil
+	}
+
+	records, bytes := statsDelta(existed, size, values)
+
+	if records == 0 && bytes == 0 {
+		return nil
+	}
+
+	// counter updates are not idempotent
+	return session.query(stmt.statsAdd, records, bytes, partition, vtype).Exec()
+}
+
+//casandraPartitionsStats reads partition statistics of cas and casp layouts: of one partition or, with
+//nil partition, of all of them
+func casandraPartitionsStats(session *casandraSession, stmt casandraStatements, partition *int64) ([]*PartitionStats, error) {
+	if !stmt.stats {
+		return nil, ErrStatsNotTracked
+	}
+
+	var (
+		p              int64
+		vtype          string
+		records, bytes int64
+		list           []*PartitionStats
+	)
+
+	q := session.idempotent(stmt.statsAll)
+
+	if partition != nil {
+		q = session.idempotent(stmt.statsGet, *partition)
+	}
+
+	iter := q.Iter()
+
+	stats := map[int64]*PartitionStats{}
+
+	for iter.Scan(&p, &vtype, &records, &bytes) {
+		s, ok := stats[p]
+
+		if !ok {
+			s = &PartitionStats{Partition: p}
+			stats[p] = s
+			list = append(list, s)
+		}
+
+		s.add(vtype, records, bytes)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//casandraPartitionStats s.e.
+func casandraPartitionStats(session *casandraSession, stmt casandraStatements, partition int64) (*PartitionStats, error) {
+	list, err := casandraPartitionsStats(session, stmt, &partition)
+
+	if err != nil || len(list) == 0 {
+		return &PartitionStats{Partition: partition}, err
+	}
+
+	return list[0], nil
+}
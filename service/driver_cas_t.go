@@ -0,0 +1,1302 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+func init() {
+	RegisterDriver("cast", func(logger *Logger) DBDriver {
+		return &CasandraTypedDriver{logger: logger}
+	}, "cassandra driver; table per view with typed columns generated from the scheme", casandraParams...)
+}
+
+//CasandraTypedDriver keeps every view of the scheme in its own table:
+//PRIMARY KEY ((partition, pk_<partitionkey field>...), ck_<clusterkey field>...), regular fields are typed columns
+type CasandraTypedDriver struct {
+	cluster *gocql.ClusterConfig
+	session *casandraSession
+
+	cfg      *Config
+	scheme   *Scheme
+	migrator *casandraMigrator
+	ttls     viewTTLs
+	indexes  viewIndexes
+
+	hosts             []string
+	keyspace          string
+	class             string
+	consistency       gocql.Consistency
+	replicationFactor int64
+	lightWeight       int64
+	stats             bool
+
+	logger *Logger
+}
+
+//Name s.e.
+func (d *CasandraTypedDriver) Name() string {
+	return "Cassandra typed driver"
+}
+
+//Info s.e.
+func (d *CasandraTypedDriver) Info() string {
+	str := "Casandra typed driver info: \n\n"
+
+	str += fmt.Sprintf("Hosts: %v\n", d.hosts)
+	str += fmt.Sprintf("Keyspace name: %v\n", d.keyspace)
+	str += fmt.Sprintf("Class: %v\n", d.class)
+	str += fmt.Sprintf("Consistency: %v\n", d.consistency)
+	str += fmt.Sprintf("Replication factor: %v\n", d.replicationFactor)
+	str += fmt.Sprintf("LightWeight mode: %v\n", d.lightWeight)
+	str += fmt.Sprintf("Partition stats: %v\n", d.stats)
+	str += fmt.Sprintf("Scheme: %v\n", d.cfg.String(SchemeParam))
+
+	for _, v := range d.scheme.Views {
+		str += fmt.Sprintf("  table %v: %v\n", v.Name, d.tableDDL(v))
+
+		for _, name := range v.Indexes {
+			str += fmt.Sprintf("  index %v: %v\n", indexTable(v.Name, name), d.indexDDL(v, v.Field(name)))
+		}
+	}
+
+	str += casandraSecurityInfo(d.cfg)
+
+	str += "\n\n --- end --- \n\n"
+
+	return str
+}
+
+//Init s.e.
+func (d *CasandraTypedDriver) Init(cfg *Config) error {
+	var err error
+
+	d.cfg = cfg
+
+	d.hosts = cfg.List(HostsParam)
+	d.keyspace = cfg.String(KeyspaceParam)
+	d.consistency = (&CasandraDriver{}).getConsistency(cfg.String(ConsistencyParam))
+	d.class = cfg.String(ClassParam)
+	d.replicationFactor = cfg.Int(ReplicationFactorParam)
+	d.lightWeight = cfg.Int(LightWeightTransactionParam)
+	d.stats = cfg.Bool(PartitionStatsParam)
+
+	if d.scheme, err = LoadScheme(cfg.String(SchemeParam)); err != nil {
+		d.logger.Error(err.Error())
+		return err
+	}
+
+	d.ttls = schemeTTLs(d.scheme)
+	d.indexes = schemeIndexes(d.scheme)
+
+	d.session, d.cluster, err = openCasandraSession(d.cfg, d.hosts, d.consistency, d.logger)
+
+	if err != nil {
+		d.logger.Error(err.Error())
+		return err
+	}
+
+	// tables follow the scheme rather than versioned migrations; the migrator creates keyspace and
+	// waits for schema agreement after each change
+	d.migrator = newCasandraMigrator(d.session, d.cfg, "typed", nil, d.logger)
+
+	if err = d.migrator.ensureKeyspace(false, nil); err != nil {
+		d.logger.Error(err.Error())
+		return err
+	}
+
+	for _, v := range d.scheme.Views {
+		if err = d.migrateView(v); err != nil {
+			d.logger.Error(err.Error())
+			return err
+		}
+
+		if err = d.migrateIndexes(v); err != nil {
+			d.logger.Error(err.Error())
+			return err
+		}
+	}
+
+	if err = d.migrateStats(); err != nil {
+		d.logger.Error(err.Error())
+		return err
+	}
+
+	d.logger.Info("Casandra typed driver initialized")
+
+	d.logger.Debug("Cassandra hosts: %v", d.hosts)
+	d.logger.Debug("Cassandra keyspace: %v", d.keyspace)
+	d.logger.Debug("Cassandra views: %v", len(d.scheme.Views))
+
+	return nil
+}
+
+//Free s.e.
+func (d *CasandraTypedDriver) Free() error {
+	d.logger.Info("Casandra typed driver freed")
+
+	d.session.Close()
+
+	return nil
+}
+
+//Ping s.e.
+func (d *CasandraTypedDriver) Ping(ctx context.Context) error {
+	return d.session.ping(ctx)
+}
+
+//Hosts s.e.
+func (d *CasandraTypedDriver) Hosts() []HostStatus {
+	return d.session.hosts.list()
+}
+
+//Clean s.e.
+func (d *CasandraTypedDriver) Clean(r *DBRequest) *DBResponse {
+	for _, v := range d.scheme.Views {
+		if err := d.session.query(fmt.Sprintf("TRUNCATE %v", d.table(v))).Exec(); err != nil {
+			return &DBResponse{Error: err.Error()}
+		}
+
+		for _, name := range v.Indexes {
+			if err := d.session.query(fmt.Sprintf("TRUNCATE %v", d.indexTable(v, name))).Exec(); err != nil {
+				return &DBResponse{Error: err.Error()}
+			}
+		}
+	}
+
+	if d.stats {
+		if err := d.session.query(fmt.Sprintf("TRUNCATE %v", d.statsTable())).Exec(); err != nil {
+			return &DBResponse{Error: err.Error()}
+		}
+	}
+
+	return &DBResponse{Status: 200}
+}
+
+//Read s.e.
+func (d *CasandraTypedDriver) Read(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	var (
+		records []*Record
+		saved   int64
+	)
+
+	if r == nil {
+		return &DBResponse{Status: 400, Error: "wrong request data"}
+	}
+
+	if len(r.ViewViews) > 0 {
+		records = make([]*Record, len(r.ViewViews))
+
+		for i, v := range r.ViewViews {
+			rec, err := d.read(r.Partition, &v)
+
+			if err != nil {
+				return &DBResponse{Status: 400, Error: err.Error()}
+			}
+
+			if rec != nil {
+				saved += projectRecord(rec, v.Fields)
+				records[i] = rec
+			}
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records, SavedBytes: saved}
+}
+
+func (d *CasandraTypedDriver) read(partition int64, view *ViewView) (*Record, error) {
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := d.keyCondition(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := d.projection(vs, view.Fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf("SELECT %v FROM %v WHERE %v", selectList(fields, fields), d.table(vs), where)
+	dest := scanDest(fields, fields)
+
+	if err := d.session.idempotent(q, args...).Scan(dest...); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	key, err := buildKey(view.PartitionKey, view.ClusterKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{Key: key, Values: fieldValues(fields, dest), TTL: recordTTL(dest[len(fields):])}, nil
+}
+
+//Insert s.e.
+func (d *CasandraTypedDriver) Insert(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	if r == nil {
+		return &DBResponse{Status: 400, Error: "wrong request data"}
+	}
+
+	for _, v := range r.ViewMods {
+		if err := d.insert(r.Partition, &v); err != nil {
+			return &DBResponse{Status: 400, Error: fmt.Sprintf("Insert error: %v", err.Error())}
+		}
+	}
+
+	return &DBResponse{Status: 200}
+}
+
+func (d *CasandraTypedDriver) insert(partition int64, view *ViewMod) error {
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return err
+	}
+
+	if vs.Counters() {
+		return fmt.Errorf("view %v has counters; use increment", vs.Name)
+	}
+
+	cols, args, err := d.keyColumns(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return err
+	}
+
+	names, values, err := d.fieldColumns(vs, view.Values)
+
+	if err != nil {
+		return err
+	}
+
+	ttl, err := d.ttls.ttl(view)
+
+	if err != nil {
+		return err
+	}
+
+	cols = append(cols, names...)
+	args = append(args, values...)
+	args = append(args, ttl)
+
+	q := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v) USING TTL ?", d.table(vs), strings.Join(cols, ", "), placeholders(len(cols)))
+
+	return d.indexedWrite(vs, partition, &view.ViewView, view.Values, false, ttl, func() error {
+		return d.session.idempotent(q, args...).Exec()
+	})
+}
+
+//Update sets only the given columns of an existing record
+func (d *CasandraTypedDriver) Update(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	if r == nil {
+		return &DBResponse{Status: 400, Error: "wrong request data"}
+	}
+
+	for _, v := range r.ViewMods {
+		if err := d.update(r.Partition, &v); err != nil {
+			return updateResponse(err)
+		}
+	}
+
+	return &DBResponse{Status: 200}
+}
+
+func (d *CasandraTypedDriver) update(partition int64, view *ViewMod) error {
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return err
+	}
+
+	if vs.Counters() {
+		return fmt.Errorf("view %v has counters; use increment", vs.Name)
+	}
+
+	where, keyArgs, err := d.keyCondition(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkUpdate(view); err != nil {
+		return err
+	}
+
+	set, values, err := d.patchAssignments(vs, view)
+
+	if err != nil {
+		return err
+	}
+
+	opSet, opValues, err := d.collectionAssignments(vs, view)
+
+	if err != nil {
+		return err
+	}
+
+	set = append(set, opSet...)
+	values = append(values, opValues...)
+
+	if err := checkTTL(view.TTL); err != nil {
+		return err
+	}
+
+	key, _ := buildKey(view.PartitionKey, view.ClusterKey)
+	remaining := int64(0)
+
+	// updated fields keep the remaining TTL of the record unless TTL is given; light weight updates check
+	// the record exists with IF EXISTS, others read it and the last writer wins
+	if d.lightWeight == 0 || view.TTL == 0 || len(set) == 0 {
+		var exists bool
+
+		if exists, remaining, err = d.remainingTTL(vs, where, keyArgs); err != nil {
+			return err
+		}
+
+		if !exists {
+			return &notFoundError{partition, vs.Name, key}
+		}
+	}
+
+	if len(set) == 0 {
+		return nil
+	}
+
+	ttl, err := d.ttls.modifyTTL(view, true, remaining)
+
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("UPDATE %v USING TTL ? SET %v WHERE %v", d.table(vs), strings.Join(set, ", "), where)
+	args := append(append([]interface{}{ttl}, values...), keyArgs...)
+
+	return d.indexedWrite(vs, partition, &view.ViewView, view.Values, view.Replace, ttl, func() error {
+		if view.If != nil {
+			return d.updateIf(vs, partition, view, q, args)
+		}
+
+		if d.lightWeight == 0 {
+			return d.session.idempotent(q, args...).Exec()
+		}
+
+		applied, err := d.session.query(q+" IF EXISTS", args...).MapScanCAS(map[string]interface{}{})
+
+		if err != nil {
+			return err
+		}
+
+		if !applied {
+			return &notFoundError{partition, vs.Name, key}
+		}
+
+		return nil
+	})
+}
+
+//remainingTTL reads whether the record exists and its TTL, see recordTTL
+func (d *CasandraTypedDriver) remainingTTL(vs *ViewScheme, where string, keyArgs []interface{}) (bool, int64, error) {
+	dest := scanDest(vs.PartitionKey, vs.Fields)
+	q := fmt.Sprintf("SELECT %v FROM %v WHERE %v", selectList(vs.PartitionKey, vs.Fields), d.table(vs), where)
+
+	if err := d.session.idempotent(q, keyArgs...).Scan(dest...); err != nil {
+		if err == gocql.ErrNotFound {
+			return false, 0, nil
+		}
+
+		return false, 0, err
+	}
+
+	return true, recordTTL(dest[len(vs.PartitionKey):]), nil
+}
+
+//updateIf applies update with IF clause of the condition; when it is not applied the record is read
+//to return current values
+func (d *CasandraTypedDriver) updateIf(vs *ViewScheme, partition int64, view *ViewMod, q string, args []interface{}) error {
+	c, err := view.If.normalize(false)
+
+	if err != nil {
+		return err
+	}
+
+	conds, condArgs, err := d.conditionClause(vs, c)
+
+	if err != nil {
+		return err
+	}
+
+	applied, err := d.session.query(q+" IF "+strings.Join(conds, " AND "), append(args, condArgs...)...).MapScanCAS(map[string]interface{}{})
+
+	if err != nil || applied {
+		return err
+	}
+
+	record, err := d.read(partition, &view.ViewView)
+
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		key, _ := buildKey(view.PartitionKey, view.ClusterKey)
+		return &notFoundError{partition, vs.Name, key}
+	}
+
+	return &conditionError{record: record}
+}
+
+//casandraConditionOps are CQL operators of conditions; IF clause compares regular columns only
+var casandraConditionOps = map[string]string{
+	CondEq:        "= ?",
+	CondNe:        "!= ?",
+	CondLt:        "< ?",
+	CondLe:        "<= ?",
+	CondGt:        "> ?",
+	CondGe:        ">= ?",
+	CondExists:    "!= null",
+	CondNotExists: "= null",
+	CondIn:        "IN ?",
+}
+
+//conditionClause renders normalized condition as CQL conditions joined with AND; CQL has no OR
+func (d *CasandraTypedDriver) conditionClause(vs *ViewScheme, c *Condition) ([]string, []interface{}, error) {
+	if len(c.Or) > 1 {
+		return nil, nil, fmt.Errorf("view %v: cassandra conditions can not be combined with or", vs.Name)
+	}
+
+	subs := make([]*Condition, 0, len(c.And)+len(c.Or))
+
+	if subs = append(append(subs, c.And...), c.Or...); len(subs) > 0 {
+		var (
+			conds []string
+			args  []interface{}
+		)
+
+		for _, sub := range subs {
+			sc, sa, err := d.conditionClause(vs, sub)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			conds = append(conds, sc...)
+			args = append(args, sa...)
+		}
+
+		return conds, args, nil
+	}
+
+	f := vs.Field(c.Field)
+
+	if f == nil || f.Type == "counter" {
+		return nil, nil, fmt.Errorf("view %v: condition field %q should be a regular field of the scheme", vs.Name, c.Field)
+	}
+
+	op, ok := casandraConditionOps[c.Op]
+
+	if !ok {
+		return nil, nil, fmt.Errorf("view %v: condition %v can not be expressed in cassandra conditions", vs.Name, c.Op)
+	}
+
+	cond := f.Column + " " + op
+
+	if c.Op == CondExists || c.Op == CondNotExists {
+		return []string{cond}, nil, nil
+	}
+
+	if f.Collection() && c.Op != CondEq && c.Op != CondNe {
+		return nil, nil, fmt.Errorf("view %v: %v field %v can be compared with %v or %v only", vs.Name, f.Type, f.Name, CondEq, CondNe)
+	}
+
+	if c.Op == CondIn {
+		in := make([]interface{}, len(c.Value.([]interface{})))
+
+		for i, e := range c.Value.([]interface{}) {
+			v, err := columnValue(f, e)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			in[i] = v
+		}
+
+		return []string{cond}, []interface{}{in}, nil
+	}
+
+	v, err := columnValue(f, c.Value)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []string{cond}, []interface{}{v}, nil
+}
+
+//patchAssignments renders Values as merge patch: null clears a column, an object is merged into a map
+//column (null entries are removed); with Replace fields which are not given are cleared
+func (d *CasandraTypedDriver) patchAssignments(vs *ViewScheme, view *ViewMod) ([]string, []interface{}, error) {
+	plain := make(map[string]interface{}, len(view.Values))
+	merged := []string{}
+
+	if view.Replace {
+		for _, f := range vs.Fields {
+			plain[f.Name] = nil
+		}
+	}
+
+	for k, v := range view.Values {
+		if _, ok := v.(map[string]interface{}); ok && !view.Replace {
+			if f := vs.Field(k); f != nil && f.Type == "map" {
+				merged = append(merged, k)
+				continue
+			}
+		}
+
+		plain[k] = mergePatch(nil, v)
+	}
+
+	cols, args, err := d.fieldColumns(vs, plain)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set := make([]string, len(cols))
+
+	for i, c := range cols {
+		set[i] = c + " = ?"
+	}
+
+	sort.Strings(merged)
+
+	for _, k := range merged {
+		f := vs.Field(k)
+		entries := map[string]interface{}{}
+		removed := []interface{}{}
+
+		for ek, ev := range view.Values[k].(map[string]interface{}) {
+			if ev == nil {
+				removed = append(removed, ek)
+			} else {
+				entries[ek] = ev
+			}
+		}
+
+		if len(entries) > 0 {
+			v, err := columnValue(f, entries)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			set = append(set, fmt.Sprintf("%[1]v = %[1]v + ?", f.Column))
+			args = append(args, v)
+		}
+
+		if len(removed) > 0 {
+			v, err := columnValue(&FieldScheme{Name: f.Name, Type: "set", Of: "string"}, removed)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			set = append(set, fmt.Sprintf("%[1]v = %[1]v - ?", f.Column))
+			args = append(args, v)
+		}
+	}
+
+	return set, args, nil
+}
+
+//collectionAssignments renders collection operations as native CQL collection updates, which cassandra
+//applies atomically without reading the record; a field takes one operation and is not given in Values
+func (d *CasandraTypedDriver) collectionAssignments(vs *ViewScheme, view *ViewMod) ([]string, []interface{}, error) {
+	set := make([]string, 0, len(view.Ops))
+	args := make([]interface{}, 0, len(view.Ops))
+	seen := map[string]bool{}
+
+	for _, op := range view.Ops {
+		if err := op.check(); err != nil {
+			return nil, nil, err
+		}
+
+		f := vs.Field(op.Field)
+
+		if f == nil || !f.Collection() {
+			return nil, nil, fmt.Errorf("view %v: collection operation %v: %q is not a collection field", vs.Name, op.Op, op.Field)
+		}
+
+		if _, ok := view.Values[op.Field]; ok || seen[op.Field] {
+			return nil, nil, fmt.Errorf("view %v: field %v is changed twice in one update", vs.Name, op.Field)
+		}
+
+		seen[op.Field] = true
+
+		kinds := map[string]string{OpAppend: "list", OpPrepend: "list", OpAdd: "set", OpPut: "map", OpRemoveKeys: "map"}
+
+		if kind, ok := kinds[op.Op]; (ok && kind != f.Type) || (op.Op == OpRemove && f.Type == "map") {
+			return nil, nil, fmt.Errorf("view %v: collection operation %v is not applicable to %v field %v", vs.Name, op.Op, f.Type, f.Name)
+		}
+
+		var (
+			arg interface{}
+			err error
+		)
+
+		switch op.Op {
+		case OpPut:
+			arg, err = columnValue(f, op.Entries)
+		case OpRemoveKeys:
+			arg, err = columnValue(&FieldScheme{Name: f.Name, Type: "set", Of: "string"}, op.Values)
+		default:
+			arg, err = columnValue(f, op.Values)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch op.Op {
+		case OpPrepend:
+			set = append(set, fmt.Sprintf("%[1]v = ? + %[1]v", f.Column))
+		case OpRemove, OpRemoveKeys:
+			set = append(set, fmt.Sprintf("%[1]v = %[1]v - ?", f.Column))
+		default:
+			set = append(set, fmt.Sprintf("%[1]v = %[1]v + ?", f.Column))
+		}
+
+		args = append(args, arg)
+	}
+
+	return set, args, nil
+}
+
+//Increment updates counters of counter views; other views are incremented with light weight transactions
+func (d *CasandraTypedDriver) Increment(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	if err := checkIncrement(r); err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	records := make([]*Record, len(r.ViewMods))
+
+	for i, v := range r.ViewMods {
+		rec, err := d.increment(r.Partition, &v)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		records[i] = rec
+	}
+
+	return &DBResponse{Status: 200, Records: records}
+}
+
+func (d *CasandraTypedDriver) increment(partition int64, view *ViewMod) (*Record, error) {
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := buildKey(view.PartitionKey, view.ClusterKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names, deltas, err := d.fieldColumns(vs, view.Values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(FieldSchemes, len(names))
+
+	for i, n := range names {
+		fields[i] = vs.Field(n)
+
+		if t := fields[i].Type; t != "int" && t != "float" && t != "counter" {
+			return nil, fmt.Errorf("view %v: field %v is not numeric", vs.Name, fields[i].Name)
+		}
+	}
+
+	var rec *Record
+
+	err = d.countWrite(vs, partition, &view.ViewView, func() (err error) {
+		rec, err = d.incrementFields(vs, partition, fields, deltas, key, view)
+		return err
+	})
+
+	return rec, err
+}
+
+//incrementFields updates counters or, for views without counters, adds deltas with light weight transactions.
+//A counter update creates a missing record, as other drivers do; returned counter values are read after the
+//update and are not atomic with it: they may include concurrent increments of other clients
+func (d *CasandraTypedDriver) incrementFields(vs *ViewScheme, partition int64, fields FieldSchemes, deltas []interface{}, key string, view *ViewMod) (*Record, error) {
+	if !vs.Counters() {
+		return d.incrementLw(vs, partition, fields, deltas, key, view)
+	}
+
+	if view.TTL > 0 {
+		return nil, fmt.Errorf("view %v: counters do not expire, TTL can not be given", vs.Name)
+	}
+
+	where, keyArgs, err := d.keyCondition(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	set := make([]string, len(fields))
+
+	for i, f := range fields {
+		set[i] = fmt.Sprintf("%v = %v + ?", f.Column, f.Column)
+	}
+
+	// counter updates are not idempotent
+	q := fmt.Sprintf("UPDATE %v SET %v WHERE %v", d.table(vs), strings.Join(set, ", "), where)
+
+	if err := d.session.query(q, append(deltas, keyArgs...)...).Exec(); err != nil {
+		return nil, err
+	}
+
+	// cassandra does not return counter values, they are read right after the update, not atomically
+	dest := scanDest(fields, nil)
+	q = fmt.Sprintf("SELECT %v FROM %v WHERE %v", columnList(fields), d.table(vs), where)
+
+	if err := d.session.idempotent(q, keyArgs...).Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	return &Record{Key: key, Values: fieldValues(fields, dest)}, nil
+}
+
+//incrementLw reads fields and writes sums on condition the fields are not changed since; a missing
+//record is inserted. The record keeps its remaining TTL unless the view gives one. Attempts are
+//repeated on concurrent changes.
+func (d *CasandraTypedDriver) incrementLw(vs *ViewScheme, partition int64, fields FieldSchemes, deltas []interface{}, key string, view *ViewMod) (*Record, error) {
+	if err := checkTTL(view.TTL); err != nil {
+		return nil, err
+	}
+
+	keyCols, keyArgs, err := d.keyColumns(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	where, _, _ := d.keyCondition(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	increments := map[string]interface{}{}
+
+	var indexed FieldSchemes
+
+	for i, f := range fields {
+		increments[f.Name] = deltas[i]
+
+		if d.indexes.indexed(vs.Name, f.Name) {
+			indexed = append(indexed, f)
+		}
+	}
+
+	for attempt := 0; attempt < LWRepeatCount; attempt++ {
+		dest := scanDest(fields, vs.Fields)
+		q := fmt.Sprintf("SELECT %v FROM %v WHERE %v", selectList(fields, vs.Fields), d.table(vs), where)
+		err := d.session.query(q, keyArgs...).Scan(dest...)
+
+		if err != nil && err != gocql.ErrNotFound {
+			return nil, err
+		}
+
+		exists := err == nil
+		current := fieldValues(fields, dest)
+
+		ttl, err := d.ttls.modifyTTL(view, exists, recordTTL(dest[len(fields):]))
+
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]interface{}{}
+
+		for k, v := range current {
+			values[k] = v
+		}
+
+		if _, err := incrementValues(values, increments); err != nil {
+			return nil, err
+		}
+
+		// names are sorted, as fields are
+		names, sums, err := d.fieldColumns(vs, values)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var stmt string
+		var args []interface{}
+
+		if exists {
+			set := make([]string, len(names))
+			cond := make([]string, len(fields))
+
+			for i, n := range names {
+				set[i] = n + " = ?"
+				cond[i] = n + " = ?"
+			}
+
+			args = append(append([]interface{}{ttl}, sums...), keyArgs...)
+
+			for _, f := range fields {
+				args = append(args, current[f.Name])
+			}
+
+			stmt = fmt.Sprintf("UPDATE %v USING TTL ? SET %v WHERE %v IF %v", d.table(vs), strings.Join(set, ", "), where, strings.Join(cond, " AND "))
+		} else {
+			cols := append(append([]string{}, keyCols...), names...)
+			args = append(append(append([]interface{}{}, keyArgs...), sums...), ttl)
+			stmt = fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v) IF NOT EXISTS USING TTL ?", d.table(vs), strings.Join(cols, ", "), placeholders(len(cols)))
+		}
+
+		if err := d.writeIndex(vs, keyCols, keyArgs, indexed, values, ttl); err != nil {
+			return nil, err
+		}
+
+		applied, err := d.session.query(stmt, args...).MapScanCAS(map[string]interface{}{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if applied {
+			if err := d.cleanIndex(vs, keyCols, keyArgs, indexed, current, values); err != nil {
+				return nil, err
+			}
+
+			result := map[string]interface{}{}
+
+			for i, f := range fields {
+				result[f.Name] = sums[i]
+			}
+
+			return &Record{Key: key, Values: result, TTL: ttl}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("record %v of view %v is changed concurrently; increment is not applied in %v attempts", key, vs.Name, LWRepeatCount)
+}
+
+//Scan reads records of one view partition ordered by cluster key; ViewView.ClusterKey sets the start
+func (d *CasandraTypedDriver) Scan(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	view, err := checkScan(r)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	where, args, err := d.keyCondition(vs, r.Partition, view.PartitionKey, view.ClusterKey, true)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	return executeScan(view, func(names []string, pageState []byte, limit int) ([]*Record, []byte, error) {
+		fields, err := d.projection(vs, names)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cols := append(append(append(FieldSchemes{}, vs.PartitionKey...), vs.ClusterKey...), fields...)
+		q := fmt.Sprintf("SELECT %v FROM %v WHERE %v", selectList(cols, fields), d.table(vs), where)
+
+		// setting page state (even empty) turns automatic paging off, so one page is read
+		iter := d.session.idempotent(q, args...).PageSize(limit).PageState(pageState).Iter()
+		next := iter.PageState()
+
+		var records []*Record
+
+		for {
+			dest := scanDest(cols, fields)
+
+			if !iter.Scan(dest...) {
+				break
+			}
+
+			pkey := fieldValues(vs.PartitionKey, dest[:len(vs.PartitionKey)])
+			ckey := fieldValues(vs.ClusterKey, dest[len(vs.PartitionKey):len(vs.PartitionKey)+len(vs.ClusterKey)])
+			key, _ := buildKey(pkey, ckey)
+
+			values := dest[len(vs.PartitionKey)+len(vs.ClusterKey) : len(cols)]
+
+			records = append(records, &Record{Key: key, Values: fieldValues(fields, values), TTL: recordTTL(dest[len(cols):])})
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, nil, err
+		}
+
+		return records, next, nil
+	})
+}
+
+//Delete s.e.
+func (d *CasandraTypedDriver) Delete(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	if r == nil {
+		return &DBResponse{Status: 400, Error: "wrong request data"}
+	}
+
+	for _, v := range r.ViewViews {
+		vs, err := d.view(v.ViewType)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		where, args, err := d.keyCondition(vs, r.Partition, v.PartitionKey, v.ClusterKey, false)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		err = d.indexedWrite(vs, r.Partition, &v, nil, true, 0, func() error {
+			return d.session.idempotent(fmt.Sprintf("DELETE FROM %v WHERE %v", d.table(vs), where), args...).Exec()
+		})
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+	}
+
+	return &DBResponse{Status: 200}
+}
+
+//migrateView creates view table or adds columns declared in the scheme since; other changes
+//(key columns, types) can not be applied to existing table and are reported as errors
+func (d *CasandraTypedDriver) migrateView(vs *ViewScheme) error {
+	ks, err := d.session.KeyspaceMetadata(d.keyspace)
+
+	if err != nil {
+		return err
+	}
+
+	table, exists := ks.Tables[vs.Name]
+
+	if !exists {
+		d.logger.Info("Creating table %v", d.table(vs))
+		return d.migrator.exec(false, nil, d.tableDDL(vs))
+	}
+
+	keys := append(FieldSchemes{{Name: "partition", Type: "int", Column: "partition"}}, vs.PartitionKey...)
+
+	if len(table.PartitionKey) != len(keys) || len(table.ClusteringColumns) != len(vs.ClusterKey) {
+		return fmt.Errorf("table %v: primary key differs from the scheme; view should be renamed or table dropped", d.table(vs))
+	}
+
+	for i, c := range table.PartitionKey {
+		if c.Name != keys[i].Column {
+			return fmt.Errorf("table %v: partition key column %v differs from the scheme column %v", d.table(vs), c.Name, keys[i].Column)
+		}
+	}
+
+	for i, c := range table.ClusteringColumns {
+		if c.Name != vs.ClusterKey[i].Column {
+			return fmt.Errorf("table %v: clustering column %v differs from the scheme column %v", d.table(vs), c.Name, vs.ClusterKey[i].Column)
+		}
+	}
+
+	for _, f := range vs.Fields {
+		c, ok := table.Columns[f.Column]
+
+		if !ok {
+			q := fmt.Sprintf("ALTER TABLE %v ADD %v %v", d.table(vs), f.Column, f.cqlType())
+			d.logger.Info("Migrating: %v", q)
+
+			if err := d.migrator.exec(false, nil, q); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if t := strings.ToLower(c.Validator); t != "" && strings.ReplaceAll(t, " ", "") != strings.ReplaceAll(f.cqlType(), " ", "") {
+			return fmt.Errorf("table %v: column %v has type %v, scheme declares %v", d.table(vs), f.Name, t, f.cqlType())
+		}
+	}
+
+	return nil
+}
+
+func (d *CasandraTypedDriver) tableDDL(vs *ViewScheme) string {
+	cols := []string{"partition bigint"}
+
+	for _, f := range vs.Columns() {
+		cols = append(cols, f.Column+" "+f.cqlType())
+	}
+
+	pk := "(partition, " + columnList(vs.PartitionKey) + ")"
+
+	if len(vs.ClusterKey) > 0 {
+		pk += ", " + columnList(vs.ClusterKey)
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v ( %v, PRIMARY KEY (%v) )", d.table(vs), strings.Join(cols, ", "), pk)
+}
+
+func (d *CasandraTypedDriver) table(vs *ViewScheme) string {
+	return d.keyspace + "." + vs.Name
+}
+
+//projection returns fields of the projection (columns to select), all view fields when none is given
+func (d *CasandraTypedDriver) projection(vs *ViewScheme, names []string) (FieldSchemes, error) {
+	if len(names) == 0 {
+		return vs.Fields, nil
+	}
+
+	fields := make(FieldSchemes, 0, len(names))
+
+	for _, n := range names {
+		f := vs.Field(n)
+
+		if f == nil {
+			return nil, fmt.Errorf("view %v: projected field %q is not declared in the scheme", vs.Name, n)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+func (d *CasandraTypedDriver) view(name string) (*ViewScheme, error) {
+	if vs := d.scheme.View(name); vs != nil {
+		return vs, nil
+	}
+
+	return nil, fmt.Errorf("view %q is not declared in the scheme", name)
+}
+
+//keyColumns returns key column names and values; for scans cluster key may be given partially (a prefix)
+func (d *CasandraTypedDriver) keyColumns(vs *ViewScheme, partition int64, pkey, ckey map[string]interface{}, scan bool) ([]string, []interface{}, error) {
+	cols := []string{"partition"}
+	args := []interface{}{partition}
+
+	for _, f := range vs.PartitionKey {
+		v, err := columnValue(f, pkey[f.Name])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if v == nil {
+			return nil, nil, fmt.Errorf("view %v: partition key field %v is missing", vs.Name, f.Name)
+		}
+
+		cols = append(cols, f.Column)
+		args = append(args, v)
+	}
+
+	for _, f := range vs.ClusterKey {
+		v, err := columnValue(f, ckey[f.Name])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if v == nil {
+			if scan {
+				break
+			}
+
+			return nil, nil, fmt.Errorf("view %v: cluster key field %v is missing", vs.Name, f.Name)
+		}
+
+		cols = append(cols, f.Column)
+		args = append(args, v)
+	}
+
+	return cols, args, nil
+}
+
+//keyCondition builds WHERE clause; for scans the given cluster key prefix is a start bound
+func (d *CasandraTypedDriver) keyCondition(vs *ViewScheme, partition int64, pkey, ckey map[string]interface{}, scan bool) (string, []interface{}, error) {
+	cols, args, err := d.keyColumns(vs, partition, pkey, ckey, scan)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	pkLen := 1 + len(vs.PartitionKey)
+	cond := make([]string, 0, pkLen+1)
+
+	for _, c := range cols[:pkLen] {
+		cond = append(cond, c+" = ?")
+	}
+
+	if ck := cols[pkLen:]; len(ck) > 0 {
+		if scan {
+			cond = append(cond, fmt.Sprintf("(%v) >= (%v)", strings.Join(ck, ", "), placeholders(len(ck))))
+		} else {
+			for _, c := range ck {
+				cond = append(cond, c+" = ?")
+			}
+		}
+	}
+
+	return strings.Join(cond, " AND "), args, nil
+}
+
+//fieldColumns returns columns (sorted, so statements repeat and stay prepared) and values of the given fields
+func (d *CasandraTypedDriver) fieldColumns(vs *ViewScheme, values map[string]interface{}) ([]string, []interface{}, error) {
+	names := make([]string, 0, len(values))
+
+	for n := range values {
+		if vs.Field(n) == nil {
+			return nil, nil, fmt.Errorf("view %v: field %q is not declared in the scheme", vs.Name, n)
+		}
+
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	cols := make([]string, len(names))
+	args := make([]interface{}, len(names))
+
+	for i, n := range names {
+		f := vs.Field(n)
+		v, err := columnValue(f, values[n])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cols[i] = f.Column
+		args[i] = v
+	}
+
+	return cols, args, nil
+}
+
+func fieldValues(fields FieldSchemes, dest []interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+
+	for i, f := range fields {
+		if v, ok := destValue(dest[i]); ok {
+			values[f.Name] = v
+		}
+	}
+
+	return values
+}
+
+//selectList selects the given columns followed by TTL of every field; TTL of counters and collections is not selectable
+func selectList(cols FieldSchemes, fields FieldSchemes) string {
+	list := columnList(cols)
+
+	for _, f := range fields {
+		if f.expires() {
+			list += ", TTL(" + f.Column + ")"
+		}
+	}
+
+	return list
+}
+
+func scanDest(cols FieldSchemes, fields FieldSchemes) []interface{} {
+	dest := make([]interface{}, 0, len(cols)+len(fields))
+
+	for _, c := range cols {
+		dest = append(dest, columnDest(c))
+	}
+
+	for _, f := range fields {
+		if f.expires() {
+			dest = append(dest, new(*int64))
+		}
+	}
+
+	return dest
+}
+
+//recordTTL is the longest TTL of the record fields, since the record lives while any of them does
+func recordTTL(dest []interface{}) int64 {
+	var ttl int64
+
+	for _, d := range dest {
+		if v, ok := destValue(d); ok && v.(int64) > ttl {
+			ttl = v.(int64)
+		}
+	}
+
+	return ttl
+}
+
+func columnList(fields FieldSchemes) string {
+	names := make([]string, len(fields))
+
+	for i, f := range fields {
+		names[i] = f.Column
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+//traced returns the driver whose queries are traced within the span of the request, if any
+func (d *CasandraTypedDriver) traced(r *DBRequest) *CasandraTypedDriver {
+	session := d.session.traced(r)
+
+	if session == d.session {
+		return d
+	}
+
+	c := *d
+	c.session = session
+
+	return &c
+}
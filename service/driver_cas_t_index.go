@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+//Lookup reads a page of view records by value of an indexed field; records are read from the view table
+//by keys of the index table <view>_by_<field>, PRIMARY KEY ((partition, value), key columns...)
+func (d *CasandraTypedDriver) Lookup(r *DBRequest) *DBResponse {
+	d = d.traced(r)
+
+	view, err := checkLookup(r, d.indexes)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	vs, err := d.view(view.ViewType)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	value, err := columnValue(vs.Field(view.Index), view.IndexValue)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	pageState, err := decodePageState(view.PageState)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	keys := append(append(FieldSchemes{}, vs.PartitionKey...), vs.ClusterKey...)
+	q := fmt.Sprintf("SELECT %v FROM %v WHERE partition = ? AND value = ?", columnList(keys), d.indexTable(vs, view.Index))
+
+	// setting page state (even empty) turns automatic paging off, so one page is read
+	iter := d.session.idempotent(q, r.Partition, value).PageSize(pageLimit(view)).PageState(pageState).Iter()
+	next := iter.PageState()
+
+	var found []ViewView
+
+	for dest := scanDest(keys, nil); iter.Scan(dest...); dest = scanDest(keys, nil) {
+		v := ViewView{ViewType: vs.Name, PartitionKey: map[string]interface{}{}, Fields: lookupProjection(view)}
+
+		for i, f := range keys {
+			k, _ := destValue(dest[i])
+
+			if i < len(vs.PartitionKey) {
+				v.PartitionKey[f.Name] = k
+			} else {
+				if v.ClusterKey == nil {
+					v.ClusterKey = map[string]interface{}{}
+				}
+
+				v.ClusterKey[f.Name] = k
+			}
+		}
+
+		found = append(found, v)
+	}
+
+	if err := iter.Close(); err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	var (
+		records []*Record
+		saved   int64
+	)
+
+	for i := range found {
+		rec, err := d.read(r.Partition, &found[i])
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		if ok, s := lookupMatch(rec, view); ok {
+			saved += s
+			records = append(records, rec)
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records, PageState: encodePageState(next), SavedBytes: saved}
+}
+
+//indexedWrite makes a write of the record and maintains index entries of the indexed fields among values
+//(all indexed fields when all is set, e.g. for deletes and replaces): entries of new values are written
+//before the record, entries of old values are deleted after it. The write is counted in partition
+//statistics.
+func (d *CasandraTypedDriver) indexedWrite(vs *ViewScheme, partition int64, view *ViewView, values map[string]interface{}, all bool, ttl int64, write func() error) error {
+	var fields FieldSchemes
+
+	for _, name := range d.indexes[vs.Name] {
+		if _, ok := values[name]; ok || all {
+			fields = append(fields, vs.Field(name))
+		}
+	}
+
+	if len(fields) == 0 {
+		return d.countWrite(vs, partition, view, write)
+	}
+
+	keyCols, keyArgs, err := d.keyColumns(vs, partition, view.PartitionKey, view.ClusterKey, false)
+
+	if err != nil {
+		return err
+	}
+
+	where, _, _ := d.keyCondition(vs, partition, view.PartitionKey, view.ClusterKey, false)
+	dest := scanDest(fields, nil)
+	q := fmt.Sprintf("SELECT %v FROM %v WHERE %v", columnList(fields), d.table(vs), where)
+
+	if err := d.session.idempotent(q, keyArgs...).Scan(dest...); err != nil && err != gocql.ErrNotFound {
+		return err
+	}
+
+	old := fieldValues(fields, dest)
+
+	if err := d.writeIndex(vs, keyCols, keyArgs, fields, values, ttl); err != nil {
+		return err
+	}
+
+	if err := d.countWrite(vs, partition, view, write); err != nil {
+		return err
+	}
+
+	return d.cleanIndex(vs, keyCols, keyArgs, fields, old, values)
+}
+
+//writeIndex adds index entries of the field values; entries live as long as the written values
+func (d *CasandraTypedDriver) writeIndex(vs *ViewScheme, keyCols []string, keyArgs []interface{}, fields FieldSchemes, values map[string]interface{}, ttl int64) error {
+	for _, f := range fields {
+		if _, ok := indexValue(values[f.Name]); !ok {
+			continue
+		}
+
+		v, err := columnValue(f, values[f.Name])
+
+		if err != nil {
+			return err
+		}
+
+		cols := append([]string{"partition", "value"}, keyCols[1:]...)
+		args := append(append([]interface{}{keyArgs[0], v}, keyArgs[1:]...), ttl)
+		q := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v) USING TTL ?", d.indexTable(vs, f.Name), strings.Join(cols, ", "), placeholders(len(cols)))
+
+		if err := d.session.idempotent(q, args...).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//cleanIndex deletes index entries of old values which the record does not have any more
+func (d *CasandraTypedDriver) cleanIndex(vs *ViewScheme, keyCols []string, keyArgs []interface{}, fields FieldSchemes, old, values map[string]interface{}) error {
+	for _, f := range fields {
+		o, ok := indexValue(old[f.Name])
+
+		if !ok {
+			continue
+		}
+
+		if v, ok := indexValue(values[f.Name]); ok && v == o {
+			continue
+		}
+
+		cond := []string{"partition = ?", "value = ?"}
+
+		for _, c := range keyCols[1:] {
+			cond = append(cond, c+" = ?")
+		}
+
+		args := append([]interface{}{keyArgs[0], old[f.Name]}, keyArgs[1:]...)
+		q := fmt.Sprintf("DELETE FROM %v WHERE %v", d.indexTable(vs, f.Name), strings.Join(cond, " AND "))
+
+		if err := d.session.idempotent(q, args...).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//migrateIndexes creates index tables of the view
+func (d *CasandraTypedDriver) migrateIndexes(vs *ViewScheme) error {
+	for _, name := range vs.Indexes {
+		if err := d.migrator.exec(false, nil, d.indexDDL(vs, vs.Field(name))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *CasandraTypedDriver) indexDDL(vs *ViewScheme, f *FieldScheme) string {
+	cols := []string{"partition bigint", "value " + f.cqlType()}
+	keys := append(append(FieldSchemes{}, vs.PartitionKey...), vs.ClusterKey...)
+
+	for _, k := range keys {
+		cols = append(cols, k.Column+" "+k.cqlType())
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v ( %v, PRIMARY KEY ((partition, value), %v) )", d.indexTable(vs, f.Name), strings.Join(cols, ", "), columnList(keys))
+}
+
+func (d *CasandraTypedDriver) indexTable(vs *ViewScheme, field string) string {
+	return d.keyspace + "." + indexTable(vs.Name, field)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import "fmt"
+
+//castStatsTable is the counter table of cast partition statistics; it is created when they are tracked
+const castStatsTable = "partition_stats"
+
+//PartitionStats s.e.
+func (d *CasandraTypedDriver) PartitionStats(partition int64) (*PartitionStats, error) {
+	list, err := d.partitionsStats(&partition)
+
+	if err != nil || len(list) == 0 {
+		return &PartitionStats{Partition: partition}, err
+	}
+
+	return list[0], nil
+}
+
+//PartitionsStats s.e.
+func (d *CasandraTypedDriver) PartitionsStats() ([]*PartitionStats, error) {
+	return d.partitionsStats(nil)
+}
+
+func (d *CasandraTypedDriver) partitionsStats(partition *int64) ([]*PartitionStats, error) {
+	if !d.stats {
+		return nil, ErrStatsNotTracked
+	}
+
+	var (
+		p              int64
+		view           string
+		records, bytes int64
+		list           []*PartitionStats
+	)
+
+	q := d.session.idempotent(fmt.Sprintf("SELECT partition, view, records, bytes FROM %v", d.statsTable()))
+
+	if partition != nil {
+		q = d.session.idempotent(fmt.Sprintf("SELECT partition, view, records, bytes FROM %v WHERE partition = ?", d.statsTable()), *partition)
+	}
+
+	iter := q.Iter()
+	stats := map[int64]*PartitionStats{}
+
+	for iter.Scan(&p, &view, &records, &bytes) {
+		s, ok := stats[p]
+
+		if !ok {
+			s = &PartitionStats{Partition: p}
+			stats[p] = s
+			list = append(list, s)
+		}
+
+		s.add(view, records, bytes)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+//countWrite makes a write of the record and adds its change to partition statistics: record is read
+//before and after the write, since columns are written partially. Counters are estimates: concurrent
+//writes of a record may be counted twice and expired records are not subtracted.
+func (d *CasandraTypedDriver) countWrite(vs *ViewScheme, partition int64, view *ViewView, write func() error) error {
+	if !d.stats {
+		return write()
+	}
+
+	key := &ViewView{ViewType: view.ViewType, PartitionKey: view.PartitionKey, ClusterKey: view.ClusterKey}
+	before, err := d.read(partition, key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	after, err := d.read(partition, key)
+
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	var values map[string]interface{}
+
+	if before != nil {
+		size = recordSize(before.Values)
+	}
+
+	if after != nil {
+		values = after.Values
+	}
+
+	records, bytes := statsDelta(before != nil, size, values)
+
+	if records == 0 && bytes == 0 {
+		return nil
+	}
+
+	// counter updates are not idempotent
+	return d.session.query(fmt.Sprintf("UPDATE %v SET records = records + ?, bytes = bytes + ? WHERE partition = ? AND view = ?", d.statsTable()), records, bytes, partition, vs.Name).Exec()
+}
+
+//migrateStats creates the table of partition statistics when they are tracked
+func (d *CasandraTypedDriver) migrateStats() error {
+	if !d.stats {
+		return nil
+	}
+
+	if d.scheme.View(castStatsTable) != nil {
+		return fmt.Errorf("view %v of the scheme conflicts with the table of partition statistics; rename the view or turn %v off", castStatsTable, PartitionStatsAttribute)
+	}
+
+	return d.migrator.exec(false, nil, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v ( partition bigint, view text, records counter, bytes counter, PRIMARY KEY (partition, view) )", d.statsTable()))
+}
+
+func (d *CasandraTypedDriver) statsTable() string {
+	return d.keyspace + "." + castStatsTable
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/gocql/gocql"
+)
+
+//casandraUpdateRecord serves light weight Update of cas and casp drivers, conditional updates and updates
+//with collection operations: values are patched and written back on condition the record version is
+//not changed, so the update condition holds at the time of the write
+func casandraUpdateRecord(session *casandraSession, stmt casandraStatements, x *casandraIndex, ttls viewTTLs, partition int64, key string, view *ViewMod) error {
+	_, err := casandraModifyRecord(session, stmt, x, ttls, partition, key, view, func(values map[string]interface{}, exists bool) (map[string]interface{}, error) {
+		if !exists {
+			return nil, &notFoundError{partition, view.ViewType, key}
+		}
+
+		if err := checkCondition(view, key, values); err != nil {
+			return nil, err
+		}
+
+		patchValues(values, view.Values, view.Replace)
+
+		return nil, applyCollectionOps(values, view.Ops)
+	})
+
+	return err
+}
+
+//casandraPatchedRecord reads the record and applies the patch of an update which is not guarded by the
+//record version: the last writer wins, concurrent updates of the record may be lost and an update racing
+//a delete may write the record back. The returned record has the next version and the TTL of the view
+//or the remaining TTL of the record.
+func casandraPatchedRecord(session *casandraSession, stmt casandraStatements, ttls viewTTLs, partition int64, key string, view *ViewMod) (*Record, error) {
+	var (
+		b         []byte
+		version   int
+		remaining int64
+	)
+
+	err := session.idempotent(stmt.get, partition, view.ViewType, key).Scan(&b, &version, &remaining)
+
+	if err == gocql.ErrNotFound {
+		return nil, &notFoundError{partition, view.ViewType, key}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+
+	if len(b) > 0 && !view.Replace {
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, err
+		}
+	}
+
+	patchValues(values, view.Values, view.Replace)
+
+	ttl, err := ttls.modifyTTL(view, true, remaining)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{Key: key, Values: values, Version: version + 1, TTL: ttl}, nil
+}
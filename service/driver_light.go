@@ -4,7 +4,13 @@
 
 package service
 
-import "fmt"
+import "context"
+
+func init() {
+	RegisterDriver("light", func(logger *Logger) DBDriver {
+		return &LightDriver{logger: logger}
+	}, "light driver that just sends Ok status for all operations")
+}
 
 //LightDriver s.e.
 type LightDriver struct {
@@ -22,14 +28,19 @@ func (d *LightDriver) Info() string {
 }
 
 //Init s.e.
-func (d *LightDriver) Init(args map[string]string) error {
-	fmt.Println("light driver initialized")
+func (d *LightDriver) Init(cfg *Config) error {
+	d.logger.Info("light driver initialized")
 	return nil
 }
 
 //Free s.e.
 func (d *LightDriver) Free() error {
-	fmt.Println("light driver freed")
+	d.logger.Info("light driver freed")
+	return nil
+}
+
+//Ping s.e.
+func (d *LightDriver) Ping(ctx context.Context) error {
 	return nil
 }
 
@@ -62,3 +73,13 @@ func (d *LightDriver) Scan(r *DBRequest) *DBResponse {
 func (d *LightDriver) Delete(r *DBRequest) *DBResponse {
 	return &DBResponse{Status: 200}
 }
+
+//Increment s.e.
+func (d *LightDriver) Increment(r *DBRequest) *DBResponse {
+	return &DBResponse{Status: 200}
+}
+
+//Lookup s.e.
+func (d *LightDriver) Lookup(r *DBRequest) *DBResponse {
+	return &DBResponse{Status: 200}
+}
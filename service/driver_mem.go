@@ -4,15 +4,60 @@
 
 package service
 
-import "fmt"
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterDriver("mem", func(logger *Logger) DBDriver {
+		return &MemoryDriver{logger: logger}
+	}, "memory driver; records are kept in process memory",
+		Param{Name: ReapIntervalParam, Attribute: ReapIntervalAttribute, Env: ReapIntervalEnvironmentProperty, Default: "1s", Kind: KindDuration, MinDuration: time.Millisecond, Description: "interval of removing expired records"},
+	)
+}
 
 //MemoryDriver s.e.
 type MemoryDriver struct {
+	mu      sync.Mutex
 	storage map[string]interface{}
 
+	// expires holds expiry of records written with TTL, expiry is the queue of them ordered by time
+	expires map[memKey]time.Time
+	expiry  memExpiryQueue
+	ttls    viewTTLs
+	stop    chan struct{}
+
+	// indexes are the indexed fields of views, index maps indexed values to record keys
+	indexes viewIndexes
+	index   map[memIndexKey]map[string]bool
+
+	// stats are statistics of partitions, changed together with records; expired records are reaped before
+	// they are read, as reads do not return them
+	stats map[string]*PartitionStats
+
 	logger *Logger
 }
 
+type memKey struct {
+	partition string
+	table     string
+	key       string
+}
+
+type memIndexKey struct {
+	partition string
+	table     string
+	field     string
+	value     string
+}
+
 //Name s.e.
 func (d *MemoryDriver) Name() string {
 	return "Memory drivwer"
@@ -24,30 +69,90 @@ func (d *MemoryDriver) Info() string {
 }
 
 //Init s.e.
-func (d *MemoryDriver) Init(args map[string]string) error {
-	fmt.Println("memory driver initialized")
+func (d *MemoryDriver) Init(cfg *Config) error {
+	scheme, err := loadOptionalScheme(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	d.ttls = schemeTTLs(scheme)
+	d.indexes = schemeIndexes(scheme)
+
+	d.logger.Info("memory driver initialized")
 
 	d.storage = map[string]interface{}{}
+	d.expires = map[memKey]time.Time{}
+	d.expiry = nil
+	d.index = map[memIndexKey]map[string]bool{}
+	d.stats = map[string]*PartitionStats{}
+	d.stop = make(chan struct{})
+
+	// configs built in code are not validated; without the reaper expired records are only hidden from reads
+	if interval := cfg.Duration(ReapIntervalParam); interval > 0 {
+		go d.reaper(interval, d.stop)
+	}
 
 	return nil
 }
 
 //Free s.e.
 func (d *MemoryDriver) Free() error {
-	fmt.Println("memory driver freed")
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+
+	d.logger.Info("memory driver freed")
 	return nil
 }
 
+//Ping checks that the storage is initialized and not locked up
+func (d *MemoryDriver) Ping(ctx context.Context) error {
+	locked := make(chan bool, 1)
+
+	go func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		locked <- d.storage != nil
+	}()
+
+	select {
+	case ok := <-locked:
+		if !ok {
+			return fmt.Errorf("memory driver is not initialized")
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("memory driver storage is locked: %v", ctx.Err())
+	}
+}
+
 //Read s.e.
 func (d *MemoryDriver) Clean(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	d.storage = map[string]interface{}{}
+	d.expires = map[memKey]time.Time{}
+	d.expiry = nil
+	d.index = map[memIndexKey]map[string]bool{}
+	d.stats = map[string]*PartitionStats{}
 
 	return &DBResponse{Status: 200}
 }
 
 //Read s.e.
 func (d *MemoryDriver) Read(r *DBRequest) *DBResponse {
-	var records []*Record
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		records []*Record
+		saved   int64
+	)
 
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
@@ -64,12 +169,13 @@ func (d *MemoryDriver) Read(r *DBRequest) *DBResponse {
 			}
 
 			if rec != nil {
+				saved += projectRecord(rec, v.Fields)
 				records[i] = rec
 			}
 		}
 	}
 
-	return &DBResponse{Status: 200, Records: records}
+	return &DBResponse{Status: 200, Records: records, SavedBytes: saved}
 }
 
 func (d *MemoryDriver) read(partition int64, view *ViewView) (*Record, error) {
@@ -87,18 +193,35 @@ func (d *MemoryDriver) read(partition int64, view *ViewView) (*Record, error) {
 		return nil, err
 	}
 
-	if t := d.get(fmt.Sprintf("%v", partition), view.ViewType, key); t != nil {
-		return &Record{
-			Key:    key,
-			Values: t.(map[string]interface{}),
-		}, nil
+	return d.record(fmt.Sprintf("%v", partition), view.ViewType, key), nil
+}
+
+//record returns a copy of the record; nil if not exists
+func (d *MemoryDriver) record(partition, table, key string) *Record {
+	t := d.get(partition, table, key)
+
+	if t == nil {
+		return nil
+	}
+
+	values := map[string]interface{}{}
+
+	for k, v := range t.(map[string]interface{}) {
+		values[k] = v
 	}
 
-	return nil, nil
+	return &Record{
+		Key:    key,
+		Values: values,
+		TTL:    d.ttl(memKey{partition, table, key}),
+	}
 }
 
 //Insert s.e.
 func (d *MemoryDriver) Insert(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
@@ -127,12 +250,19 @@ func (d *MemoryDriver) insert(partition int64, view *ViewMod) error {
 		return err
 	}
 
+	ttl, err := d.ttls.ttl(view)
+
+	if err != nil {
+		return err
+	}
+
 	pnum := fmt.Sprintf("%d", partition)
 
 	r := d.get(pnum, view.ViewType, key)
 
 	if r == nil {
 		d.set(pnum, view.ViewType, key, view.Values)
+		d.expire(memKey{pnum, view.ViewType, key}, ttl)
 	}
 
 	return nil
@@ -140,6 +270,9 @@ func (d *MemoryDriver) insert(partition int64, view *ViewMod) error {
 
 //Update s.e.
 func (d *MemoryDriver) Update(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
 	}
@@ -149,7 +282,7 @@ func (d *MemoryDriver) Update(r *DBRequest) *DBResponse {
 			err := d.update(r.Partition, &v)
 
 			if err != nil {
-				return &DBResponse{Status: 400, Error: err.Error()}
+				return updateResponse(err)
 			}
 		}
 	}
@@ -172,38 +305,226 @@ func (d *MemoryDriver) update(partition int64, view *ViewMod) error {
 		return err
 	}
 
+	if err := checkTTL(view.TTL); err != nil {
+		return err
+	}
+
 	p := fmt.Sprintf("%v", partition)
 
 	r := d.get(p, view.ViewType, key)
 
 	if r == nil {
-		return fmt.Errorf("Record with key %v not exists int partition %v table %v", key, partition, view.ViewType)
+		return &notFoundError{partition, view.ViewType, key}
 	}
 
-	if len(view.Values) > 0 {
-		newValues := r.(map[string]interface{})
+	if err := checkUpdate(view); err != nil {
+		return err
+	}
+
+	if err := checkCondition(view, key, r.(map[string]interface{})); err != nil {
+		return err
+	}
+
+	if len(view.Values) > 0 || len(view.Ops) > 0 || view.Replace {
+		// changes are made on a copy, so a failed operation leaves the record as is
+		newValues := map[string]interface{}{}
 
-		for k, v := range view.Values {
+		for k, v := range r.(map[string]interface{}) {
 			newValues[k] = v
 		}
 
+		patchValues(newValues, view.Values, view.Replace)
+
+		if err := applyCollectionOps(newValues, view.Ops); err != nil {
+			return err
+		}
+
 		d.set(p, view.ViewType, key, newValues)
 	}
 
+	// without TTL the record keeps its expiry
+	if view.TTL > 0 {
+		d.expire(memKey{p, view.ViewType, key}, view.TTL)
+	}
+
 	return nil
 }
 
-//Scan s.e.
+//Increment s.e.
+func (d *MemoryDriver) Increment(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := checkIncrement(r); err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	records := make([]*Record, len(r.ViewMods))
+
+	for i, v := range r.ViewMods {
+		rec, err := d.increment(r.Partition, &v)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		records[i] = rec
+	}
+
+	return &DBResponse{Status: 200, Records: records}
+}
+
+//increment creates missing record; values are checked before the record is changed
+func (d *MemoryDriver) increment(partition int64, view *ViewMod) (*Record, error) {
+	key, err := buildKey(view.PartitionKey, view.ClusterKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTTL(view.TTL); err != nil {
+		return nil, err
+	}
+
+	p := fmt.Sprintf("%v", partition)
+	values := map[string]interface{}{}
+	t := d.get(p, view.ViewType, key)
+
+	if t != nil {
+		for k, v := range t.(map[string]interface{}) {
+			values[k] = v
+		}
+	}
+
+	result, err := incrementValues(values, view.Values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	k := memKey{p, view.ViewType, key}
+
+	d.set(p, view.ViewType, key, values)
+
+	// an existing record keeps its expiry unless TTL is given, a created one gets the view default
+	if view.TTL > 0 || t == nil {
+		ttl, _ := d.ttls.modifyTTL(view, t != nil, 0)
+		d.expire(k, ttl)
+	}
+
+	return &Record{Key: key, Values: result, TTL: d.ttl(k)}, nil
+}
+
+//Scan reads records of the view whose keys start with the partition key in order of keys; ClusterKey
+//sets the start, page state is the last returned key
 func (d *MemoryDriver) Scan(r *DBRequest) *DBResponse {
-	if r == nil {
-		return &DBResponse{Status: 400, Error: "wrong request data"}
+	view, err := checkScan(r)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
 	}
 
-	return &DBResponse{Status: 200, Error: "Scan method not implemented yet"}
+	p := fmt.Sprintf("%v", r.Partition)
+	prefix, _ := buildKey(view.PartitionKey, nil)
+	start, _ := buildKey(view.PartitionKey, view.ClusterKey)
+
+	return executeScan(view, func(fields []string, pageState []byte, limit int) ([]*Record, []byte, error) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		var keys []string
+
+		if pt, ok := d.storage[p].(map[string]interface{}); ok {
+			t, _ := pt[view.ViewType].(map[string]interface{})
+
+			for k := range t {
+				if strings.HasPrefix(k, prefix) && k >= start && k > string(pageState) {
+					keys = append(keys, k)
+				}
+			}
+		}
+
+		sort.Strings(keys)
+
+		var (
+			records []*Record
+			next    []byte
+		)
+
+		for i, k := range keys {
+			if rec := d.record(p, view.ViewType, k); rec != nil {
+				records = append(records, rec)
+			}
+
+			if len(records) == limit && i < len(keys)-1 {
+				next = []byte(k)
+				break
+			}
+		}
+
+		return records, next, nil
+	})
+}
+
+//Lookup returns records by index entries ordered by key; page state is the last returned key
+func (d *MemoryDriver) Lookup(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	view, err := checkLookup(r, d.indexes)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	after, err := decodePageState(view.PageState)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	p := fmt.Sprintf("%v", r.Partition)
+	value, _ := indexValue(view.IndexValue)
+	entries := d.index[memIndexKey{p, view.ViewType, view.Index, value}]
+	keys := make([]string, 0, len(entries))
+
+	for k := range entries {
+		if k > string(after) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var (
+		records []*Record
+		saved   int64
+		next    string
+	)
+
+	for i, k := range keys {
+		rec := d.record(p, view.ViewType, k)
+
+		if rec == nil {
+			continue
+		}
+
+		saved += projectRecord(rec, view.Fields)
+		records = append(records, rec)
+
+		if len(records) == pageLimit(view) && i < len(keys)-1 {
+			next = encodePageState([]byte(k))
+			break
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records, PageState: next, SavedBytes: saved}
 }
 
 //Delete s.e.
 func (d *MemoryDriver) Delete(r *DBRequest) *DBResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	if r == nil {
 		return &DBResponse{Status: 400, Error: "wrong request data"}
@@ -242,13 +563,19 @@ func (d *MemoryDriver) delete(partition int64, view *ViewView) error {
 	r := d.get(p, view.ViewType, key)
 
 	if r != nil {
-		d.set(p, view.ViewType, key, nil)
+		d.remove(p, view.ViewType, key)
+		d.expire(memKey{p, view.ViewType, key}, 0)
 	}
 
 	return nil
 }
 
+//get returns record values; expired records which are not reaped yet are not returned
 func (d *MemoryDriver) get(partition string, table string, key string) interface{} {
+	if at, ok := d.expires[memKey{partition, table, key}]; ok && !time.Now().Before(at) {
+		return nil
+	}
+
 	if p, ok := d.storage[partition]; ok {
 		if t, ok := p.(map[string]interface{})[table]; ok {
 			if v, ok := t.(map[string]interface{})[key]; ok {
@@ -260,6 +587,110 @@ func (d *MemoryDriver) get(partition string, table string, key string) interface
 	return nil
 }
 
+func (d *MemoryDriver) remove(partition string, table string, key string) {
+	if p, ok := d.storage[partition]; ok {
+		if t, ok := p.(map[string]interface{})[table]; ok {
+			if old, ok := t.(map[string]interface{})[key]; ok {
+				d.reindex(partition, table, key, old, nil)
+				d.count(partition, table, old, nil)
+			}
+
+			delete(t.(map[string]interface{}), key)
+		}
+	}
+}
+
+//reindex moves record key from index entries of old values to the ones of new values; it is called
+//by every change of stored records, so indexes change together with records
+func (d *MemoryDriver) reindex(partition, table, key string, old interface{}, values map[string]interface{}) {
+	if len(d.indexes[table]) == 0 {
+		return
+	}
+
+	oldValues, _ := old.(map[string]interface{})
+
+	for f, v := range d.indexes.values(table, oldValues) {
+		k := memIndexKey{partition, table, f, v}
+		delete(d.index[k], key)
+
+		if len(d.index[k]) == 0 {
+			delete(d.index, k)
+		}
+	}
+
+	for f, v := range d.indexes.values(table, values) {
+		k := memIndexKey{partition, table, f, v}
+
+		if d.index[k] == nil {
+			d.index[k] = map[string]bool{}
+		}
+
+		d.index[k][key] = true
+	}
+}
+
+//count adds the change of a stored record to partition statistics; nil stored - the record is removed
+func (d *MemoryDriver) count(partition, table string, old, stored interface{}) {
+	var size int64
+
+	oldValues, existed := old.(map[string]interface{})
+
+	if existed {
+		size = recordSize(oldValues)
+	}
+
+	values, ok := stored.(map[string]interface{})
+
+	if ok && values == nil {
+		values = map[string]interface{}{}
+	}
+
+	records, bytes := statsDelta(existed, size, values)
+	s, ok := d.stats[partition]
+
+	if !ok {
+		p, _ := strconv.ParseInt(partition, 10, 64)
+		s = &PartitionStats{Partition: p}
+		d.stats[partition] = s
+	}
+
+	s.add(table, records, bytes)
+
+	if len(s.Views) == 0 {
+		delete(d.stats, partition)
+	}
+}
+
+//PartitionStats s.e.
+func (d *MemoryDriver) PartitionStats(partition int64) (*PartitionStats, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.reap(time.Now())
+
+	if s, ok := d.stats[fmt.Sprintf("%v", partition)]; ok {
+		return s.copy(), nil
+	}
+
+	return &PartitionStats{Partition: partition}, nil
+}
+
+//PartitionsStats s.e.
+func (d *MemoryDriver) PartitionsStats() ([]*PartitionStats, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.reap(time.Now())
+
+	list := make([]*PartitionStats, 0, len(d.stats))
+
+	for _, s := range d.stats {
+		list = append(list, s.copy())
+	}
+
+	return list, nil
+}
+
 /*
 func (d *MemoryDriver) scan(partition int, table string, startKey string, count int) (map[string]Record, error) {
 	ps := fmt.Sprintf("%v", partition)
@@ -319,5 +750,79 @@ func (d *MemoryDriver) set(partition string, table string, key string, values ma
 		p.(map[string]interface{})[table] = t
 	}
 
+	d.reindex(partition, table, key, t.(map[string]interface{})[key], values)
+	d.count(partition, table, t.(map[string]interface{})[key], values)
 	t.(map[string]interface{})[key] = values
 }
+
+//expire sets record expiry; 0 ttl makes record persistent
+func (d *MemoryDriver) expire(k memKey, ttl int64) {
+	if ttl <= 0 {
+		delete(d.expires, k)
+		return
+	}
+
+	at := time.Now().Add(time.Duration(ttl) * time.Second)
+	d.expires[k] = at
+	heap.Push(&d.expiry, memExpiry{at: at, key: k})
+}
+
+//ttl returns remaining time to live in seconds, rounded up
+func (d *MemoryDriver) ttl(k memKey) int64 {
+	at, ok := d.expires[k]
+
+	if !ok {
+		return 0
+	}
+
+	return int64((time.Until(at) + time.Second - 1) / time.Second)
+}
+
+func (d *MemoryDriver) reaper(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			d.mu.Lock()
+			d.reap(now)
+			d.mu.Unlock()
+		}
+	}
+}
+
+//reap removes records expired by now; queue entries of records written again since are skipped
+func (d *MemoryDriver) reap(now time.Time) {
+	for len(d.expiry) > 0 && !now.Before(d.expiry[0].at) {
+		e := heap.Pop(&d.expiry).(memExpiry)
+
+		if at, ok := d.expires[e.key]; ok && at.Equal(e.at) {
+			delete(d.expires, e.key)
+			d.remove(e.key.partition, e.key.table, e.key.key)
+		}
+	}
+}
+
+type memExpiry struct {
+	at  time.Time
+	key memKey
+}
+
+//memExpiryQueue is a min heap of expiries
+type memExpiryQueue []memExpiry
+
+func (q memExpiryQueue) Len() int            { return len(q) }
+func (q memExpiryQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q memExpiryQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *memExpiryQueue) Push(x interface{}) { *q = append(*q, x.(memExpiry)) }
+
+func (q *memExpiryQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return e
+}
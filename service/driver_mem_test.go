@@ -0,0 +1,488 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//newTestMemoryDriver initializes the memory driver with the scheme, if given; it is freed by the end of
+//the test
+func newTestMemoryDriver(t *testing.T, scheme string) *MemoryDriver {
+	cfg := DefaultConfig()
+
+	if scheme != "" {
+		file := filepath.Join(t.TempDir(), "scheme.yml")
+		assert.Nil(t, ioutil.WriteFile(file, []byte(scheme), 0600))
+		assert.Nil(t, cfg.Set(SchemeParam, file))
+	}
+
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(cfg))
+	t.Cleanup(func() { d.Free() })
+
+	return d
+}
+
+func Test_MemoryDriverTTL(t *testing.T) {
+	d := newTestMemoryDriver(t, "views:\n  sessions:\n    ttl: 60\n    partitionkey:\n      id: {type: string}\n  usertable:\n    partitionkey:\n      value: {type: string}\n")
+
+	view := func(vtype, key string) ViewView {
+		return ViewView{ViewType: vtype, PartitionKey: map[string]interface{}{"value": key}}
+	}
+
+	read := func(v ViewView) *Record {
+		res := d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{v}})
+		assert.Equal(t, int64(200), res.Status)
+
+		return res.Records[0]
+	}
+
+	// a record without TTL gets the default of the view, if any
+	inserts := []struct {
+		view ViewView
+		ttl  int64
+		want int64
+	}{
+		{view("usertable", "a"), 10, 10},
+		{view("usertable", "b"), 0, 0},
+		{view("sessions", "c"), 0, 60},
+	}
+
+	for _, test := range inserts {
+		res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: test.view, Values: map[string]interface{}{"f": 1}, TTL: test.ttl}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, test.want, read(test.view).TTL, test.view.ViewType)
+	}
+
+	{
+		res := d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{
+			{ViewView: view("usertable", "b"), Values: map[string]interface{}{"f": 4}, TTL: 20},
+		}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, int64(20), read(view("usertable", "b")).TTL)
+
+		res = d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("usertable", "b"), TTL: -1}}})
+		assert.Equal(t, int64(400), res.Status)
+	}
+
+	{
+		d.mu.Lock()
+		d.reap(time.Now().Add(15 * time.Second))
+		d.mu.Unlock()
+
+		assert.Nil(t, read(view("usertable", "a")))
+		assert.NotNil(t, read(view("usertable", "b")))
+		assert.Equal(t, 2, len(d.expiry))
+
+		d.mu.Lock()
+		d.reap(time.Now().Add(time.Hour))
+		d.mu.Unlock()
+
+		assert.Nil(t, read(view("usertable", "b")))
+		assert.Nil(t, read(view("sessions", "c")))
+		assert.Equal(t, 0, len(d.expires))
+	}
+}
+
+func Test_MemoryDriverKeepTTL(t *testing.T) {
+	d := newTestMemoryDriver(t, "views:\n  sessions:\n    ttl: 60\n    partitionkey:\n      value: {type: string}\n")
+
+	view := func(vtype, key string) ViewView {
+		return ViewView{ViewType: vtype, PartitionKey: map[string]interface{}{"value": key}}
+	}
+
+	res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{
+		{ViewView: view("usertable", "a"), Values: map[string]interface{}{"f": 1}, TTL: 10},
+		{ViewView: view("usertable", "b"), Values: map[string]interface{}{"f": 1}},
+		{ViewView: view("sessions", "c"), Values: map[string]interface{}{"f": 1}, TTL: 5},
+	}})
+	assert.Equal(t, int64(200), res.Status)
+
+	expires := map[memKey]time.Time{}
+
+	for k, at := range d.expires {
+		expires[k] = at
+	}
+
+	tests := []struct {
+		name string
+		call func(r *DBRequest) *DBResponse
+	}{
+		{"update", d.Update},
+		{"increment", d.Increment},
+	}
+
+	for _, test := range tests {
+		res := test.call(&DBRequest{Partition: 1, ViewMods: []ViewMod{
+			{ViewView: view("usertable", "a"), Values: map[string]interface{}{"f": 2}},
+			{ViewView: view("usertable", "b"), Values: map[string]interface{}{"f": 2}},
+			{ViewView: view("sessions", "c"), Values: map[string]interface{}{"f": 2}},
+		}})
+		assert.Equal(t, int64(200), res.Status, test.name)
+		assert.Equal(t, expires, d.expires, test.name)
+	}
+
+	{
+		res := d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("sessions", "d"), Values: map[string]interface{}{"f": 1}}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, int64(60), res.Records[0].TTL)
+
+		res = d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("usertable", "a"), Values: map[string]interface{}{"f": 1}}}})
+		assert.Equal(t, int64(10), res.Records[0].TTL)
+
+		res = d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("usertable", "b"), Values: map[string]interface{}{"f": 3}, TTL: 30}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Len(t, d.expires, len(expires)+2)
+	}
+}
+
+func Test_MemoryDriverIncrement(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}}
+
+	{
+		res := d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"hits": float64(2)}}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, float64(2), res.Records[0].Values["hits"])
+
+		res = d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"hits": float64(-5), "bytes": 10}}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, float64(-3), res.Records[0].Values["hits"])
+		assert.Equal(t, float64(10), res.Records[0].Values["bytes"])
+	}
+
+	{
+		done := make(chan bool)
+
+		for i := 0; i < 10; i++ {
+			go func() {
+				for j := 0; j < 100; j++ {
+					d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"hits": 1}}}})
+				}
+
+				done <- true
+			}()
+		}
+
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+
+		res := d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{view}})
+		assert.Equal(t, float64(997), res.Records[0].Values["hits"])
+	}
+
+	{
+		d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"name": "x"}}}})
+
+		res := d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"name": 1, "hits": 1}}}})
+		assert.Equal(t, int64(400), res.Status)
+
+		res = d.Increment(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"hits": "1"}}}})
+		assert.Equal(t, int64(400), res.Status)
+
+		res = d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{view}})
+		assert.Equal(t, float64(997), res.Records[0].Values["hits"])
+	}
+}
+
+func Test_MemoryDriverCollections(t *testing.T) {
+	d := newTestMemoryDriver(t, "")
+
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}}
+	update := func(ops ...CollectionOp) *DBResponse {
+		return d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Ops: ops}}})
+	}
+	read := func() map[string]interface{} {
+		return d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{view}}).Records[0].Values
+	}
+
+	res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"name": "x"}}}})
+	assert.Equal(t, int64(200), res.Status)
+
+	// operations apply all or none: putting into a string field fails the append too
+	tests := []struct {
+		ops    []CollectionOp
+		status int64
+		log    []interface{}
+	}{
+		{[]CollectionOp{{Op: OpAppend, Field: "log", Values: []interface{}{"a"}}, {Op: OpPut, Field: "attrs", Entries: map[string]interface{}{"k": "v"}}}, 200, []interface{}{"a"}},
+		{[]CollectionOp{{Op: OpAppend, Field: "log", Values: []interface{}{"b"}}, {Op: OpPut, Field: "name", Entries: map[string]interface{}{"k": "v"}}}, 400, []interface{}{"a"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.status, update(test.ops...).Status)
+		assert.Equal(t, test.log, read()["log"])
+		assert.Equal(t, map[string]interface{}{"k": "v"}, read()["attrs"])
+	}
+
+	{
+		done := make(chan bool)
+
+		for i := 0; i < 10; i++ {
+			go func() {
+				for j := 0; j < 50; j++ {
+					update(CollectionOp{Op: OpAppend, Field: "log", Values: []interface{}{"c"}})
+				}
+
+				done <- true
+			}()
+		}
+
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+
+		assert.Equal(t, 501, len(read()["log"].([]interface{})))
+	}
+}
+
+func Test_MemoryDriverUpdatePatch(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}}
+	update := func(values map[string]interface{}, replace bool) {
+		res := d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: values, Replace: replace}}})
+		assert.Equal(t, int64(200), res.Status)
+	}
+	read := func() map[string]interface{} {
+		return d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{view}}).Records[0].Values
+	}
+
+	res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"name": "x", "age": 3, "addr": map[string]interface{}{"city": "c", "zip": "z"}}}}})
+	assert.Equal(t, int64(200), res.Status)
+
+	{
+		update(map[string]interface{}{"age": nil, "addr": map[string]interface{}{"zip": nil, "street": "s"}}, false)
+		assert.Equal(t, map[string]interface{}{"name": "x", "addr": map[string]interface{}{"city": "c", "street": "s"}}, read())
+	}
+
+	{
+		update(map[string]interface{}{"age": 4}, true)
+		assert.Equal(t, map[string]interface{}{"age": 4}, read())
+	}
+}
+
+func Test_MemoryDriverConditionalUpdate(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}}
+	update := func(values map[string]interface{}, cond *Condition) *DBResponse {
+		return d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: values, If: cond}}})
+	}
+
+	res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"status": "open"}}}})
+	assert.Equal(t, int64(200), res.Status)
+
+	{
+		open := &Condition{Field: "status", Op: CondEq, Value: "open"}
+
+		res := update(map[string]interface{}{"status": "closed"}, open)
+		assert.Equal(t, int64(200), res.Status)
+
+		res = update(map[string]interface{}{"status": "reopened"}, open)
+		assert.Equal(t, int64(409), res.Status)
+		assert.Equal(t, map[string]interface{}{"status": "closed"}, res.Records[0].Values)
+	}
+
+	{
+		done := make(chan int64)
+
+		for i := 0; i < 10; i++ {
+			go func() {
+				done <- update(map[string]interface{}{"owner": "x"}, &Condition{Field: "owner", Op: CondNotExists}).Status
+			}()
+		}
+
+		applied := 0
+
+		for i := 0; i < 10; i++ {
+			if <-done == 200 {
+				applied++
+			}
+		}
+
+		assert.Equal(t, 1, applied)
+	}
+}
+
+func Test_MemoryDriverLookup(t *testing.T) {
+	d := newTestMemoryDriver(t, "views:\n  users:\n    indexes: [city]\n    partitionkey:\n      id: {type: string}\n    fields:\n      city: {type: string}\n      name: {type: string}\n")
+
+	view := func(id string) ViewView {
+		return ViewView{ViewType: "users", PartitionKey: map[string]interface{}{"id": id}}
+	}
+	lookup := func(city, pageState string, limit int) *DBResponse {
+		return d.Lookup(&DBRequest{Partition: 1, ViewViews: []ViewView{{ViewType: "users", Index: "city", IndexValue: city, Limit: limit, PageState: pageState}}})
+	}
+	keys := func(res *DBResponse) []string {
+		keys := []string{}
+
+		for _, r := range res.Records {
+			keys = append(keys, r.Key)
+		}
+
+		return keys
+	}
+
+	res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{
+		{ViewView: view("a"), Values: map[string]interface{}{"city": "x", "name": "A"}},
+		{ViewView: view("b"), Values: map[string]interface{}{"city": "x", "name": "B"}},
+		{ViewView: view("c"), Values: map[string]interface{}{"city": "y", "name": "C"}, TTL: 10},
+	}})
+	assert.Equal(t, int64(200), res.Status)
+
+	// pages of one record continue from the page state of the previous one
+	pages := []struct {
+		keys []string
+		more bool
+	}{
+		{[]string{"a"}, true},
+		{[]string{"b"}, false},
+	}
+
+	pageState := ""
+
+	for _, page := range pages {
+		res := lookup("x", pageState, 1)
+		assert.Equal(t, page.keys, keys(res))
+		assert.Equal(t, page.more, res.PageState != "")
+
+		pageState = res.PageState
+	}
+
+	{
+		d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("a"), Values: map[string]interface{}{"city": "y"}}}})
+		d.Delete(&DBRequest{Partition: 1, ViewViews: []ViewView{view("b")}})
+
+		assert.Equal(t, []string{}, keys(lookup("x", "", 0)))
+		assert.Equal(t, []string{"a", "c"}, keys(lookup("y", "", 0)))
+	}
+
+	{
+		d.mu.Lock()
+		d.reap(time.Now().Add(time.Minute))
+		d.mu.Unlock()
+
+		assert.Equal(t, []string{"a"}, keys(lookup("y", "", 0)))
+		assert.Equal(t, int64(400), d.Lookup(&DBRequest{Partition: 1, ViewViews: []ViewView{{ViewType: "users", Index: "name", IndexValue: "A"}}}).Status)
+	}
+}
+
+func Test_MemoryDriverScan(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	for i, ck := range []string{"1", "2", "3", "4"} {
+		res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{
+			ViewView: ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}, ClusterKey: map[string]interface{}{"value": ck}},
+			Values:   map[string]interface{}{"n": i},
+		}}})
+		assert.Equal(t, int64(200), res.Status)
+	}
+
+	d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "b"}}, Values: map[string]interface{}{"n": 9}}}})
+
+	scan := func(view ViewView) *DBResponse {
+		view.ViewType = "usertable"
+		view.PartitionKey = map[string]interface{}{"value": "a"}
+
+		return d.Scan(&DBRequest{Partition: 1, ViewViews: []ViewView{view}})
+	}
+
+	{
+		res := scan(ViewView{ClusterKey: map[string]interface{}{"value": "2"}, Limit: 2})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, []string{"a2", "a3"}, []string{res.Records[0].Key, res.Records[1].Key})
+
+		res = scan(ViewView{Limit: 2, PageState: res.PageState})
+		assert.Equal(t, 1, len(res.Records))
+		assert.Equal(t, "a4", res.Records[0].Key)
+		assert.Empty(t, res.PageState)
+	}
+
+	{
+		res := scan(ViewView{Limit: 2, Filter: &Condition{Field: "n", Op: CondIn, Value: []interface{}{1, 3}}, Aggregates: []Aggregate{{Func: AggCount}, {Func: AggSum, Field: "n"}}})
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, map[string]interface{}{"count": int64(2), "sum(n)": float64(4)}, res.Records[0].Values)
+	}
+}
+
+func Test_MemoryDriverPartitionStats(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	view := func(key string) ViewView {
+		return ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": key}}
+	}
+
+	d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{
+		{ViewView: view("a"), Values: map[string]interface{}{"f": "x"}},
+		{ViewView: view("b"), Values: map[string]interface{}{"f": "y"}, TTL: 10},
+	}})
+	d.Insert(&DBRequest{Partition: 2, ViewMods: []ViewMod{{ViewView: view("a"), Values: map[string]interface{}{"f": "xyz"}}}})
+
+	{
+		s, err := d.PartitionStats(1)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), s.Records)
+		assert.Equal(t, int64(18), s.Bytes)
+		assert.Equal(t, int64(2), s.Views["usertable"].Records)
+	}
+
+	{
+		d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view("a"), Values: map[string]interface{}{"g": 1}}}})
+		d.Delete(&DBRequest{Partition: 2, ViewViews: []ViewView{view("a")}})
+
+		s, _ := d.PartitionStats(1)
+		assert.Equal(t, int64(2), s.Records)
+		assert.Equal(t, int64(24), s.Bytes)
+
+		list, _ := d.PartitionsStats()
+		assert.Equal(t, 1, len(list))
+	}
+
+	{
+		// the record expires a minute ago, but the reaper has not removed it yet
+		d.mu.Lock()
+
+		for i := range d.expiry {
+			d.expiry[i].at = d.expiry[i].at.Add(-time.Minute)
+		}
+
+		for k, at := range d.expires {
+			d.expires[k] = at.Add(-time.Minute)
+		}
+
+		d.mu.Unlock()
+
+		s, _ := d.PartitionStats(1)
+		assert.Equal(t, int64(1), s.Records)
+		assert.Equal(t, int64(15), s.Bytes)
+
+		list, _ := d.PartitionsStats()
+		assert.Equal(t, int64(1), list[0].Records)
+
+		d.Clean(nil)
+
+		s, _ = d.PartitionStats(1)
+		assert.Equal(t, int64(0), s.Records)
+	}
+}
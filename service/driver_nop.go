@@ -4,7 +4,13 @@
 
 package service
 
-import "fmt"
+import "context"
+
+func init() {
+	RegisterDriver(NopDriverName, func(logger *Logger) DBDriver {
+		return &NopDriver{logger: logger}
+	}, "nop mode; same as -nop, requests are answered before reaching the driver")
+}
 
 //NopDriver s.e.
 type NopDriver struct {
@@ -22,14 +28,19 @@ func (d *NopDriver) Info() string {
 }
 
 //Init s.e.
-func (d *NopDriver) Init(args map[string]string) error {
-	fmt.Println("nop mode active")
+func (d *NopDriver) Init(cfg *Config) error {
+	d.logger.Info("nop mode active")
 	return nil
 }
 
 //Free s.e.
 func (d *NopDriver) Free() error {
-	fmt.Println("nop mode stoped")
+	d.logger.Info("nop mode stoped")
+	return nil
+}
+
+//Ping s.e.
+func (d *NopDriver) Ping(ctx context.Context) error {
 	return nil
 }
 
@@ -62,3 +73,13 @@ func (d *NopDriver) Scan(r *DBRequest) *DBResponse {
 func (d *NopDriver) Delete(r *DBRequest) *DBResponse {
 	return &DBResponse{Status: 200}
 }
+
+//Increment s.e.
+func (d *NopDriver) Increment(r *DBRequest) *DBResponse {
+	return &DBResponse{Status: 200}
+}
+
+//Lookup s.e.
+func (d *NopDriver) Lookup(r *DBRequest) *DBResponse {
+	return &DBResponse{Status: 200}
+}
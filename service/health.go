@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//DefaultHealthTimeout s.e.
+const DefaultHealthTimeout = 2 * time.Second
+
+// Health statuses
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+//HostStatus is the state of a cluster host as seen by the driver
+type HostStatus struct {
+	Host    string
+	DC      string `json:",omitempty"`
+	Rack    string `json:",omitempty"`
+	Version string `json:",omitempty"`
+	Up      bool
+
+	//Connects and Failures count connection attempts to the host; the latest one took ConnectNs
+	Connects    int64
+	Failures    int64
+	LastConnect time.Time
+	ConnectNs   int64
+	LastError   string `json:",omitempty"`
+}
+
+//DriverHealth is the result of the driver ping
+type DriverHealth struct {
+	Name   string
+	Up     bool
+	PingNs int64
+	Error  string `json:",omitempty"`
+}
+
+//StartupStep s.e.
+type StartupStep struct {
+	Name       string
+	Start      time.Time
+	DurationNs int64
+	Error      string `json:",omitempty"`
+}
+
+//StartupProgress lists steps of New; Done when the service is initialized
+type StartupProgress struct {
+	Done  bool
+	Steps []StartupStep
+}
+
+//Health is the response of /healthz and /readyz
+type Health struct {
+	Status  string
+	Ready   bool
+	Version string
+	Driver  DriverHealth
+	Hosts   []HostStatus `json:",omitempty"`
+	Startup StartupProgress
+}
+
+//startupProgress records steps of the service initialization
+type startupProgress struct {
+	mu       sync.Mutex
+	progress StartupProgress
+}
+
+//run runs the step and records its duration and error
+func (p *startupProgress) run(name string, step func() error) error {
+	start := time.Now()
+	err := step()
+
+	s := StartupStep{Name: name, Start: start, DurationNs: time.Since(start).Nanoseconds()}
+
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress.Steps = append(p.progress.Steps, s)
+
+	return err
+}
+
+func (p *startupProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress.Done = true
+}
+
+func (p *startupProgress) get() StartupProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	progress := p.progress
+	progress.Steps = append([]StartupStep{}, p.progress.Steps...)
+
+	return progress
+}
+
+//health pings the driver and collects the state of the service
+func (s *Service) health(ctx context.Context) *Health {
+	ctx, cancel := context.WithTimeout(ctx, s.healthTimeout)
+	defer cancel()
+
+	h := &Health{
+		Status:  HealthOK,
+		Ready:   atomic.LoadInt32(&s.ready) == 1,
+		Version: ServiceVersion,
+		Driver:  DriverHealth{Name: s.driver.Name(), Up: true},
+		Startup: s.startup.get(),
+	}
+
+	start := time.Now()
+
+	if err := s.driver.Ping(ctx); err != nil {
+		h.Status = HealthUnavailable
+		h.Driver.Up = false
+		h.Driver.Error = err.Error()
+	}
+
+	h.Driver.PingNs = time.Since(start).Nanoseconds()
+
+	if hr, ok := s.driver.(HostsReporter); ok {
+		h.Hosts = hr.Hosts()
+	}
+
+	h.Ready = h.Ready && h.Driver.Up
+
+	return h
+}
+
+//handleHealthz is the liveness check: 503 when the driver does not respond
+func (s *Service) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	h := s.health(r.Context())
+
+	s.writeHealth(w, h, h.Driver.Up)
+}
+
+//handleReadyz is the readiness check: 503 until the service is initialized, while it shuts down and when
+//the driver does not respond
+func (s *Service) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	h := s.health(r.Context())
+
+	s.writeHealth(w, h, h.Ready)
+}
+
+func (s *Service) writeHealth(w http.ResponseWriter, h *Health, ok bool) {
+	if !h.Driver.Up {
+		s.logger.Warn("health check: driver %v does not respond: %v", h.Driver.Name, h.Driver.Error)
+	}
+
+	bytes, err := json.Marshal(h)
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	w.Write(bytes)
+}
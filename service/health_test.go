@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDownDriver struct {
+	LightDriver
+}
+
+func (d *testDownDriver) Ping(ctx context.Context) error {
+	return fmt.Errorf("connection refused")
+}
+
+func (d *testDownDriver) Hosts() []HostStatus {
+	return []HostStatus{{Host: "10.0.0.1", Failures: 1, LastError: "connection refused"}}
+}
+
+func Test_Health(t *testing.T) {
+	// a closed service is alive but not ready; a driver which does not respond fails both checks
+	tests := []struct {
+		option    Option
+		close     bool
+		path      string
+		code      int
+		status    string
+		ready     bool
+		driverErr string
+		hosts     int
+	}{
+		{WithDriver("mem"), false, "/healthz", 200, HealthOK, true, "", 0},
+		{WithDriver("mem"), false, "/readyz", 200, HealthOK, true, "", 0},
+		{WithDriver("mem"), true, "/healthz", 200, HealthOK, false, "", 0},
+		{WithDriver("mem"), true, "/readyz", 503, HealthOK, false, "", 0},
+		{WithDriverInstance(&testDownDriver{LightDriver{logger: &Logger{}}}), false, "/healthz", 503, HealthUnavailable, false, "connection refused", 1},
+		{WithDriverInstance(&testDownDriver{LightDriver{logger: &Logger{}}}), false, "/readyz", 503, HealthUnavailable, false, "connection refused", 1},
+	}
+
+	for _, test := range tests {
+		s, err := New(test.option)
+		assert.Nil(t, err)
+
+		if test.close {
+			s.Close()
+		}
+
+		rec := serve(s.Handler(), "GET", test.path, "")
+		assert.Equal(t, test.code, rec.Code, test.path)
+
+		var h Health
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &h))
+		assert.Equal(t, test.status, h.Status, test.path)
+		assert.Equal(t, test.ready, h.Ready, test.path)
+		assert.Equal(t, test.driverErr, h.Driver.Error, test.path)
+		assert.Equal(t, test.hosts, len(h.Hosts), test.path)
+		assert.True(t, h.Startup.Done)
+		assert.Equal(t, "options", h.Startup.Steps[0].Name)
+		assert.Equal(t, "driver init", h.Startup.Steps[len(h.Startup.Steps)-1].Name)
+
+		if !test.close {
+			s.Close()
+		}
+	}
+
+	{
+		_, err := New(WithDriver("mem"), WithHealthTimeout(0))
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_MemoryDriverPing(t *testing.T) {
+	d := &MemoryDriver{logger: &Logger{}}
+
+	assert.NotNil(t, d.Ping(context.Background()))
+
+	assert.Nil(t, d.Init(DefaultConfig()))
+	defer d.Free()
+
+	assert.Nil(t, d.Ping(context.Background()))
+
+	d.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, d.Ping(ctx))
+
+	d.mu.Unlock()
+}
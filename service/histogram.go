@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram buckets are log-linear as in HdrHistogram: values below histSubBuckets have buckets of
+// their own, greater ones share a bucket with less than 1/histHalfBuckets relative difference
+const (
+	histSubBucketBits = 8
+	histSubBuckets    = 1 << histSubBucketBits
+	histHalfBuckets   = histSubBuckets / 2
+
+	//HistogramMaxValue is the greatest recorded value; greater values are recorded as it
+	HistogramMaxValue = int64(time.Hour)
+)
+
+var histBuckets = histIndex(HistogramMaxValue) + 1
+
+//Histogram counts values (latencies in nanoseconds) with 2 significant digits precision in fixed
+//memory; Record is safe for concurrent use. Histograms of service instances can be merged, as all of
+//them have the same buckets.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+//LatencySummary s.e.
+type LatencySummary struct {
+	Count  int64
+	MeanNs int64
+	P50Ns  int64
+	P95Ns  int64
+	P99Ns  int64
+	P999Ns int64
+	MaxNs  int64
+}
+
+//NewHistogram s.e.
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]int64, histBuckets), min: math.MaxInt64}
+}
+
+//histIndex is the bucket of the value
+func histIndex(v int64) int {
+	if v < histSubBuckets {
+		return int(v)
+	}
+
+	shift := bits.Len64(uint64(v)) - histSubBucketBits
+
+	return shift*histHalfBuckets + int(v>>uint(shift))
+}
+
+//histUpper is the greatest value of the bucket
+func histUpper(i int) int64 {
+	if i < histSubBuckets {
+		return int64(i)
+	}
+
+	shift := uint(i/histHalfBuckets - 1)
+	sub := int64(i - int(shift)*histHalfBuckets)
+
+	return (sub+1)<<shift - 1
+}
+
+//Record counts the value; negative values are counted as 0
+func (h *Histogram) Record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+
+	if v > HistogramMaxValue {
+		v = HistogramMaxValue
+	}
+
+	atomic.AddInt64(&h.counts[histIndex(v)], 1)
+	atomic.AddInt64(&h.total, 1)
+	atomic.AddInt64(&h.sum, v)
+
+	for m := atomic.LoadInt64(&h.min); v < m && !atomic.CompareAndSwapInt64(&h.min, m, v); m = atomic.LoadInt64(&h.min) {
+	}
+
+	for m := atomic.LoadInt64(&h.max); v > m && !atomic.CompareAndSwapInt64(&h.max, m, v); m = atomic.LoadInt64(&h.max) {
+	}
+}
+
+//Count s.e.
+func (h *Histogram) Count() int64 {
+	return atomic.LoadInt64(&h.total)
+}
+
+//Max s.e.
+func (h *Histogram) Max() int64 {
+	return atomic.LoadInt64(&h.max)
+}
+
+//Min is 0 for empty histogram
+func (h *Histogram) Min() int64 {
+	if h.Count() == 0 {
+		return 0
+	}
+
+	return atomic.LoadInt64(&h.min)
+}
+
+//Mean s.e.
+func (h *Histogram) Mean() int64 {
+	if n := h.Count(); n > 0 {
+		return atomic.LoadInt64(&h.sum) / n
+	}
+
+	return 0
+}
+
+//ValueAt returns the value which the given percent of values do not exceed, up to the bucket precision
+func (h *Histogram) ValueAt(percent float64) int64 {
+	total := h.Count()
+
+	if total == 0 {
+		return 0
+	}
+
+	target := int64(math.Ceil(percent / 100 * float64(total)))
+
+	if target < 1 {
+		target = 1
+	}
+
+	var count int64
+
+	for i := range h.counts {
+		if count += atomic.LoadInt64(&h.counts[i]); count >= target {
+			if v := histUpper(i); v < h.Max() {
+				return v
+			}
+
+			break
+		}
+	}
+
+	return h.Max()
+}
+
+//Summary s.e.
+func (h *Histogram) Summary() LatencySummary {
+	return LatencySummary{
+		Count:  h.Count(),
+		MeanNs: h.Mean(),
+		P50Ns:  h.ValueAt(50),
+		P95Ns:  h.ValueAt(95),
+		P99Ns:  h.ValueAt(99),
+		P999Ns: h.ValueAt(99.9),
+		MaxNs:  h.Max(),
+	}
+}
+
+//Merge adds values of the other histogram
+func (h *Histogram) Merge(o *Histogram) {
+	if o.Count() == 0 {
+		return
+	}
+
+	for i := range o.counts {
+		if c := atomic.LoadInt64(&o.counts[i]); c > 0 {
+			atomic.AddInt64(&h.counts[i], c)
+		}
+	}
+
+	atomic.AddInt64(&h.total, o.Count())
+	atomic.AddInt64(&h.sum, atomic.LoadInt64(&o.sum))
+
+	for m, v := atomic.LoadInt64(&h.min), o.Min(); v < m && !atomic.CompareAndSwapInt64(&h.min, m, v); m = atomic.LoadInt64(&h.min) {
+	}
+
+	for m, v := atomic.LoadInt64(&h.max), o.Max(); v > m && !atomic.CompareAndSwapInt64(&h.max, m, v); m = atomic.LoadInt64(&h.max) {
+	}
+}
+
+//rawHistogram is the JSON form of Histogram: non-empty buckets as [bucket, count] pairs
+type rawHistogram struct {
+	SubBucketBits int
+	Total         int64
+	Sum           int64
+	Min           int64
+	Max           int64
+	Counts        [][2]int64
+}
+
+//MarshalJSON s.e.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	raw := rawHistogram{SubBucketBits: histSubBucketBits, Total: h.Count(), Sum: atomic.LoadInt64(&h.sum), Min: h.Min(), Max: h.Max(), Counts: [][2]int64{}}
+
+	for i := range h.counts {
+		if c := atomic.LoadInt64(&h.counts[i]); c > 0 {
+			raw.Counts = append(raw.Counts, [2]int64{int64(i), c})
+		}
+	}
+
+	return json.Marshal(raw)
+}
+
+//UnmarshalJSON s.e.
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var raw rawHistogram
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if raw.SubBucketBits != histSubBucketBits {
+		return fmt.Errorf("histogram of %v sub-bucket bits can not be read; %v expected", raw.SubBucketBits, histSubBucketBits)
+	}
+
+	*h = *NewHistogram()
+
+	for _, c := range raw.Counts {
+		if c[0] < 0 || c[0] >= int64(histBuckets) || c[1] < 0 {
+			return fmt.Errorf("histogram bucket %v of count %v is malformed", c[0], c[1])
+		}
+
+		h.counts[c[0]] += c[1]
+	}
+
+	h.total, h.sum, h.max = raw.Total, raw.Sum, raw.Max
+
+	if raw.Total > 0 {
+		h.min = raw.Min
+	}
+
+	return nil
+}
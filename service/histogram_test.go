@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_histIndex(t *testing.T) {
+	for _, v := range []int64{0, 1, 255, 256, 257, 511, 512, 1000, 123456789, HistogramMaxValue} {
+		i := histIndex(v)
+
+		assert.True(t, histUpper(i) >= v, v)
+		assert.True(t, i == 0 || histUpper(i-1) < v, v)
+		assert.True(t, float64(histUpper(i)-v) <= float64(v)/histHalfBuckets, v)
+	}
+
+	assert.Equal(t, histBuckets-1, histIndex(HistogramMaxValue))
+}
+
+func Test_Histogram(t *testing.T) {
+	h := NewHistogram()
+
+	{
+		assert.Equal(t, LatencySummary{}, h.Summary())
+
+		for v := int64(1); v <= 10000; v++ {
+			h.Record(v * int64(time.Microsecond))
+		}
+
+		s := h.Summary()
+
+		assert.Equal(t, int64(10000), s.Count)
+		assert.InDelta(t, 5000*int64(time.Microsecond), s.P50Ns, 5000*float64(time.Microsecond)/100)
+		assert.InDelta(t, 9900*int64(time.Microsecond), s.P99Ns, 9900*float64(time.Microsecond)/100)
+		assert.InDelta(t, 9990*int64(time.Microsecond), s.P999Ns, 9990*float64(time.Microsecond)/100)
+		assert.Equal(t, 10000*int64(time.Microsecond), s.MaxNs)
+		assert.Equal(t, int64(5000500), s.MeanNs)
+		assert.Equal(t, int64(time.Microsecond), h.Min())
+	}
+
+	{
+		b, err := json.Marshal(h)
+		assert.Nil(t, err)
+
+		var raw Histogram
+		assert.Nil(t, json.Unmarshal(b, &raw))
+		assert.Equal(t, h.Summary(), raw.Summary())
+
+		other := NewHistogram()
+		other.Record(-1)
+		other.Record(2 * HistogramMaxValue)
+
+		raw.Merge(other)
+
+		assert.Equal(t, int64(10002), raw.Count())
+		assert.Equal(t, int64(0), raw.Min())
+		assert.Equal(t, HistogramMaxValue, raw.Max())
+	}
+
+	{
+		var raw Histogram
+		assert.NotNil(t, json.Unmarshal([]byte(`{"SubBucketBits":7}`), &raw))
+		assert.NotNil(t, json.Unmarshal([]byte(`{"SubBucketBits":8,"Counts":[[100000,1]]}`), &raw))
+	}
+}
+
+func Test_latencyRecorder(t *testing.T) {
+	r := newLatencyRecorder([]string{"YcsbView", "YcsbAdd"})
+
+	r.record("YcsbView", 2*time.Millisecond, time.Millisecond)
+	r.record("Unknown", time.Millisecond, time.Millisecond)
+
+	total, window := r.histograms(true)
+	assert.Equal(t, int64(1), window.Ops["YcsbView"].Total.Count())
+	assert.Equal(t, 1, len(window.Summary()))
+
+	r.record("YcsbView", time.Millisecond, time.Millisecond)
+
+	total, window = r.histograms(false)
+	assert.Equal(t, int64(2), total.Summary()["YcsbView"].Total.Count)
+	assert.Equal(t, int64(1), window.Summary()["YcsbView"].Total.Count)
+	assert.Equal(t, int64(2*time.Millisecond), total.Summary()["YcsbView"].Total.MaxNs)
+
+	merged := &LatencyHistograms{Start: time.Now()}
+	merged.Merge(total)
+	merged.Merge(window)
+
+	assert.Equal(t, total.Start, merged.Start)
+	assert.Equal(t, int64(3), merged.Ops["YcsbView"].Driver.Count())
+}
+
+func Test_LatencyMetrics(t *testing.T) {
+	s, err := New(WithDriver("mem"), withTestAdmin())
+	assert.Nil(t, err)
+	defer s.Close()
+
+	serve(s.Handler(), "POST", funcPath(7, "YcsbAdd"), `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Values":{"field0":"a"}}]}`)
+	serve(s.Handler(), "POST", funcPath(7, "YcsbView"), `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"}}]}`)
+
+	// the data port does not reset the window, the admin one does
+	tests := []struct {
+		admin  bool
+		path   string
+		window int64
+	}{
+		{false, funcPath(1, "YcsbMetric?reset=true"), 1},
+		{false, funcPath(1, "YcsbMetric"), 1},
+		{true, "/api/metrics?reset=true", 1},
+		{true, "/api/metrics", 0},
+	}
+
+	for _, test := range tests {
+		h, send := s.Handler(), serve
+
+		if test.admin {
+			h, send = s.AdminHandler(), serveAdmin
+		}
+
+		var metrics struct {
+			Latency       map[string]OpLatency
+			LatencyWindow map[string]OpLatency
+		}
+
+		assert.Nil(t, json.Unmarshal(send(h, "GET", test.path, "").Body.Bytes(), &metrics))
+		assert.Equal(t, int64(1), metrics.Latency["YcsbAdd"].Total.Count, test.path)
+		assert.True(t, metrics.Latency["YcsbAdd"].Total.P99Ns >= metrics.Latency["YcsbAdd"].Driver.P99Ns, test.path)
+		assert.Equal(t, test.window, metrics.LatencyWindow["YcsbView"].Driver.Count, test.path)
+	}
+
+	var histograms struct {
+		Total  LatencyHistograms
+		Window LatencyHistograms
+	}
+
+	assert.Nil(t, json.Unmarshal(serveAdmin(s.AdminHandler(), "GET", "/api/histograms", "").Body.Bytes(), &histograms))
+	assert.Equal(t, int64(1), histograms.Total.Ops["YcsbView"].Total.Count())
+	assert.Equal(t, int64(0), histograms.Window.Ops["YcsbView"].Total.Count())
+}
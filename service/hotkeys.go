@@ -0,0 +1,322 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//DefaultHotTop s.e.
+const DefaultHotTop = 10
+
+//DefaultHotWindows s.e.
+const DefaultHotWindows = "1m,10m"
+
+// count-min sketch dimensions: estimates exceed true counts by at most 2/width of the window total
+// with probability 1 - 2^-depth
+const (
+	sketchWidth = 2048
+	sketchDepth = 4
+)
+
+//HotKey is a partition or a record key with the estimated number of its accesses; estimates may exceed
+//true counts, never fall below them
+type HotKey struct {
+	Key   string
+	Count int64
+}
+
+//HotKeys holds the most accessed partitions and keys of a window
+type HotKeys struct {
+	Total      int64
+	Partitions []HotKey
+	Keys       []HotKey
+}
+
+//HotWindow reports accesses of the current window and of the last complete one, if any
+type HotWindow struct {
+	Window   string
+	Start    time.Time
+	Read     HotKeys
+	Write    HotKeys
+	Previous *HotWindow `json:",omitempty"`
+}
+
+//countMinSketch estimates counts of a stream of strings in fixed memory
+type countMinSketch struct {
+	counts [sketchDepth][sketchWidth]int64
+}
+
+//add counts the item and returns its estimated count
+func (s *countMinSketch) add(item string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	sum := h.Sum64()
+
+	// rows are indexed by combinations of two halves of one hash
+	h1, h2 := uint32(sum), uint32(sum>>32)
+	est := int64(-1)
+
+	for i := 0; i < sketchDepth; i++ {
+		c := &s.counts[i][(h1+uint32(i)*h2)%sketchWidth]
+		*c++
+
+		if est < 0 || *c < est {
+			est = *c
+		}
+	}
+
+	return est
+}
+
+//topK keeps the k items with the greatest estimated counts
+type topK struct {
+	k      int
+	sketch countMinSketch
+	items  map[string]int64
+	total  int64
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, items: make(map[string]int64, k+1)}
+}
+
+func (t *topK) add(item string) {
+	t.total++
+	est := t.sketch.add(item)
+
+	if _, ok := t.items[item]; ok || len(t.items) < t.k {
+		t.items[item] = est
+		return
+	}
+
+	minItem, minCount := "", int64(-1)
+
+	for i, c := range t.items {
+		if minCount < 0 || c < minCount {
+			minItem, minCount = i, c
+		}
+	}
+
+	if est > minCount {
+		delete(t.items, minItem)
+		t.items[item] = est
+	}
+}
+
+//list returns the items, the most counted first
+func (t *topK) list() []HotKey {
+	list := make([]HotKey, 0, len(t.items))
+
+	for k, c := range t.items {
+		list = append(list, HotKey{Key: k, Count: c})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+
+		return list[i].Key < list[j].Key
+	})
+
+	return list
+}
+
+//hotCounters counts accesses of one kind (reads or writes) in a window
+type hotCounters struct {
+	partitions *topK
+	keys       *topK
+}
+
+func newHotCounters(k int) *hotCounters {
+	return &hotCounters{partitions: newTopK(k), keys: newTopK(k)}
+}
+
+func (c *hotCounters) report() HotKeys {
+	return HotKeys{Total: c.partitions.total, Partitions: c.partitions.list(), Keys: c.keys.list()}
+}
+
+//hotWindow tracks accesses of a tumbling window of the given duration
+type hotWindow struct {
+	mu       sync.Mutex
+	duration time.Duration
+	k        int
+	start    time.Time
+	read     *hotCounters
+	write    *hotCounters
+	previous *HotWindow
+}
+
+func newHotWindow(duration time.Duration, k int, now time.Time) *hotWindow {
+	w := &hotWindow{duration: duration, k: k}
+	w.reset(now.Truncate(duration))
+
+	return w
+}
+
+func (w *hotWindow) reset(start time.Time) {
+	w.start = start
+	w.read = newHotCounters(w.k)
+	w.write = newHotCounters(w.k)
+}
+
+//rotate starts a new window when the current one is over; the previous window is empty when no
+//accesses came during it
+func (w *hotWindow) rotate(now time.Time) {
+	end := w.start.Add(w.duration)
+
+	if now.Before(end) {
+		return
+	}
+
+	if now.Before(end.Add(w.duration)) {
+		w.previous = w.report()
+	} else {
+		w.previous = &HotWindow{Window: w.duration.String(), Start: now.Truncate(w.duration).Add(-w.duration)}
+	}
+
+	w.reset(now.Truncate(w.duration))
+}
+
+func (w *hotWindow) add(write bool, partition string, keys []string, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate(now)
+
+	c := w.read
+
+	if write {
+		c = w.write
+	}
+
+	c.partitions.add(partition)
+
+	for _, k := range keys {
+		c.keys.add(k)
+	}
+}
+
+func (w *hotWindow) report() *HotWindow {
+	return &HotWindow{Window: w.duration.String(), Start: w.start, Read: w.read.report(), Write: w.write.report()}
+}
+
+func (w *hotWindow) snapshot(now time.Time) *HotWindow {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate(now)
+
+	r := w.report()
+	r.Previous = w.previous
+
+	return r
+}
+
+//hotTracker finds the most accessed partitions and keys (heavy hitters) of reads and writes in each of
+//the windows with a count-min sketch and a top-k list per window
+type hotTracker struct {
+	windows []*hotWindow
+}
+
+func newHotTracker(k int, windows []time.Duration) *hotTracker {
+	t := &hotTracker{}
+	now := time.Now()
+
+	for _, d := range windows {
+		t.windows = append(t.windows, newHotWindow(d, k, now))
+	}
+
+	return t
+}
+
+//add counts an access of the request; keys are <partition>/<view type>/<record key>
+func (t *hotTracker) add(write bool, r *DBRequest) {
+	partition := strconv.FormatInt(r.Partition, 10)
+
+	var keys []string
+
+	for i := range r.ViewViews {
+		keys = append(keys, hotKey(partition, &r.ViewViews[i]))
+	}
+
+	for i := range r.ViewMods {
+		keys = append(keys, hotKey(partition, &r.ViewMods[i].ViewView))
+	}
+
+	now := time.Now()
+
+	for _, w := range t.windows {
+		w.add(write, partition, keys, now)
+	}
+}
+
+//report returns windows in the configured order or only the window of the given duration
+func (t *hotTracker) report(window string) ([]*HotWindow, error) {
+	var list []*HotWindow
+
+	now := time.Now()
+
+	for _, w := range t.windows {
+		if window == "" || window == w.duration.String() {
+			list = append(list, w.snapshot(now))
+		}
+	}
+
+	if window != "" && len(list) == 0 {
+		return nil, fmt.Errorf("window %q is not tracked", window)
+	}
+
+	return list, nil
+}
+
+//latest returns the last complete window of the shortest duration, or its current window before the
+//first one completes
+func (t *hotTracker) latest() *HotWindow {
+	var shortest *hotWindow
+
+	for _, w := range t.windows {
+		if shortest == nil || w.duration < shortest.duration {
+			shortest = w
+		}
+	}
+
+	r := shortest.snapshot(time.Now())
+
+	if r.Previous != nil {
+		return r.Previous
+	}
+
+	return r
+}
+
+func hotKey(partition string, view *ViewView) string {
+	key, _ := buildKey(view.PartitionKey, view.ClusterKey)
+
+	return partition + "/" + view.ViewType + "/" + key
+}
+
+//parseHotWindows parses durations of the windows
+func parseHotWindows(windows []string) ([]time.Duration, error) {
+	var list []time.Duration
+
+	for _, w := range windows {
+		d, err := time.ParseDuration(w)
+
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("hot keys window %q is not a positive duration", w)
+		}
+
+		list = append(list, d)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_topK(t *testing.T) {
+	{
+		top := newTopK(3)
+
+		// skewed stream: item i comes 100/(i+1) times, mixed with 1000 distinct cold items
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 100/(i+1); j++ {
+				top.add(fmt.Sprint("hot", i))
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			top.add(fmt.Sprint("cold", i))
+		}
+
+		list := top.list()
+
+		assert.Equal(t, 3, len(list))
+		assert.Equal(t, "hot0", list[0].Key)
+		assert.Equal(t, "hot1", list[1].Key)
+		assert.Equal(t, "hot2", list[2].Key)
+		assert.True(t, list[0].Count >= 100)
+		assert.Equal(t, int64(1000+100+50+33+25+20+16+14+12+11+10+9+8+7+7+6+6+5+5+5+5), top.total)
+	}
+}
+
+func Test_hotWindow(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	w := newHotWindow(time.Minute, 2, start.Add(10*time.Second))
+
+	{
+		w.add(false, "1", []string{"1/usertable/a"}, start.Add(20*time.Second))
+		w.add(true, "1", []string{"1/usertable/a", "1/usertable/b"}, start.Add(30*time.Second))
+
+		r := w.snapshot(start.Add(40 * time.Second))
+
+		assert.Equal(t, start, r.Start)
+		assert.Nil(t, r.Previous)
+		assert.Equal(t, int64(1), r.Read.Total)
+		assert.Equal(t, []HotKey{{Key: "1", Count: 1}}, r.Write.Partitions)
+		assert.Equal(t, 2, len(r.Write.Keys))
+	}
+
+	{
+		w.add(false, "2", nil, start.Add(70*time.Second))
+
+		r := w.snapshot(start.Add(80 * time.Second))
+
+		assert.Equal(t, start.Add(time.Minute), r.Start)
+		assert.Equal(t, []HotKey{{Key: "2", Count: 1}}, r.Read.Partitions)
+		assert.Equal(t, start, r.Previous.Start)
+		assert.Equal(t, []HotKey{{Key: "1", Count: 1}}, r.Previous.Read.Partitions)
+	}
+
+	{
+		r := w.snapshot(start.Add(5 * time.Minute))
+
+		assert.Equal(t, start.Add(5*time.Minute), r.Start)
+		assert.Equal(t, start.Add(4*time.Minute), r.Previous.Start)
+		assert.Equal(t, int64(0), r.Previous.Read.Total)
+	}
+}
+
+func Test_parseHotWindows(t *testing.T) {
+	windows, err := parseHotWindows([]string{"1m", "10m"})
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{time.Minute, 10 * time.Minute}, windows)
+
+	_, err = parseHotWindows([]string{"1m", "0s"})
+	assert.NotNil(t, err)
+
+	_, err = parseHotWindows([]string{"x"})
+	assert.NotNil(t, err)
+}
+
+func Test_HotHandler(t *testing.T) {
+	body := `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Values":{"field0":"a"}}]}`
+	view := `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"}}]}`
+
+	tests := []struct {
+		option Option
+		path   string
+		code   int
+		resp   string
+	}{
+		{WithHotKeys(2, 24*time.Hour), "/api/hot?window=24h", 200, `"Read":{"Total":2,"Partitions":[{"Key":"7","Count":2}],"Keys":[{"Key":"7/usertable/user1","Count":2}]}`},
+		{WithHotKeys(2, 24*time.Hour), "/api/hot?window=5m", 400, ""},
+		{WithHotKeys(2, 24*time.Hour), "/api/metrics", 200, `"hotWriteKeys":[{"Key":"7/usertable/user1","Count":1}]`},
+		{WithHotKeys(0), "/api/hot", 501, ""},
+	}
+
+	for _, test := range tests {
+		s, err := New(WithDriver("mem"), withTestAdmin(), test.option)
+		assert.Nil(t, err)
+		defer s.Close()
+
+		serve(s.Handler(), "POST", funcPath(7, "YcsbAdd"), body)
+
+		for i := 0; i < 2; i++ {
+			serve(s.Handler(), "POST", funcPath(7, "YcsbView"), view)
+		}
+
+		rec := serveAdmin(s.AdminHandler(), "GET", test.path, "")
+		assert.Equal(t, test.code, rec.Code, test.path)
+		assert.Contains(t, rec.Body.String(), test.resp, test.path)
+
+		assert.NotContains(t, serve(s.Handler(), "GET", funcPath(1, "YcsbMetric"), "").Body.String(), "hotWriteKeys")
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"math"
+)
+
+//incrementValues adds deltas to the fields of values, missing fields count as 0; returns new values
+//of the incremented fields. Values are changed in place.
+func incrementValues(values, deltas map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(deltas))
+
+	for k, d := range deltas {
+		delta, ok := numberValue(d)
+
+		if !ok {
+			return nil, fmt.Errorf("field %v: increment %v is not a number", k, d)
+		}
+
+		var current float64
+
+		if v, exists := values[k]; exists && v != nil {
+			if current, ok = numberValue(v); !ok {
+				return nil, fmt.Errorf("field %v: value %v is not a number", k, v)
+			}
+		}
+
+		values[k] = current + delta
+		result[k] = values[k]
+	}
+
+	return result, nil
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, !math.IsNaN(n) && !math.IsInf(n, 0)
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+//checkIncrement validates request of Increment
+func checkIncrement(r *DBRequest) error {
+	if r == nil || len(r.ViewMods) == 0 {
+		return fmt.Errorf("wrong request data")
+	}
+
+	for _, v := range r.ViewMods {
+		if v.ViewType == "" {
+			return fmt.Errorf("record ViewType malformed")
+		}
+
+		if len(v.Values) == 0 {
+			return fmt.Errorf("view %v: no fields to increment", v.ViewType)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+//viewIndexes holds indexed fields of the views declared in the scheme
+type viewIndexes map[string][]string
+
+func schemeIndexes(s *Scheme) viewIndexes {
+	indexes := viewIndexes{}
+
+	for _, v := range s.Views {
+		if len(v.Indexes) > 0 {
+			indexes[v.Name] = v.Indexes
+		}
+	}
+
+	return indexes
+}
+
+//indexed tells the field of the view is indexed
+func (x viewIndexes) indexed(view, field string) bool {
+	for _, f := range x[view] {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+//values returns encoded values of indexed fields of the view; fields without value are not indexed
+func (x viewIndexes) values(view string, values map[string]interface{}) map[string]string {
+	if len(x[view]) == 0 {
+		return nil
+	}
+
+	encoded := map[string]string{}
+
+	for _, f := range x[view] {
+		if v, ok := indexValue(values[f]); ok {
+			encoded[f] = v
+		}
+	}
+
+	return encoded
+}
+
+//indexTable is the cast table of the field index
+func indexTable(view, field string) string {
+	return view + "_by_" + field
+}
+
+//indexValue encodes indexed value as JSON, so that numbers equal by value are equal; null and
+//objects are not indexed
+func indexValue(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	if n, ok := numberValue(v); ok {
+		v = n
+	}
+
+	switch v.(type) {
+	case float64, string, bool:
+	default:
+		return "", false
+	}
+
+	b, err := json.Marshal(v)
+
+	return string(b), err == nil
+}
+
+//checkLookup validates request of Lookup and returns the looked up view
+func checkLookup(r *DBRequest, indexes viewIndexes) (*ViewView, error) {
+	if r == nil || len(r.ViewViews) != 1 {
+		return nil, fmt.Errorf("wrong request data: one view expected")
+	}
+
+	view := &r.ViewViews[0]
+
+	if view.Index == "" || view.IndexValue == nil {
+		return nil, fmt.Errorf("lookup of view %v: Index and IndexValue expected", view.ViewType)
+	}
+
+	if !indexes.indexed(view.ViewType, view.Index) {
+		return nil, fmt.Errorf("view %v: field %q is not indexed in the scheme", view.ViewType, view.Index)
+	}
+
+	if _, ok := indexValue(view.IndexValue); !ok {
+		return nil, fmt.Errorf("lookup of view %v: value %v can not be indexed", view.ViewType, view.IndexValue)
+	}
+
+	return view, nil
+}
+
+//pageLimit is the page size of a scan or lookup
+func pageLimit(view *ViewView) int {
+	if view.Limit <= 0 {
+		return DefaultScanLimit
+	}
+
+	return view.Limit
+}
+
+//lookupProjection adds the index field to projected fields, since lookups check it
+func lookupProjection(view *ViewView) []string {
+	if len(view.Fields) == 0 {
+		return nil
+	}
+
+	return append(append([]string{}, view.Fields...), view.Index)
+}
+
+//lookupMatch checks the record found by an index entry still has the looked up value and applies
+//the projection; entries may be stale when a write fails after its index entry is written
+func lookupMatch(rec *Record, view *ViewView) (bool, int64) {
+	if rec == nil {
+		return false, 0
+	}
+
+	value, _ := indexValue(view.IndexValue)
+
+	if v, ok := indexValue(rec.Values[view.Index]); !ok || v != value {
+		return false, 0
+	}
+
+	return true, projectRecord(rec, view.Fields)
+}
+
+func encodePageState(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func decodePageState(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, fmt.Errorf("page state malformed")
+	}
+
+	return b, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_indexValue(t *testing.T) {
+	{
+		a, ok := indexValue(5)
+		assert.True(t, ok)
+
+		b, _ := indexValue(float64(5))
+		assert.Equal(t, a, b)
+
+		c, _ := indexValue("5")
+		assert.NotEqual(t, a, c)
+	}
+
+	{
+		_, ok := indexValue(nil)
+		assert.False(t, ok)
+
+		_, ok = indexValue(map[string]interface{}{"a": 1})
+		assert.False(t, ok)
+	}
+}
+
+func Test_checkLookup(t *testing.T) {
+	indexes := viewIndexes{"users": {"email"}}
+	lookup := func(index string, value interface{}) error {
+		_, err := checkLookup(&DBRequest{ViewViews: []ViewView{{ViewType: "users", Index: index, IndexValue: value}}}, indexes)
+		return err
+	}
+
+	assert.Nil(t, lookup("email", "a@b"))
+	assert.NotNil(t, lookup("name", "a@b"))
+	assert.NotNil(t, lookup("email", nil))
+	assert.NotNil(t, lookup("email", []interface{}{"a@b"}))
+	assert.NotNil(t, lookup("", "a@b"))
+}
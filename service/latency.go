@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+//OpHistograms are latency histograms of a function: end-to-end (from the request start to the written
+//response) and of the driver call only
+type OpHistograms struct {
+	Total  *Histogram
+	Driver *Histogram
+}
+
+//OpLatency s.e.
+type OpLatency struct {
+	Total  LatencySummary
+	Driver LatencySummary
+}
+
+//LatencyHistograms are histograms of functions since Start
+type LatencyHistograms struct {
+	Start time.Time
+	Ops   map[string]*OpHistograms
+}
+
+//latencyRecorder keeps histograms of the functions since start and of the current window; the window
+//is reset on demand, so that consecutive metric reads get latencies of their intervals
+type latencyRecorder struct {
+	mu     sync.RWMutex
+	total  *LatencyHistograms
+	window *LatencyHistograms
+}
+
+func newLatencyRecorder(functions []string) *latencyRecorder {
+	now := time.Now()
+
+	return &latencyRecorder{total: newLatencyHistograms(functions, now), window: newLatencyHistograms(functions, now)}
+}
+
+func newLatencyHistograms(functions []string, start time.Time) *LatencyHistograms {
+	h := &LatencyHistograms{Start: start, Ops: make(map[string]*OpHistograms, len(functions))}
+
+	for _, f := range functions {
+		h.Ops[f] = &OpHistograms{Total: NewHistogram(), Driver: NewHistogram()}
+	}
+
+	return h
+}
+
+//record counts latencies of the function call; calls of unknown functions are not counted
+func (r *latencyRecorder) record(function string, total, driver time.Duration) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, h := range []*LatencyHistograms{r.total, r.window} {
+		if op, ok := h.Ops[function]; ok {
+			op.Total.Record(total.Nanoseconds())
+			op.Driver.Record(driver.Nanoseconds())
+		}
+	}
+}
+
+//histograms returns histograms since start and of the window; with reset a new window starts
+func (r *latencyRecorder) histograms(reset bool) (total, window *LatencyHistograms) {
+	if !reset {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		return r.total, r.window
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total, window = r.total, r.window
+
+	functions := make([]string, 0, len(window.Ops))
+
+	for f := range window.Ops {
+		functions = append(functions, f)
+	}
+
+	r.window = newLatencyHistograms(functions, time.Now())
+
+	return total, window
+}
+
+//Summary returns percentiles of the functions which were called
+func (h *LatencyHistograms) Summary() map[string]OpLatency {
+	summary := map[string]OpLatency{}
+
+	for f, op := range h.Ops {
+		if op.Total.Count() > 0 {
+			summary[f] = OpLatency{Total: op.Total.Summary(), Driver: op.Driver.Summary()}
+		}
+	}
+
+	return summary
+}
+
+//Merge adds histograms of the other instance; Start is the earliest one
+func (h *LatencyHistograms) Merge(o *LatencyHistograms) {
+	if o.Start.Before(h.Start) {
+		h.Start = o.Start
+	}
+
+	if h.Ops == nil {
+		h.Ops = map[string]*OpHistograms{}
+	}
+
+	for f, op := range o.Ops {
+		mine, ok := h.Ops[f]
+
+		if !ok {
+			mine = &OpHistograms{Total: NewHistogram(), Driver: NewHistogram()}
+			h.Ops[f] = mine
+		}
+
+		mine.Total.Merge(op.Total)
+		mine.Driver.Merge(op.Driver)
+	}
+}
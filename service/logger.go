@@ -4,32 +4,220 @@
 
 package service
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-const ErrLevel = 0
-const DebugLevel = 1
+//Level is a logger level; a logger writes messages of its level and the lower ones
+type Level int32
 
+// Logger levels
+const (
+	ErrLevel Level = iota
+	WarnLevel
+	InfoLevel
+	DebugLevel
+)
+
+// Logger formats
+const (
+	//LogFormatText - one line per message: time, level, caller, message and fields as key=value
+	LogFormatText = "text"
+	//LogFormatJSON - one JSON object per line
+	LogFormatJSON = "json"
+)
+
+//RequestIDHeader is the header request IDs are taken from and returned in; IDs are generated for
+//requests without it
+const RequestIDHeader = "X-Request-ID"
+
+var levelNames = []string{"error", "warn", "info", "debug"}
+
+// writes of all loggers are serialized, so lines of concurrent messages do not mix
+var logMu sync.Mutex
+
+func (l Level) String() string {
+	if l < ErrLevel || l > DebugLevel {
+		return strconv.Itoa(int(l))
+	}
+
+	return levelNames[l]
+}
+
+//ParseLevel parses level name; former numeric levels 0 and 1 mean info and debug, as messages of
+//info level were written with any of them
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "0":
+		return InfoLevel, nil
+	case "1":
+		return DebugLevel, nil
+	}
+
+	for i, n := range levelNames {
+		if strings.EqualFold(s, n) {
+			return Level(i), nil
+		}
+	}
+
+	return ErrLevel, fmt.Errorf("logger level %q is unknown; available: %v", s, strings.Join(levelNames, ", "))
+}
+
+//Logger writes leveled messages with a timestamp, the caller and fields. Loggers derived by With
+//share the level, so SetLevel changes it for all of them. Zero Logger writes errors only, as text to
+//stdout.
 type Logger struct {
-	level int64
-	//showTS bool
+	level  *int32
+	json   bool
+	out    io.Writer
+	fields []interface{}
 }
 
-func (l *Logger) Error(msg string, args ...interface{}) {
-	if l.level < ErrLevel {
-		return
+//NewLogger creates a logger writing in the format (LogFormatText by default) to stdout
+func NewLogger(level Level, format string) *Logger {
+	l := int32(level)
+
+	return &Logger{level: &l, json: format == LogFormatJSON}
+}
+
+//SetOutput s.e.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.out = w
+}
+
+//Level s.e.
+func (l *Logger) Level() Level {
+	if l.level == nil {
+		return ErrLevel
+	}
+
+	return Level(atomic.LoadInt32(l.level))
+}
+
+//SetLevel changes level of the logger and the loggers derived from it
+func (l *Logger) SetLevel(level Level) {
+	if l.level == nil {
+		l.level = new(int32)
+	}
+
+	atomic.StoreInt32(l.level, int32(level))
+}
+
+//Format s.e.
+func (l *Logger) Format() string {
+	if l.json {
+		return LogFormatJSON
 	}
 
-	fmt.Printf("Error: "+msg+"\n", args...)
+	return LogFormatText
+}
+
+//With returns a logger which adds the field to messages
+func (l *Logger) With(key string, value interface{}) *Logger {
+	c := *l
+	c.fields = append(append(make([]interface{}, 0, len(l.fields)+2), l.fields...), key, value)
+
+	return &c
+}
+
+//Request returns a logger adding ID of the request, if any
+func (l *Logger) Request(r *DBRequest) *Logger {
+	if r == nil || r.RequestID == "" {
+		return l
+	}
+
+	return l.With("requestId", r.RequestID)
+}
+
+//Enabled tells whether messages of the level are written; nil Logger writes nothing
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level <= l.Level()
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.write(ErrLevel, msg, args)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.write(WarnLevel, msg, args)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.write(InfoLevel, msg, args)
 }
 
 func (l *Logger) Debug(msg string, args ...interface{}) {
-	if l.level < DebugLevel {
+	l.write(DebugLevel, msg, args)
+}
+
+func (l *Logger) write(level Level, msg string, args []interface{}) {
+	if !l.Enabled(level) {
 		return
 	}
 
-	fmt.Printf("Debug: "+msg+"\n", args...)
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+
+	caller := ""
+
+	// callers of write are the level methods, so their callers are two frames up
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+	}
+
+	line := l.format(time.Now().UTC(), level, caller, msg)
+
+	out := l.out
+
+	if out == nil {
+		out = os.Stdout
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	out.Write(line)
 }
 
-func (l *Logger) Log(msg string, args ...interface{}) {
-	fmt.Printf(msg+"\n", args...)
+func (l *Logger) format(t time.Time, level Level, caller, msg string) []byte {
+	ts := t.Format("2006-01-02T15:04:05.000Z07:00")
+
+	if !l.json {
+		var b strings.Builder
+
+		fmt.Fprintf(&b, "%v %-5v %v %v", ts, strings.ToUpper(level.String()), caller, strings.TrimRight(msg, "\n"))
+
+		for i := 0; i+1 < len(l.fields); i += 2 {
+			fmt.Fprintf(&b, " %v=%v", l.fields[i], l.fields[i+1])
+		}
+
+		b.WriteByte('\n')
+
+		return []byte(b.String())
+	}
+
+	entry := map[string]interface{}{"time": ts, "level": level.String(), "caller": caller, "msg": strings.TrimRight(msg, "\n")}
+
+	for i := 0; i+1 < len(l.fields); i += 2 {
+		entry[fmt.Sprint(l.fields[i])] = l.fields[i+1]
+	}
+
+	b, err := json.Marshal(entry)
+
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{"time": ts, "level": level.String(), "caller": caller, "msg": msg, "fieldsError": err.Error()})
+	}
+
+	return append(b, '\n')
 }
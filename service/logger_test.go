@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Logger(t *testing.T) {
+	{
+		var out bytes.Buffer
+
+		l := NewLogger(InfoLevel, LogFormatText)
+		l.SetOutput(&out)
+
+		l.With("requestId", "r1").Info("read %v", 5)
+		l.Debug("skipped")
+
+		line := out.String()
+		assert.True(t, strings.HasSuffix(line, " INFO  logger_test.go:24 read 5 requestId=r1\n"), line)
+	}
+
+	{
+		var out bytes.Buffer
+
+		l := NewLogger(ErrLevel, LogFormatJSON)
+		l.SetOutput(&out)
+		r := l.Request(&DBRequest{RequestID: "r2"})
+
+		r.Warn("skipped")
+		l.SetLevel(DebugLevel)
+		r.Debug("written")
+
+		var entry map[string]interface{}
+		assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+		assert.Equal(t, "debug", entry["level"])
+		assert.Equal(t, "written", entry["msg"])
+		assert.Equal(t, "r2", entry["requestId"])
+		assert.Equal(t, "logger_test.go:40", entry["caller"])
+	}
+
+	{
+		var l *Logger
+		l.Error("nil logger writes nothing")
+
+		assert.False(t, (&Logger{}).Enabled(InfoLevel))
+		assert.True(t, (&Logger{}).Enabled(ErrLevel))
+	}
+}
+
+func Test_ParseLevel(t *testing.T) {
+	for s, level := range map[string]Level{"error": ErrLevel, "WARN": WarnLevel, "info": InfoLevel, "debug": DebugLevel, "0": InfoLevel, "1": DebugLevel} {
+		l, err := ParseLevel(s)
+		assert.Nil(t, err)
+		assert.Equal(t, level, l)
+	}
+
+	_, err := ParseLevel("trace")
+	assert.NotNil(t, err)
+}
+
+func Test_RequestLogging(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := NewLogger(InfoLevel, LogFormatJSON)
+	logger.SetOutput(&out)
+
+	s, err := New(WithDriver("mem"), WithLogger(logger), withTestAdmin())
+	assert.Nil(t, err)
+	defer s.Close()
+
+	// failed requests are logged with the request ID
+	ids := []struct {
+		header string
+		f      string
+		length int
+		logged bool
+	}{
+		{"req-1", "YcsbFoo", len("req-1"), true},
+		{"", "YcsbView", 16, false},
+	}
+
+	for _, test := range ids {
+		req := httptest.NewRequest("POST", funcPath(7, test.f), strings.NewReader(`{}`))
+
+		if test.header != "" {
+			req.Header.Set(RequestIDHeader, test.header)
+		}
+
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		assert.Equal(t, test.length, len(id))
+		assert.Equal(t, test.logged, strings.Contains(out.String(), `"requestId":"`+id+`"`))
+	}
+
+	levels := []struct {
+		level string
+		code  int
+		resp  string
+	}{
+		{"debug", 200, `{"Format":"json","Level":"debug"}`},
+		{"trace", 400, ""},
+	}
+
+	for _, test := range levels {
+		rec := serveAdmin(s.AdminHandler(), "PUT", "/api/log?level="+test.level, "")
+		assert.Equal(t, test.code, rec.Code, test.level)
+		assert.Contains(t, rec.Body.String(), test.resp, test.level)
+		assert.Equal(t, DebugLevel, logger.Level())
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"io"
+)
+
+//MigrateCommand is the first argument which switches the app to schema migrations
+const MigrateCommand = "migrate"
+
+const (
+	//MigrateUp is the migrate command which applies pending migrations
+	MigrateUp = "up"
+	//MigrateStatus is the migrate command which lists migrations
+	MigrateStatus = "status"
+)
+
+//DryRunAttribute s.e.
+const DryRunAttribute = "--dry-run"
+
+//Migrate runs "migrate up|status [--dry-run] [arguments]" for the configured driver; arguments are
+//the same as for the service. With --dry-run up writes statements instead of applying them.
+func Migrate(args []string, lookupEnv func(string) (string, bool), w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%v command expected: %v or %v", MigrateCommand, MigrateUp, MigrateStatus)
+	}
+
+	command := args[0]
+
+	if command != MigrateUp && command != MigrateStatus {
+		return fmt.Errorf("unknown %v command %q; available: %v, %v", MigrateCommand, command, MigrateUp, MigrateStatus)
+	}
+
+	dryRun := false
+	rest := make([]string, 0, len(args))
+
+	for _, a := range args[1:] {
+		if a == DryRunAttribute {
+			dryRun = true
+			continue
+		}
+
+		rest = append(rest, a)
+	}
+
+	cfg, err := LoadConfig(rest, lookupEnv)
+
+	if err != nil {
+		return err
+	}
+
+	name := cfg.String(ServiceDriverParam)
+	d, err := newDriver(name, configLogger(cfg))
+
+	if err != nil {
+		return err
+	}
+
+	m, ok := d.(SchemaMigrator)
+
+	if !ok {
+		return fmt.Errorf("driver %v has no schema migrations", name)
+	}
+
+	return m.Migrate(cfg, command, dryRun, w)
+}
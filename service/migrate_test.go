@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMigratorDriver struct {
+	LightDriver
+}
+
+func (d *testMigratorDriver) Migrate(cfg *Config, command string, dryRun bool, w io.Writer) error {
+	fmt.Fprintf(w, "%v %v %v", command, dryRun, cfg.Int(ServicePortParam))
+	return nil
+}
+
+func Test_Migrate(t *testing.T) {
+	RegisterDriver("test-migrate", func(logger *Logger) DBDriver {
+		return &testMigratorDriver{LightDriver{logger: logger}}
+	}, "test migrations driver")
+
+	{
+		var b bytes.Buffer
+
+		assert.Nil(t, Migrate([]string{MigrateUp, "-d", "test-migrate", DryRunAttribute, "-p", "8090"}, nil, &b))
+		assert.Equal(t, "up true 8090", b.String())
+	}
+
+	{
+		var b bytes.Buffer
+
+		assert.Nil(t, Migrate([]string{MigrateStatus, "-d", "test-migrate"}, nil, &b))
+		assert.Equal(t, "status false 80", b.String())
+	}
+
+	{
+		assert.NotNil(t, Migrate(nil, nil, io.Discard))
+		assert.NotNil(t, Migrate([]string{"down", "-d", "test-migrate"}, nil, io.Discard))
+
+		err := Migrate([]string{MigrateUp, "-d", "mem"}, nil, io.Discard)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "no schema migrations")
+	}
+}
+
+func Test_casandraRecordsMigrations(t *testing.T) {
+	migrations := casandraRecordsMigrations(casandraRecordsTable, casandraLegacyRecordsTable, "((partition, type, key))")
+
+	for i, mg := range migrations {
+		assert.Equal(t, i+1, mg.version)
+	}
+
+	stmt := fmt.Sprintf(migrations[0].cql[0], "ks")
+	assert.Contains(t, stmt, "CREATE TABLE IF NOT EXISTS ks.records_v2")
+	assert.Contains(t, stmt, "PRIMARY KEY ((partition, type, key))")
+
+	stmt = fmt.Sprintf(migrations[2].cql[0], "ks")
+	assert.Contains(t, stmt, "CREATE TABLE IF NOT EXISTS ks.records_v2_index")
+
+	stmt = fmt.Sprintf(migrations[3].cql[0], "ks")
+	assert.Contains(t, stmt, "CREATE TABLE IF NOT EXISTS ks.records_v2_stats")
+
+	other := casandraRecordsMigrations(casandraPartitionedRecordsTable, casandraLegacyPartitionedRecordsTable, "(partition, type, key)")
+	assert.NotEqual(t, migrations[0].checksum(), other[0].checksum())
+	assert.Equal(t, migrations[0].checksum(), casandraRecordsMigrations(casandraRecordsTable, casandraLegacyRecordsTable, "((partition, type, key))")[0].checksum())
+}
+
+func Test_casandraCopyStatements(t *testing.T) {
+	tests := []struct {
+		legacy, table string
+		legacyCreate  string
+		key           string
+	}{
+		{casandraLegacyRecordsTable, casandraRecordsTable, "CREATE TABLE IF NOT EXISTS records ( key text PRIMARY KEY, partition int, version int, values blob, type text, weight int )", "((partition, type, key))"},
+		{casandraLegacyPartitionedRecordsTable, casandraPartitionedRecordsTable, "CREATE TABLE IF NOT EXISTS records_p ( key text, partition int, version int, values blob, type text, weight int, PRIMARY KEY (partition, key))", "(partition, type, key)"},
+	}
+
+	for _, test := range tests {
+		selectRows, insert := casandraCopyStatements("ks."+test.legacy, "ks."+test.table)
+		assert.Equal(t, "SELECT partition, type, key, version, values, weight FROM ks."+test.legacy, selectRows)
+		assert.Equal(t, "INSERT INTO ks."+test.table+" (partition, type, key, version, values, weight) VALUES (?, ?, ?, ?, ?, ?)", insert)
+
+		create := fmt.Sprintf(casandraRecordsMigrations(test.table, test.legacy, test.key)[0].cql[0], "ks")
+
+		// each copied column exists in both tables
+		for _, column := range strings.Split(casandraCopyColumns, ", ") {
+			assert.Regexp(t, `[(,] `+column+` \w+`, test.legacyCreate, column)
+			assert.Regexp(t, `[(,] `+column+` \w+`, create, column)
+		}
+	}
+}
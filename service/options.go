@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+//Option configures a service created by New
+type Option func(s *Service) error
+
+//FuncNames holds names of the functions served at the {function} path segment; empty names keep defaults
+type FuncNames struct {
+	Read      string
+	Insert    string
+	Update    string
+	Scan      string
+	Delete    string
+	Increment string
+	Lookup    string
+}
+
+//WithDriver selects a registered driver by name
+func WithDriver(name string) Option {
+	return func(s *Service) error {
+		if name == "" {
+			return fmt.Errorf("driver name is empty")
+		}
+
+		s.driverName = name
+
+		return nil
+	}
+}
+
+//WithDriverInstance makes service use the given driver; New calls its Init
+func WithDriverInstance(d DBDriver) Option {
+	return func(s *Service) error {
+		if d == nil {
+			return fmt.Errorf("driver is nil")
+		}
+
+		s.driver = d
+
+		return nil
+	}
+}
+
+//WithConfig sets the configuration passed to DBDriver.Init; DefaultConfig is used when not given
+func WithConfig(cfg *Config) Option {
+	return func(s *Service) error {
+		if cfg == nil {
+			return fmt.Errorf("config is nil")
+		}
+
+		s.config = cfg
+
+		return nil
+	}
+}
+
+//WithPort s.e.
+func WithPort(port int64) Option {
+	return func(s *Service) error {
+		s.port = port
+
+		return nil
+	}
+}
+
+//WithPathPattern sets the data api route; it must contain {wsid} and {function} variables
+func WithPathPattern(pattern string) Option {
+	return func(s *Service) error {
+		if pattern == "" {
+			return fmt.Errorf("path pattern is empty")
+		}
+
+		s.pathPattern = pattern
+
+		return nil
+	}
+}
+
+//WithFuncNames s.e.
+func WithFuncNames(names FuncNames) Option {
+	return func(s *Service) error {
+		setIfNotEmpty(&s.readFunc, names.Read)
+		setIfNotEmpty(&s.insertFunc, names.Insert)
+		setIfNotEmpty(&s.updateFunc, names.Update)
+		setIfNotEmpty(&s.scanFunc, names.Scan)
+		setIfNotEmpty(&s.deleteFunc, names.Delete)
+		setIfNotEmpty(&s.incrementFunc, names.Increment)
+		setIfNotEmpty(&s.lookupFunc, names.Lookup)
+
+		return nil
+	}
+}
+
+//WithLogger s.e.
+func WithLogger(logger *Logger) Option {
+	return func(s *Service) error {
+		s.logger = logger
+
+		return nil
+	}
+}
+
+//WithNoop makes service answer data requests without calling the driver
+func WithNoop(noop bool) Option {
+	return func(s *Service) error {
+		s.noop = noop
+
+		return nil
+	}
+}
+
+//WithHotKeys makes service track the k most accessed partitions and keys in windows of the given
+//durations; k = 0 turns tracking off
+func WithHotKeys(k int, windows ...time.Duration) Option {
+	return func(s *Service) error {
+		if k < 0 {
+			return fmt.Errorf("number of hot keys is negative")
+		}
+
+		if k > 0 && len(windows) == 0 {
+			return fmt.Errorf("no hot keys window is given")
+		}
+
+		for _, w := range windows {
+			if w <= 0 {
+				return fmt.Errorf("hot keys window %v is not positive", w)
+			}
+		}
+
+		s.hot = nil
+
+		if k > 0 {
+			s.hot = newHotTracker(k, windows)
+		}
+
+		return nil
+	}
+}
+
+//WithSlowLog makes service log driver calls slower than the thresholds of their functions
+func WithSlowLog(cfg SlowLogConfig) Option {
+	return func(s *Service) error {
+		l, err := newSlowLog(cfg)
+
+		if err != nil {
+			return err
+		}
+
+		if s.slow != nil {
+			s.slow.close()
+		}
+
+		s.slow = l
+
+		return nil
+	}
+}
+
+//WithTracing makes service trace the given percent of data requests which do not come with a sampled
+//W3C traceparent; spans are sent to the exporter
+func WithTracing(exporter TraceExporter, samplePercent int) Option {
+	return func(s *Service) error {
+		if exporter == nil {
+			return fmt.Errorf("trace exporter is nil")
+		}
+
+		if samplePercent < 0 || samplePercent > 100 {
+			return fmt.Errorf("trace sample percent %v is out of range [0, 100]", samplePercent)
+		}
+
+		s.traceExporter = exporter
+		s.traceSample = samplePercent
+
+		return nil
+	}
+}
+
+//WithAdmin configures the listener of administrative routes, its authentication and the audit file
+func WithAdmin(cfg AdminConfig) Option {
+	return func(s *Service) error {
+		a, err := newAdmin(cfg)
+
+		if err != nil {
+			return err
+		}
+
+		if s.admin != nil {
+			s.admin.close()
+		}
+
+		s.admin = a
+
+		return nil
+	}
+}
+
+//WithHealthTimeout limits the driver ping of /healthz and /readyz
+func WithHealthTimeout(d time.Duration) Option {
+	return func(s *Service) error {
+		if d <= 0 {
+			return fmt.Errorf("health timeout %v is not positive", d)
+		}
+
+		s.healthTimeout = d
+
+		return nil
+	}
+}
+
+//WithInitCheck adds a check of the initialized driver; New fails and frees the driver when the check fails
+func WithInitCheck(check func(d DBDriver) error) Option {
+	return func(s *Service) error {
+		s.initChecks = append(s.initChecks, check)
+
+		return nil
+	}
+}
+
+//ConfigOptions builds options from the loaded configuration
+func ConfigOptions(cfg *Config) []Option {
+	return []Option{
+		WithConfig(cfg),
+		WithLogger(configLogger(cfg)),
+		WithNoop(cfg.Bool(NoopServiceParam)),
+		WithDriver(cfg.String(ServiceDriverParam)),
+		WithPort(cfg.Int(ServicePortParam)),
+		WithPathPattern(cfg.String(PathPatternParam)),
+		WithFuncNames(FuncNames{
+			Read:      cfg.String(ServiceReadFuncParam),
+			Insert:    cfg.String(ServiceInsertFuncParam),
+			Update:    cfg.String(ServiceUpdateFuncParam),
+			Scan:      cfg.String(ServiceScanFuncParam),
+			Delete:    cfg.String(ServiceDeleteFuncParam),
+			Increment: cfg.String(ServiceIncrementFuncParam),
+			Lookup:    cfg.String(ServiceLookupFuncParam),
+		}),
+		withHotKeysConfig(cfg),
+		withSlowLogConfig(cfg),
+		withTracingConfig(cfg),
+		WithHealthTimeout(cfg.Duration(HealthTimeoutParam)),
+		WithAdmin(AdminConfig{
+			Port:        cfg.Int(AdminPortParam),
+			Host:        cfg.String(AdminHostParam),
+			Token:       cfg.String(AdminTokenParam),
+			TLSCert:     cfg.String(AdminTLSCertParam),
+			TLSKey:      cfg.String(AdminTLSKeyParam),
+			TLSClientCA: cfg.String(AdminTLSClientCAParam),
+			AuditFile:   cfg.String(AdminAuditFileParam),
+		}),
+	}
+}
+
+func withHotKeysConfig(cfg *Config) Option {
+	windows, err := parseHotWindows(cfg.List(HotWindowsParam))
+
+	if err != nil {
+		return func(s *Service) error { return err }
+	}
+
+	return WithHotKeys(int(cfg.Int(HotTopParam)), windows...)
+}
+
+func setIfNotEmpty(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
+
+func withSlowLogConfig(cfg *Config) Option {
+	thresholds, err := parseSlowThresholds(cfg.List(SlowFuncThresholdsParam))
+
+	if err != nil {
+		return func(s *Service) error { return err }
+	}
+
+	return WithSlowLog(SlowLogConfig{
+		Threshold:    cfg.Duration(SlowThresholdParam),
+		Thresholds:   thresholds,
+		Size:         int(cfg.Int(SlowSizeParam)),
+		Sample:       int(cfg.Int(SlowSampleParam)),
+		File:         cfg.String(SlowFileParam),
+		FileMaxBytes: cfg.Int(SlowFileMaxSizeParam) << 20,
+		FileBackups:  int(cfg.Int(SlowFileBackupsParam)),
+	})
+}
+
+//configLogger creates the logger of the configured level and format
+func configLogger(cfg *Config) *Logger {
+	level, _ := ParseLevel(cfg.String(LoggerLevelParam))
+
+	return NewLogger(level, cfg.String(LoggerFormatParam))
+}
+
+func withTracingConfig(cfg *Config) Option {
+	sample := int(cfg.Int(TraceSampleParam))
+
+	switch cfg.String(TraceExporterParam) {
+	case TraceExporterFile:
+		return func(s *Service) error {
+			exporter, err := NewFileTraceExporter(cfg.String(TraceFileParam))
+
+			if err != nil {
+				return err
+			}
+
+			return WithTracing(exporter, sample)(s)
+		}
+	case TraceExporterOTLP:
+		return WithTracing(NewOTLPTraceExporter(cfg.String(TraceEndpointParam)), sample)
+	}
+
+	return func(s *Service) error { return nil }
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import "fmt"
+
+// Update semantics: ViewMod.Values is a JSON merge patch (RFC 7396) of the record values
+
+//patchValues applies patch to values in place: null removes a field, an object is merged into the
+//field recursively, any other value replaces the field. With replace values become the patch
+//(less nulls) instead.
+func patchValues(values, patch map[string]interface{}, replace bool) {
+	if replace {
+		for k := range values {
+			delete(values, k)
+		}
+	}
+
+	for k, p := range patch {
+		if p == nil {
+			delete(values, k)
+			continue
+		}
+
+		values[k] = mergePatch(values[k], p)
+	}
+}
+
+//mergePatch is MergePatch(Target, Patch) of RFC 7396; target is not changed
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	t, ok := toMap(target)
+
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	patchValues(t, p, false)
+
+	return t
+}
+
+//checkUpdate validates update modes of a ViewMod
+func checkUpdate(view *ViewMod) error {
+	if view.Replace && len(view.Ops) > 0 {
+		return fmt.Errorf("view %v: collection operations can not be combined with replace", view.ViewType)
+	}
+
+	return nil
+}
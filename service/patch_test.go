@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_patchValues(t *testing.T) {
+	{
+		// the example of RFC 7396
+		values := map[string]interface{}{
+			"title":   "Goodbye!",
+			"author":  map[string]interface{}{"givenName": "John", "familyName": "Doe"},
+			"tags":    []interface{}{"example", "sample"},
+			"content": "This will be unchanged",
+		}
+		patchValues(values, map[string]interface{}{
+			"title":       "Hello!",
+			"phoneNumber": "+01-123-456-7890",
+			"author":      map[string]interface{}{"familyName": nil},
+			"tags":        []interface{}{"example"},
+		}, false)
+
+		assert.Equal(t, map[string]interface{}{
+			"title":       "Hello!",
+			"author":      map[string]interface{}{"givenName": "John"},
+			"tags":        []interface{}{"example"},
+			"content":     "This will be unchanged",
+			"phoneNumber": "+01-123-456-7890",
+		}, values)
+	}
+
+	{
+		nested := map[string]interface{}{"a": 1}
+		values := map[string]interface{}{"n": nested, "s": "x"}
+		patchValues(values, map[string]interface{}{"s": map[string]interface{}{"b": nil, "c": 2}, "n": map[string]interface{}{"a": nil}, "gone": nil}, false)
+
+		assert.Equal(t, map[string]interface{}{"n": map[string]interface{}{}, "s": map[string]interface{}{"c": 2}}, values)
+		assert.Equal(t, map[string]interface{}{"a": 1}, nested)
+	}
+
+	{
+		values := map[string]interface{}{"a": 1, "b": 2}
+		patchValues(values, map[string]interface{}{"b": 3, "c": nil, "d": map[string]interface{}{"e": nil}}, true)
+
+		assert.Equal(t, map[string]interface{}{"b": 3, "d": map[string]interface{}{}}, values)
+	}
+
+	{
+		assert.NotNil(t, checkUpdate(&ViewMod{Replace: true, Ops: []CollectionOp{{Op: OpAdd, Field: "s", Values: []interface{}{1}}}}))
+		assert.Nil(t, checkUpdate(&ViewMod{Replace: true}))
+	}
+}
+
+func Test_UpdateMissingRecord(t *testing.T) {
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "missing"}, ClusterKey: map[string]interface{}{"value": "1"}}
+
+	forEachDriver(t, func(name string, d DBDriver) {
+		res := d.Update(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"field0": "a"}}}})
+		assert.Equal(t, int64(404), res.Status, name)
+
+		res = d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{view}})
+		assert.Equal(t, []*Record{nil}, res.Records, name)
+	})
+}
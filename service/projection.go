@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import "encoding/json"
+
+//projectRecord keeps only the given fields of decoded record values, all of them when none is given;
+//returns bytes saved - JSON size of the omitted fields
+func projectRecord(rec *Record, fields []string) int64 {
+	if rec == nil || len(fields) == 0 {
+		return 0
+	}
+
+	keep := make(map[string]bool, len(fields))
+
+	for _, f := range fields {
+		keep[f] = true
+	}
+
+	var saved int64
+
+	for k, v := range rec.Values {
+		if keep[k] {
+			continue
+		}
+
+		b, _ := json.Marshal(v)
+		// "key":value,
+		saved += int64(len(k) + len(b) + 4)
+
+		delete(rec.Values, k)
+	}
+
+	return saved
+}
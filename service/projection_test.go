@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_projectRecord(t *testing.T) {
+	{
+		rec := &Record{Values: map[string]interface{}{"a": 1, "b": "xy", "c": nil}}
+
+		assert.Equal(t, int64(len(`"b":"xy",`)+len(`"c":null,`)), projectRecord(rec, []string{"a", "missing"}))
+		assert.Equal(t, map[string]interface{}{"a": 1}, rec.Values)
+	}
+
+	{
+		rec := &Record{Values: map[string]interface{}{"a": 1}}
+
+		assert.Equal(t, int64(0), projectRecord(rec, nil))
+		assert.Equal(t, int64(0), projectRecord(nil, []string{"a"}))
+		assert.Equal(t, 1, len(rec.Values))
+	}
+}
+
+//forEachDriver calls f with initialized drivers of the YCSB scheme; drivers of cassandra are checked when
+//nodes are given
+func forEachDriver(t *testing.T, f func(name string, d DBDriver)) {
+	for _, name := range []string{"mem", "cas", "casp", "cast"} {
+		if name != "mem" && os.Getenv(HostsEnvironmentProperty) == "" {
+			t.Logf("%v: %v is not set", name, HostsEnvironmentProperty)
+			continue
+		}
+
+		cfg, err := LoadConfig([]string{"-d", name, SchemeAttribute, "../data/scheme.yml"}, os.LookupEnv)
+		assert.Nil(t, err)
+
+		d, err := newDriver(name, &Logger{})
+		assert.Nil(t, err)
+
+		if !assert.Nil(t, d.Init(cfg), name) {
+			continue
+		}
+
+		d.Clean(nil)
+		f(name, d)
+
+		assert.Nil(t, d.Free())
+	}
+}
+
+func Test_ReadSavedBytes(t *testing.T) {
+	view := ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "user1"}, ClusterKey: map[string]interface{}{"value": "1"}}
+
+	forEachDriver(t, func(name string, d DBDriver) {
+		res := d.Insert(&DBRequest{Partition: 1, ViewMods: []ViewMod{{ViewView: view, Values: map[string]interface{}{"field0": "a", "field1": "bb"}}}})
+		assert.Equal(t, int64(200), res.Status, name)
+
+		projected := view
+		projected.Fields = []string{"field0"}
+
+		res = d.Read(&DBRequest{Partition: 1, ViewViews: []ViewView{projected}})
+		assert.Equal(t, int64(200), res.Status, name)
+		assert.Equal(t, map[string]interface{}{"field0": "a"}, res.Records[0].Values, name)
+
+		// cast does not select columns of other fields, so nothing is decoded and omitted
+		if name == "cast" {
+			assert.Equal(t, int64(0), res.SavedBytes, name)
+		} else {
+			assert.Equal(t, int64(len(`"field1":"bb",`)), res.SavedBytes, name)
+		}
+	})
+}
+
+func Test_ProjectionMetrics(t *testing.T) {
+	body := `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Values":{"field0":"a","field1":"bb"}}]}`
+
+	tests := []struct {
+		fields string
+		values string
+		saved  string
+	}{
+		{`["field0"]`, `"Values":{"field0":"a"}`, `"projectionSavedBytes":14`},
+		{`["field0","field1"]`, `"Values":{"field0":"a","field1":"bb"}`, `"projectionSavedBytes":0`},
+	}
+
+	for _, test := range tests {
+		s, err := New(WithDriver("mem"))
+		assert.Nil(t, err)
+		defer s.Close()
+
+		serve(s.Handler(), "POST", funcPath(1, "YcsbAdd"), body)
+
+		rec := serve(s.Handler(), "POST", funcPath(1, "YcsbView"), `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Fields":`+test.fields+`}]}`)
+		assert.Contains(t, rec.Body.String(), test.values)
+		assert.NotContains(t, rec.Body.String(), "SavedBytes")
+
+		assert.Contains(t, serve(s.Handler(), "GET", funcPath(1, "YcsbMetric"), "").Body.String(), test.saved)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DriverFactory creates a fresh, not yet initialized driver instance
+type DriverFactory func(logger *Logger) DBDriver
+
+//Param describes a configuration parameter accepted by the service or a driver
+type Param struct {
+	//Name is the key in the config file, e.g. "cas.hosts"
+	Name        string
+	Attribute   string
+	Env         string
+	Default     string
+	Description string
+	Kind        ParamKind
+	//Values lists allowed values of KindEnum
+	Values []string
+	//Min and Max bound KindInt values; zero Max means no upper bound
+	Min int64
+	Max int64
+	//MinDuration bounds KindDuration values; zero means any duration, negative ones included
+	MinDuration time.Duration
+	//Secret values are redacted in the effective config output
+	Secret bool
+}
+
+//DriverRegistration s.e.
+type DriverRegistration struct {
+	Name        string
+	Description string
+	Params      []Param
+	Factory     DriverFactory
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]*DriverRegistration{}
+)
+
+//RegisterDriver makes a driver available under the given name (value of -d).
+//It panics if factory is nil or the name is already taken, the same way database/sql does.
+func RegisterDriver(name string, factory DriverFactory, description string, params ...Param) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if name == "" {
+		panic("service: RegisterDriver driver name is empty")
+	}
+
+	if factory == nil {
+		panic("service: RegisterDriver factory is nil for driver " + name)
+	}
+
+	if _, dup := drivers[name]; dup {
+		panic("service: RegisterDriver called twice for driver " + name)
+	}
+
+	drivers[name] = &DriverRegistration{
+		Name:        name,
+		Description: description,
+		Params:      params,
+		Factory:     factory,
+	}
+}
+
+//Drivers returns registered drivers sorted by name
+func Drivers() []DriverRegistration {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	list := make([]DriverRegistration, 0, len(drivers))
+
+	for _, r := range drivers {
+		list = append(list, *r)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+//DriverNames returns registered driver names sorted
+func DriverNames() []string {
+	list := Drivers()
+	names := make([]string, len(list))
+
+	for i, r := range list {
+		names[i] = r.Name
+	}
+
+	return names
+}
+
+func newDriver(name string, logger *Logger) (DBDriver, error) {
+	driversMu.RLock()
+	r, ok := drivers[name]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("wrong driver %q is given. Available: %v", name, strings.Join(DriverNames(), ", "))
+	}
+
+	return r.Factory(logger), nil
+}
+
+func describeDrivers() string {
+	str := ""
+
+	for _, r := range Drivers() {
+		str += fmt.Sprintf("  %v - %v\n", r.Name, r.Description)
+
+		for _, p := range r.Params {
+			str += "      " + describeParam(p) + "\n"
+		}
+	}
+
+	return str
+}
+
+func describeParam(p Param) string {
+	str := p.Attribute
+
+	if p.Env != "" {
+		str += fmt.Sprintf(" (env.v. %v)", p.Env)
+	}
+
+	if p.Name != "" {
+		str += fmt.Sprintf(" [%v]", p.Name)
+	}
+
+	str += " - " + p.Description
+
+	if p.Kind == KindEnum {
+		str += "; available values: " + strings.Join(p.Values, ", ")
+	}
+
+	if p.Default != "" {
+		str += fmt.Sprintf("; default is %q", p.Default)
+	}
+
+	return str
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RegisterDriver(t *testing.T) {
+	{
+		names := DriverNames()
+
+		assert.Contains(t, names, "cas")
+		assert.Contains(t, names, "casp")
+		assert.Contains(t, names, "light")
+		assert.Contains(t, names, "mem")
+		assert.Contains(t, names, NopDriverName)
+	}
+
+	{
+		RegisterDriver("test-registry", func(logger *Logger) DBDriver {
+			return &LightDriver{logger: logger}
+		}, "test driver", Param{Attribute: "--tst", Description: "test param"})
+
+		d, err := newDriver("test-registry", &Logger{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Light driver", d.Name())
+		assert.Contains(t, describeDrivers(), "--tst - test param")
+
+		assert.Panics(t, func() {
+			RegisterDriver("test-registry", func(logger *Logger) DBDriver { return nil }, "duplicate")
+		})
+	}
+
+	{
+		_, err := newDriver("unknown", &Logger{})
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "casp")
+	}
+}
@@ -0,0 +1,389 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Aggregate functions
+const (
+	//AggCount - number of records; with Field - of records having the field
+	AggCount = "count"
+	//AggSum - sum of numeric Field values
+	AggSum = "sum"
+	//AggMin - least Field value (numbers or strings)
+	AggMin = "min"
+	//AggMax - greatest Field value (numbers or strings)
+	AggMax = "max"
+	//AggAvg - average of numeric Field values
+	AggAvg = "avg"
+)
+
+//Aggregate is an aggregate function over Field of the scanned records
+type Aggregate struct {
+	Func  string
+	Field string `json:",omitempty"`
+
+	//As names the result value; default is Func or Func(Field)
+	As string `json:",omitempty"`
+}
+
+func (a *Aggregate) name() string {
+	switch {
+	case a.As != "":
+		return a.As
+	case a.Field != "":
+		return a.Func + "(" + a.Field + ")"
+	}
+
+	return a.Func
+}
+
+//scanSource reads a page of scanned records starting from the page state (empty - from the start) with
+//the given fields (all when none is given); next page state is empty after the last page. Drivers feed
+//their scans into executeScan with it.
+type scanSource func(fields []string, pageState []byte, limit int) (records []*Record, next []byte, err error)
+
+//checkScan validates request of Scan and returns the scanned view
+func checkScan(r *DBRequest) (*ViewView, error) {
+	if r == nil || len(r.ViewViews) != 1 {
+		return nil, fmt.Errorf("wrong request data: one view expected")
+	}
+
+	view := &r.ViewViews[0]
+
+	if view.GroupBy != "" && len(view.Aggregates) == 0 {
+		return nil, fmt.Errorf("scan of view %v: GroupBy is given without Aggregates", view.ViewType)
+	}
+
+	if len(view.Fields) > 0 && len(view.Aggregates) > 0 {
+		return nil, fmt.Errorf("scan of view %v: Fields can not be combined with Aggregates", view.ViewType)
+	}
+
+	for _, a := range view.Aggregates {
+		switch a.Func {
+		case AggCount:
+		case AggSum, AggMin, AggMax, AggAvg:
+			if a.Field == "" {
+				return nil, fmt.Errorf("scan of view %v: aggregate %v needs Field", view.ViewType, a.Func)
+			}
+		default:
+			return nil, fmt.Errorf("scan of view %v: aggregate function %q is unknown; available: %v, %v, %v, %v, %v", view.ViewType, a.Func, AggCount, AggSum, AggMin, AggMax, AggAvg)
+		}
+	}
+
+	return view, nil
+}
+
+//executeScan filters records of the source, then projects them or, with Aggregates, aggregates all
+//pages of the source into one record per group
+func executeScan(view *ViewView, source scanSource) *DBResponse {
+	var (
+		filter *Condition
+		err    error
+	)
+
+	if view.Filter != nil {
+		if filter, err = view.Filter.normalize(false); err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+	}
+
+	pageState, err := decodePageState(view.PageState)
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	if len(view.Aggregates) > 0 {
+		records, err := aggregateScan(view, filter, pageState, source)
+
+		if err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		}
+
+		return &DBResponse{Status: 200, Records: records}
+	}
+
+	page, next, err := source(scanFields(view), pageState, pageLimit(view))
+
+	if err != nil {
+		return &DBResponse{Status: 400, Error: err.Error()}
+	}
+
+	var (
+		records []*Record
+		saved   int64
+	)
+
+	for _, rec := range page {
+		if ok, err := filterRecord(filter, rec); err != nil {
+			return &DBResponse{Status: 400, Error: err.Error()}
+		} else if ok {
+			saved += projectRecord(rec, view.Fields)
+			records = append(records, rec)
+		}
+	}
+
+	return &DBResponse{Status: 200, Records: records, PageState: encodePageState(next), SavedBytes: saved}
+}
+
+//scanFields are the fields to read: projected fields and fields of the filter and aggregates; none
+//(all fields) without projection
+func scanFields(view *ViewView) []string {
+	var fields []string
+
+	if len(view.Aggregates) > 0 {
+		for _, a := range view.Aggregates {
+			if a.Field != "" {
+				fields = append(fields, a.Field)
+			}
+		}
+
+		if view.GroupBy != "" {
+			fields = append(fields, view.GroupBy)
+		}
+	} else {
+		fields = append(fields, view.Fields...)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if view.Filter != nil {
+		fields = append(fields, view.Filter.fields()...)
+	}
+
+	seen := map[string]bool{}
+	unique := fields[:0]
+
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			unique = append(unique, f)
+		}
+	}
+
+	return unique
+}
+
+func filterRecord(filter *Condition, rec *Record) (bool, error) {
+	if filter == nil {
+		return true, nil
+	}
+
+	ok, err := filter.evaluate(rec.Values)
+
+	if err != nil {
+		return false, fmt.Errorf("filter of record %v: %v", rec.Key, err)
+	}
+
+	return ok, nil
+}
+
+//aggregateGroup holds results of the aggregates for one value of the group-by field
+type aggregateGroup struct {
+	value   interface{}
+	count   []int64
+	sum     []float64
+	extreme []interface{}
+}
+
+//aggregateScan reads all pages of the source from the page state, at most MaxAggregatePages, and aggregates
+//filtered records; groups are ordered by value, records without the group-by field make a group of their own
+func aggregateScan(view *ViewView, filter *Condition, pageState []byte, source scanSource) ([]*Record, error) {
+	groups := map[string]*aggregateGroup{}
+	fields := scanFields(view)
+
+	for pages := 1; ; pages++ {
+		if pages > MaxAggregatePages {
+			return nil, fmt.Errorf("scan of view %v: aggregates read more than %v pages of %v records; raise Limit", view.ViewType, MaxAggregatePages, pageLimit(view))
+		}
+
+		page, next, err := source(fields, pageState, pageLimit(view))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rec := range page {
+			ok, err := filterRecord(filter, rec)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+
+			g, err := recordGroup(groups, view, rec)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := g.add(view.Aggregates, rec); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(next) == 0 {
+			break
+		}
+
+		pageState = next
+	}
+
+	list := make([]*aggregateGroup, 0, len(groups))
+
+	for _, g := range groups {
+		list = append(list, g)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return lessGroup(list[i].value, list[j].value)
+	})
+
+	records := make([]*Record, len(list))
+
+	for i, g := range list {
+		records[i] = g.record(view)
+	}
+
+	if view.GroupBy == "" && len(records) == 0 {
+		records = append(records, newAggregateGroup(nil, len(view.Aggregates)).record(view))
+	}
+
+	return records, nil
+}
+
+func newAggregateGroup(value interface{}, n int) *aggregateGroup {
+	return &aggregateGroup{value: value, count: make([]int64, n), sum: make([]float64, n), extreme: make([]interface{}, n)}
+}
+
+//recordGroup returns the group of the record, a new one for the first record of the group
+func recordGroup(groups map[string]*aggregateGroup, view *ViewView, rec *Record) (*aggregateGroup, error) {
+	var value interface{}
+
+	key := "null"
+
+	if view.GroupBy != "" && rec.Values[view.GroupBy] != nil {
+		value = rec.Values[view.GroupBy]
+
+		k, ok := indexValue(value)
+
+		if !ok {
+			return nil, fmt.Errorf("group by %v: value %v of record %v can not be grouped", view.GroupBy, value, rec.Key)
+		}
+
+		key = k
+	}
+
+	g, ok := groups[key]
+
+	if !ok {
+		g = newAggregateGroup(value, len(view.Aggregates))
+		groups[key] = g
+	}
+
+	return g, nil
+}
+
+func (g *aggregateGroup) add(aggregates []Aggregate, rec *Record) error {
+	for i, a := range aggregates {
+		v := rec.Values[a.Field]
+
+		if a.Func == AggCount {
+			if a.Field == "" || v != nil {
+				g.count[i]++
+			}
+
+			continue
+		}
+
+		if v == nil {
+			continue
+		}
+
+		switch a.Func {
+		case AggSum, AggAvg:
+			n, ok := numberValue(v)
+
+			if !ok {
+				return fmt.Errorf("aggregate %v: value %v of record %v is not a number", a.name(), v, rec.Key)
+			}
+
+			g.sum[i] += n
+		case AggMin, AggMax:
+			if g.extreme[i] != nil {
+				cmp, err := compareValues(v, g.extreme[i])
+
+				if err != nil {
+					return fmt.Errorf("aggregate %v of record %v: %v", a.name(), rec.Key, err)
+				}
+
+				if (a.Func == AggMin) != (cmp < 0) {
+					continue
+				}
+			}
+
+			g.extreme[i] = v
+		}
+
+		g.count[i]++
+	}
+
+	return nil
+}
+
+func (g *aggregateGroup) record(view *ViewView) *Record {
+	rec := &Record{Values: map[string]interface{}{}}
+
+	if g.value != nil {
+		rec.Key = fmt.Sprint(g.value)
+		rec.Values[view.GroupBy] = g.value
+	}
+
+	for i, a := range view.Aggregates {
+		var v interface{}
+
+		switch a.Func {
+		case AggCount:
+			v = g.count[i]
+		case AggSum:
+			v = g.sum[i]
+		case AggAvg:
+			if g.count[i] > 0 {
+				v = g.sum[i] / float64(g.count[i])
+			}
+		default:
+			v = g.extreme[i]
+		}
+
+		rec.Values[a.name()] = v
+	}
+
+	return rec
+}
+
+//lessGroup orders group values: the group without value first, then comparable values, then others by JSON
+func lessGroup(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+
+	if cmp, err := compareValues(a, b); err == nil {
+		return cmp < 0
+	}
+
+	ak, _ := indexValue(a)
+	bk, _ := indexValue(b)
+
+	return ak < bk
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_executeScan(t *testing.T) {
+	data := []*Record{
+		{Key: "a", Values: map[string]interface{}{"city": "x", "total": float64(10), "name": "ann"}},
+		{Key: "b", Values: map[string]interface{}{"city": "y", "total": float64(5), "name": "bob"}},
+		{Key: "c", Values: map[string]interface{}{"city": "x", "total": float64(1), "name": "cid"}},
+		{Key: "d", Values: map[string]interface{}{"total": float64(7)}},
+	}
+
+	// pages of two records; page state is the index of the next record
+	source := func(fields []string, pageState []byte, limit int) ([]*Record, []byte, error) {
+		from := 0
+
+		if len(pageState) > 0 {
+			from = int(pageState[0])
+		}
+
+		var page []*Record
+
+		for _, r := range data[from:] {
+			if len(page) == limit {
+				return page, []byte{byte(from + limit)}, nil
+			}
+
+			values := map[string]interface{}{}
+
+			for k, v := range r.Values {
+				values[k] = v
+			}
+
+			page = append(page, &Record{Key: r.Key, Values: values})
+		}
+
+		return page, nil, nil
+	}
+
+	scan := func(request string) *DBResponse {
+		view := &ViewView{}
+		assert.Nil(t, json.Unmarshal([]byte(request), view))
+
+		return executeScan(view, source)
+	}
+
+	{
+		res := scan(`{"Limit": 2, "Filter": {"Field": "city", "Op": "eq", "Value": "x"}, "Fields": ["name"]}`)
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, 1, len(res.Records))
+		assert.Equal(t, map[string]interface{}{"name": "ann"}, res.Records[0].Values)
+		assert.NotEmpty(t, res.PageState)
+	}
+
+	{
+		res := scan(`{"Limit": 2, "Aggregates": [{"Func": "count"}, {"Func": "sum", "Field": "total"}, {"Func": "avg", "Field": "total", "As": "mean"}, {"Func": "max", "Field": "name"}]}`)
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, map[string]interface{}{"count": int64(4), "sum(total)": float64(23), "mean": 5.75, "max(name)": "cid"}, res.Records[0].Values)
+		assert.Empty(t, res.PageState)
+	}
+
+	{
+		res := scan(`{"Limit": 3, "GroupBy": "city", "Filter": {"Field": "total", "Op": "gt", "Value": 1}, "Aggregates": [{"Func": "count"}, {"Func": "min", "Field": "total"}]}`)
+		assert.Equal(t, int64(200), res.Status)
+		assert.Equal(t, 3, len(res.Records))
+		assert.Equal(t, map[string]interface{}{"count": int64(1), "min(total)": float64(7)}, res.Records[0].Values)
+		assert.Equal(t, map[string]interface{}{"city": "x", "count": int64(1), "min(total)": float64(10)}, res.Records[1].Values)
+		assert.Equal(t, "y", res.Records[2].Key)
+	}
+
+	{
+		endless := func(fields []string, pageState []byte, limit int) ([]*Record, []byte, error) {
+			return []*Record{{Key: "a", Values: map[string]interface{}{}}}, []byte{1}, nil
+		}
+
+		res := executeScan(&ViewView{ViewType: "orders", Aggregates: []Aggregate{{Func: AggCount}}}, endless)
+		assert.Equal(t, int64(400), res.Status)
+		assert.Contains(t, res.Error, "more than 100 pages")
+	}
+
+	{
+		assert.Equal(t, int64(400), scan(`{"Aggregates": [{"Func": "sum", "Field": "name"}]}`).Status)
+		assert.Equal(t, int64(400), scan(`{"Filter": {"Field": "total", "Op": "lt", "Value": "x"}}`).Status)
+
+		for _, request := range []string{
+			`{"Aggregates": [{"Func": "median", "Field": "total"}]}`,
+			`{"Aggregates": [{"Func": "sum"}]}`,
+			`{"GroupBy": "city"}`,
+			`{"Fields": ["name"], "Aggregates": [{"Func": "count"}]}`,
+		} {
+			view := ViewView{}
+			assert.Nil(t, json.Unmarshal([]byte(request), &view))
+
+			_, err := checkScan(&DBRequest{ViewViews: []ViewView{view}})
+			assert.NotNil(t, err, request)
+		}
+	}
+}
+
+func Test_casandraScanLayouts(t *testing.T) {
+	request := &DBRequest{Partition: 1, ViewViews: []ViewView{{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "a"}}}}
+
+	// key of cas records is a part of the partition key, so cas does not scan
+	{
+		stmt := newCasandraStatements("ks", casandraRecordsTable, false)
+		assert.Empty(t, stmt.scan)
+
+		res := casandraScan(nil, stmt, request)
+		assert.Equal(t, int64(501), res.Status)
+		assert.Contains(t, res.Error, "usertable")
+	}
+
+	{
+		stmt := newCasandraStatements("ks", casandraPartitionedRecordsTable, true)
+		assert.Contains(t, stmt.scan, "key >= ?")
+		assert.NotContains(t, stmt.scan, "ALLOW FILTERING")
+	}
+}
@@ -0,0 +1,495 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Scheme describes views as given in data/scheme.yml
+type Scheme struct {
+	Author string      `yaml:"author"`
+	Descr  string      `yaml:"decsr"`
+	Views  ViewSchemes `yaml:"views"`
+}
+
+//ViewSchemes keeps views in the order of the scheme file
+type ViewSchemes []*ViewScheme
+
+//ViewScheme s.e.
+type ViewScheme struct {
+	Name         string
+	PartitionKey FieldSchemes `yaml:"partitionkey"`
+	ClusterKey   FieldSchemes `yaml:"clusterkey"`
+	Fields       FieldSchemes `yaml:"fields"`
+
+	// TTL is the default time to live of view records in seconds; 0 - records do not expire
+	TTL int64 `yaml:"ttl"`
+
+	// Indexes are fields records are looked up by
+	Indexes []string `yaml:"indexes"`
+}
+
+//FieldSchemes keeps fields in the order of the scheme file; key columns order matters
+type FieldSchemes []*FieldScheme
+
+//FieldScheme s.e.
+type FieldScheme struct {
+	Name string
+	Type string `yaml:"type"`
+	// Of is the element type of list, set and map fields; map keys are strings
+	Of string `yaml:"of"`
+	// Column is the table column name: key fields are prefixed with pk_ and ck_ since
+	// partition and cluster keys may have fields of the same name
+	Column string `yaml:"-"`
+}
+
+//schemeTypes maps scheme field types to CQL types
+var schemeTypes = map[string]string{
+	"string":  "text",
+	"int":     "bigint",
+	"float":   "double",
+	"bool":    "boolean",
+	"counter": "counter",
+}
+
+//collectionTypes are scheme field types of collections, their elements are of scalar types
+var collectionTypes = map[string]bool{
+	"list": true,
+	"set":  true,
+	"map":  true,
+}
+
+var identifierRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+//LoadScheme reads and validates scheme file
+func LoadScheme(path string) (*Scheme, error) {
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheme{}
+
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("scheme %v malformed: %v", path, err)
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("scheme %v malformed: %v", path, err)
+	}
+
+	return s, nil
+}
+
+//View s.e.
+func (s *Scheme) View(name string) *ViewScheme {
+	for _, v := range s.Views {
+		if v.Name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheme) validate() error {
+	if len(s.Views) == 0 {
+		return fmt.Errorf("no views declared")
+	}
+
+	for _, v := range s.Views {
+		if !identifierRe.MatchString(v.Name) {
+			return fmt.Errorf("view name %q should be a lower case identifier", v.Name)
+		}
+
+		if err := checkTTL(v.TTL); err != nil {
+			return fmt.Errorf("view %v: %v", v.Name, err)
+		}
+
+		if len(v.PartitionKey) == 0 {
+			return fmt.Errorf("view %v: partition key is empty", v.Name)
+		}
+
+		setColumns(v.PartitionKey, "pk_")
+		setColumns(v.ClusterKey, "ck_")
+		setColumns(v.Fields, "")
+
+		seen := map[string]bool{"partition": true}
+
+		for _, f := range v.Columns() {
+			if !identifierRe.MatchString(f.Name) {
+				return fmt.Errorf("view %v: field name %q should be a lower case identifier", v.Name, f.Name)
+			}
+
+			if seen[f.Column] {
+				return fmt.Errorf("view %v: field %q declared twice or reserved", v.Name, f.Name)
+			}
+
+			seen[f.Column] = true
+
+			if err := f.validateType(); err != nil {
+				return fmt.Errorf("view %v: %v", v.Name, err)
+			}
+		}
+
+		if err := v.validateCounters(); err != nil {
+			return err
+		}
+
+		if err := s.validateIndexes(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//validateIndexes checks indexed fields are scalar regular fields; cast keeps an index in table
+//<view>_by_<field>, which should not be a view
+func (s *Scheme) validateIndexes(v *ViewScheme) error {
+	seen := map[string]bool{}
+
+	for _, name := range v.Indexes {
+		f := v.Field(name)
+
+		if f == nil {
+			return fmt.Errorf("view %v: indexed field %q is not declared in fields", v.Name, name)
+		}
+
+		if f.Type == "counter" || f.Collection() {
+			return fmt.Errorf("view %v: %v field %v can not be indexed", v.Name, f.Type, name)
+		}
+
+		if seen[name] {
+			return fmt.Errorf("view %v: field %v is indexed twice", v.Name, name)
+		}
+
+		seen[name] = true
+
+		if s.View(indexTable(v.Name, name)) != nil {
+			return fmt.Errorf("view %v: index table %v of field %v is a view", v.Name, indexTable(v.Name, name), name)
+		}
+	}
+
+	return nil
+}
+
+func (f *FieldScheme) validateType() error {
+	if !collectionTypes[f.Type] {
+		if _, ok := schemeTypes[f.Type]; !ok {
+			return fmt.Errorf("field %v has unknown type %q; available: %v", f.Name, f.Type, strings.Join(schemeTypeNames(), ", "))
+		}
+
+		if f.Of != "" {
+			return fmt.Errorf("field %v: element type is given for %v", f.Name, f.Type)
+		}
+
+		return nil
+	}
+
+	if _, ok := schemeTypes[f.Of]; !ok || f.Of == "counter" {
+		return fmt.Errorf("field %v: %v element type %q should be one of string, int, float, bool", f.Name, f.Type, f.Of)
+	}
+
+	return nil
+}
+
+//Collection tells the field is a list, set or map
+func (f *FieldScheme) Collection() bool {
+	return collectionTypes[f.Type]
+}
+
+//cqlType is the CQL type of the field column
+func (f *FieldScheme) cqlType() string {
+	switch f.Type {
+	case "list", "set":
+		return f.Type + "<" + schemeTypes[f.Of] + ">"
+	case "map":
+		return "map<text, " + schemeTypes[f.Of] + ">"
+	}
+
+	return schemeTypes[f.Type]
+}
+
+//expires tells TTL of the column can be selected: cassandra does not select TTL of counters and collections
+func (f *FieldScheme) expires() bool {
+	return f.Type != "counter" && !f.Collection()
+}
+
+//Columns returns key columns followed by fields
+func (v *ViewScheme) Columns() FieldSchemes {
+	cols := make(FieldSchemes, 0, len(v.PartitionKey)+len(v.ClusterKey)+len(v.Fields))
+	cols = append(cols, v.PartitionKey...)
+	cols = append(cols, v.ClusterKey...)
+
+	return append(cols, v.Fields...)
+}
+
+//Counters tells the view is a counter table: its fields are counters
+func (v *ViewScheme) Counters() bool {
+	return len(v.Fields) > 0 && v.Fields[0].Type == "counter"
+}
+
+//validateCounters checks cassandra restrictions: a table has either counter fields only or none,
+//counters can not be keys and do not expire
+func (v *ViewScheme) validateCounters() error {
+	for _, f := range append(append(FieldSchemes{}, v.PartitionKey...), v.ClusterKey...) {
+		if f.Type == "counter" {
+			return fmt.Errorf("view %v: key field %v can not be a counter", v.Name, f.Name)
+		}
+
+		if f.Collection() {
+			return fmt.Errorf("view %v: key field %v can not be a collection", v.Name, f.Name)
+		}
+	}
+
+	for _, f := range v.Fields {
+		if (f.Type == "counter") != v.Counters() {
+			return fmt.Errorf("view %v: counter and other fields can not be mixed", v.Name)
+		}
+	}
+
+	if v.Counters() && v.TTL > 0 {
+		return fmt.Errorf("view %v: counters do not expire, ttl can not be given", v.Name)
+	}
+
+	return nil
+}
+
+//Field finds regular (not key) field
+func (v *ViewScheme) Field(name string) *FieldScheme {
+	for _, f := range v.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+//UnmarshalYAML keeps views order and names
+func (vs *ViewSchemes) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %v: views should be a map", node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		v := &ViewScheme{Name: node.Content[i].Value}
+
+		if err := node.Content[i+1].Decode(v); err != nil {
+			return err
+		}
+
+		*vs = append(*vs, v)
+	}
+
+	return nil
+}
+
+//UnmarshalYAML keeps fields order and names
+func (fs *FieldSchemes) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %v: fields should be a map", node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		f := &FieldScheme{Name: node.Content[i].Value}
+
+		if err := node.Content[i+1].Decode(f); err != nil {
+			return err
+		}
+
+		*fs = append(*fs, f)
+	}
+
+	return nil
+}
+
+func setColumns(fs FieldSchemes, prefix string) {
+	for _, f := range fs {
+		f.Column = prefix + f.Name
+	}
+}
+
+func schemeTypeNames() []string {
+	names := make([]string, 0, len(schemeTypes)+len(collectionTypes))
+
+	for n := range schemeTypes {
+		names = append(names, n)
+	}
+
+	for n := range collectionTypes {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+//columnValue converts JSON decoded value to the go type of the field column
+func columnValue(f *FieldScheme, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	if f.Collection() {
+		return collectionValue(f, v)
+	}
+
+	switch f.Type {
+	case "int", "counter":
+		switch n := v.(type) {
+		case float64:
+			if n != float64(int64(n)) {
+				return nil, fmt.Errorf("field %v: integer expected, got %v", f.Name, n)
+			}
+
+			return int64(n), nil
+		case int64:
+			return n, nil
+		case int:
+			return int64(n), nil
+		case string:
+			i, err := strconv.ParseInt(n, 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("field %v: integer expected, got %q", f.Name, n)
+			}
+
+			return i, nil
+		}
+	case "float":
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int64:
+			return float64(n), nil
+		case int:
+			return float64(n), nil
+		}
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "string":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("field %v: value %v is not of type %v", f.Name, v, f.Type)
+}
+
+//columnDest returns scan destination which keeps null columns distinguishable
+func columnDest(f *FieldScheme) interface{} {
+	if f.Collection() {
+		elem := reflect.TypeOf(destValueOf(columnDest(&FieldScheme{Type: f.Of})))
+
+		if f.Type == "map" {
+			return reflect.New(reflect.MapOf(reflect.TypeOf(""), elem)).Interface()
+		}
+
+		return reflect.New(reflect.SliceOf(elem)).Interface()
+	}
+
+	switch f.Type {
+	case "int", "counter":
+		return new(*int64)
+	case "float":
+		return new(*float64)
+	case "bool":
+		return new(*bool)
+	default:
+		return new(*string)
+	}
+}
+
+//destValue returns the value scanned into columnDest; false for null columns (and empty collections,
+//which cassandra does not distinguish from null)
+func destValue(dest interface{}) (interface{}, bool) {
+	switch p := dest.(type) {
+	case **int64:
+		if *p != nil {
+			return **p, true
+		}
+	case **float64:
+		if *p != nil {
+			return **p, true
+		}
+	case **bool:
+		if *p != nil {
+			return **p, true
+		}
+	case **string:
+		if *p != nil {
+			return **p, true
+		}
+	default:
+		if rv := reflect.ValueOf(dest).Elem(); rv.Len() > 0 {
+			return rv.Interface(), true
+		}
+	}
+
+	return nil, false
+}
+
+//destValueOf is a zero value of the scalar type scanned into dest
+func destValueOf(dest interface{}) interface{} {
+	return reflect.Zero(reflect.TypeOf(dest).Elem().Elem()).Interface()
+}
+
+//collectionValue converts JSON array (list, set) or object (map) to a slice or map of the element type
+func collectionValue(f *FieldScheme, v interface{}) (interface{}, error) {
+	elem := &FieldScheme{Name: f.Name, Type: f.Of}
+
+	if f.Type == "map" {
+		m, ok := toMap(v)
+
+		if !ok {
+			return nil, fmt.Errorf("field %v: value %v is not a map", f.Name, v)
+		}
+
+		for k, e := range m {
+			c, err := columnValue(elem, e)
+
+			if err != nil {
+				return nil, err
+			}
+
+			m[k] = c
+		}
+
+		return m, nil
+	}
+
+	l, ok := toList(v)
+
+	if !ok {
+		return nil, fmt.Errorf("field %v: value %v is not a %v", f.Name, v, f.Type)
+	}
+
+	for i, e := range l {
+		c, err := columnValue(elem, e)
+
+		if err != nil {
+			return nil, err
+		}
+
+		l[i] = c
+	}
+
+	return l, nil
+}
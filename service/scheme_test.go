@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadScheme(t *testing.T) {
+	{
+		s, err := LoadScheme("../data/scheme.yml")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Heeus", s.Author)
+		assert.Equal(t, 1, len(s.Views))
+
+		v := s.View("usertable")
+		assert.NotNil(t, v)
+		assert.Equal(t, "value", v.PartitionKey[0].Name)
+		assert.Equal(t, "value", v.ClusterKey[0].Name)
+		assert.Equal(t, "pk_value", v.PartitionKey[0].Column)
+		assert.Equal(t, "ck_value", v.ClusterKey[0].Column)
+		assert.Equal(t, 10, len(v.Fields))
+		assert.Equal(t, "field0", v.Fields[0].Name)
+		assert.Equal(t, "field9", v.Fields[9].Name)
+		assert.Nil(t, s.View("unknown"))
+	}
+
+	dir, err := ioutil.TempDir("", "scheme")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	load := func(data string) (*Scheme, error) {
+		file := filepath.Join(dir, "scheme.yml")
+		assert.Nil(t, ioutil.WriteFile(file, []byte(data), 0600))
+
+		return LoadScheme(file)
+	}
+
+	{
+		s, err := load("views:\n  orders:\n    partitionkey:\n      customer: {type: string}\n    clusterkey:\n      year: {type: int}\n      id: {type: string}\n    fields:\n      total: {type: float}\n      paid: {type: bool}\n")
+
+		assert.Nil(t, err)
+
+		v := s.View("orders")
+		assert.Equal(t, []string{"customer", "year", "id", "total", "paid"}, []string{v.Columns()[0].Name, v.Columns()[1].Name, v.Columns()[2].Name, v.Columns()[3].Name, v.Columns()[4].Name})
+		assert.NotNil(t, v.Field("paid"))
+		assert.Nil(t, v.Field("year"))
+	}
+
+	{
+		_, err := load("views:\n  orders:\n    fields:\n      total: {type: float}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  orders:\n    partitionkey:\n      id: {type: int}\n    fields:\n      pk_id: {type: int}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  orders:\n    partitionkey:\n      id: {type: uuid}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  Orders:\n    partitionkey:\n      id: {type: int}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  hits:\n    partitionkey:\n      id: {type: int}\n    fields:\n      n: {type: counter}\n      s: {type: string}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  hits:\n    ttl: 10\n    partitionkey:\n      id: {type: int}\n    fields:\n      n: {type: counter}\n")
+		assert.NotNil(t, err)
+	}
+
+	{
+		s, err := load("views:\n  hits:\n    partitionkey:\n      id: {type: int}\n    fields:\n      n: {type: counter}\n      m: {type: counter}\n")
+
+		assert.Nil(t, err)
+		assert.True(t, s.View("hits").Counters())
+	}
+
+	{
+		s, err := load("views:\n  posts:\n    partitionkey:\n      id: {type: int}\n    fields:\n      tags: {type: set, of: string}\n      scores: {type: map, of: float}\n")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "set<text>", s.View("posts").Field("tags").cqlType())
+		assert.Equal(t, "map<text, double>", s.View("posts").Field("scores").cqlType())
+		assert.False(t, s.View("posts").Field("tags").expires())
+
+		_, err = load("views:\n  posts:\n    partitionkey:\n      id: {type: int}\n    fields:\n      tags: {type: list}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  posts:\n    partitionkey:\n      tags: {type: list, of: string}\n")
+		assert.NotNil(t, err)
+	}
+
+	{
+		s, err := load("views:\n  users:\n    indexes: [email]\n    partitionkey:\n      id: {type: int}\n    fields:\n      email: {type: string}\n      tags: {type: set, of: string}\n")
+
+		assert.Nil(t, err)
+		assert.Equal(t, viewIndexes{"users": {"email"}}, schemeIndexes(s))
+
+		_, err = load("views:\n  users:\n    indexes: [name]\n    partitionkey:\n      id: {type: int}\n    fields:\n      email: {type: string}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  users:\n    indexes: [tags]\n    partitionkey:\n      id: {type: int}\n    fields:\n      tags: {type: set, of: string}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  users:\n    indexes: [email, email]\n    partitionkey:\n      id: {type: int}\n    fields:\n      email: {type: string}\n")
+		assert.NotNil(t, err)
+
+		_, err = load("views:\n  users:\n    indexes: [email]\n    partitionkey:\n      id: {type: int}\n    fields:\n      email: {type: string}\n  users_by_email:\n    partitionkey:\n      id: {type: int}\n")
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_columnValue(t *testing.T) {
+	{
+		v, err := columnValue(&FieldScheme{Name: "n", Type: "int"}, float64(42))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(42), v)
+
+		_, err = columnValue(&FieldScheme{Name: "n", Type: "int"}, 4.2)
+		assert.NotNil(t, err)
+
+		_, err = columnValue(&FieldScheme{Name: "s", Type: "string"}, true)
+		assert.NotNil(t, err)
+
+		v, err = columnValue(&FieldScheme{Name: "s", Type: "string"}, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, v)
+	}
+
+	{
+		dest := columnDest(&FieldScheme{Name: "n", Type: "int"})
+		_, ok := destValue(dest)
+		assert.False(t, ok)
+
+		n := int64(7)
+		*dest.(**int64) = &n
+		v, ok := destValue(dest)
+		assert.True(t, ok)
+		assert.Equal(t, int64(7), v)
+	}
+
+	{
+		f := &FieldScheme{Name: "l", Type: "list", Of: "int"}
+
+		v, err := columnValue(f, []interface{}{float64(1), float64(2)})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{int64(1), int64(2)}, v)
+
+		_, err = columnValue(f, []interface{}{"x"})
+		assert.NotNil(t, err)
+
+		dest := columnDest(f)
+		_, ok := destValue(dest)
+		assert.False(t, ok)
+
+		*dest.(*[]int64) = []int64{3}
+		v, ok = destValue(dest)
+		assert.True(t, ok)
+		assert.Equal(t, []int64{3}, v)
+
+		_, ok = columnDest(&FieldScheme{Name: "m", Type: "map", Of: "bool"}).(*map[string]bool)
+		assert.True(t, ok)
+	}
+}
@@ -10,8 +10,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -30,6 +30,21 @@ const (
 	mnBatchInterval   = "batchInterval"
 	mnCacheViewCnt    = "cacheViewCnt"
 	mnNotCacheViewCnt = "notCacheViewCnt"
+	mnSavedBytes      = "projectionSavedBytes"
+	mnSlowOpCnt       = "slowOpCnt"
+
+	mnLatency            = "latency"
+	mnLatencyWindow      = "latencyWindow"
+	mnLatencyWindowStart = "latencyWindowStart"
+
+	mnPartitionsCount   = "partitionsCount"
+	mnPartitionsRecords = "partitionsRecords"
+	mnLargestPartitions = "largestPartitions"
+
+	mnHotReadPartitions  = "hotReadPartitions"
+	mnHotWritePartitions = "hotWritePartitions"
+	mnHotReadKeys        = "hotReadKeys"
+	mnHotWriteKeys       = "hotWriteKeys"
 )
 
 //Service s.e.
@@ -37,11 +52,16 @@ type Service struct {
 	driver DBDriver
 	port   int64
 
-	readFunc   string
-	insertFunc string
-	updateFunc string
-	deleteFunc string
-	scanFunc   string
+	//driverInit tells the driver is initialized, so it is freed when the service fails to initialize
+	driverInit bool
+
+	readFunc      string
+	insertFunc    string
+	updateFunc    string
+	deleteFunc    string
+	scanFunc      string
+	incrementFunc string
+	lookupFunc    string
 
 	pathPattern string
 
@@ -49,68 +69,271 @@ type Service struct {
 
 	noop bool
 
+	driverName string
+	config     *Config
+
+	router      http.Handler
+	adminRouter http.Handler
+	admin       *admin
+	closeOnce   sync.Once
+
 	logger *Logger
 
+	hot    *hotTracker
+	slow   *slowLog
+	tracer *Tracer
+
+	latency *latencyRecorder
+
+	startup       *startupProgress
+	ready         int32
+	healthTimeout time.Duration
+
+	traceExporter TraceExporter
+	traceSample   int
+
+	initChecks []func(d DBDriver) error
+
 	EventCount      int64
 	BatchCount      int64
 	BatchDurationNS int64
-	PartitionsSize  int64
 	HcCnt           int64
 	HcDurNs         int64
 	CacheViewCnt    int64
 	NotCacheViewCnt int64
+	SavedBytes      int64
+	SlowOpCnt       int64
 }
 
-//Init s.e.
-func (s *Service) Init() error {
-	args := mapArgs(os.Args)
+//New creates a service configured by the given options and initializes its driver
+func New(opts ...Option) (*Service, error) {
+	s := &Service{
+		port:          DefaultPort,
+		pathPattern:   DefaultPathPattern,
+		readFunc:      ReadDefaultFunc,
+		insertFunc:    InsertDefaultFunc,
+		updateFunc:    UpdateDefaultFunc,
+		deleteFunc:    DeleteDefaultFunc,
+		scanFunc:      ScanDefaultFunc,
+		incrementFunc: IncrementDefaultFunc,
+		lookupFunc:    LookupDefaultFunc,
+		driverName:    DefaultDriver,
+		startup:       &startupProgress{},
+		healthTimeout: DefaultHealthTimeout,
+	}
+
+	windows, _ := parseHotWindows(splitList(DefaultHotWindows))
+	s.hot = newHotTracker(DefaultHotTop, windows)
+	s.slow, _ = newSlowLog(DefaultSlowLogConfig())
+	s.admin, _ = newAdmin(DefaultAdminConfig())
+
+	err := s.startup.run("options", func() error {
+		for _, opt := range opts {
+			if err := opt(s); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 
-	s.noop = initBoolParam(args, NoopServiceEnvironmentProperty, NoopServiceAttribute, false)
+	if err != nil {
+		return s.abort(err)
+	}
 
-	s.logger = &Logger{}
-	s.logger.level = initIntParam(args, LoggerLevelEnvironmentProperty, LoggerLevelAttribute, 0)
+	if s.logger == nil {
+		s.logger = NewLogger(InfoLevel, LogFormatText)
+	}
+
+	if s.config == nil {
+		s.config = DefaultConfig()
+	}
 
 	s.flushMetrics()
 
-	if s.noop {
-		s.driver = &NopDriver{logger: s.logger}
-	} else if d, err := s.getServiceDriver(args); err == nil {
-		s.driver = d
-	} else {
+	if err := s.startup.run("port", s.checkServicePort); err != nil {
 		s.logger.Error(err.Error())
-		return err
+		return s.abort(err)
 	}
 
-	if p, err := s.getServicePort(args); err == nil {
-		s.port = p
-	} else {
-		s.logger.Error(err.Error())
-		return err
+	if s.driver == nil {
+		err := s.startup.run("driver", func() error {
+			d, err := s.getServiceDriver()
+			s.driver = d
+
+			return err
+		})
+
+		if err != nil {
+			s.logger.Error(err.Error())
+			return s.abort(err)
+		}
 	}
 
-	if err := s.driver.Init(args); err != nil {
+	if err := s.startup.run("driver init", func() error { return s.driver.Init(s.config) }); err != nil {
 		s.logger.Error(err.Error())
-		return err
+		return s.abort(err)
+	}
+
+	s.driverInit = true
+
+	for i, check := range s.initChecks {
+		if err := s.startup.run(fmt.Sprintf("init check %v", i+1), func() error { return check(s.driver) }); err != nil {
+			s.logger.Error(err.Error())
+			return s.abort(err)
+		}
+	}
+
+	if s.traceExporter != nil {
+		s.tracer = NewTracer(s.traceExporter, s.traceSample, s.logger)
 	}
 
-	//init path pattern
+	s.latency = newLatencyRecorder([]string{s.readFunc, s.insertFunc, s.updateFunc, s.scanFunc, s.deleteFunc, s.incrementFunc, s.lookupFunc})
+
+	s.router = withRequestID(s.buildRouter())
+	s.adminRouter = withRequestID(http.HandlerFunc(s.handleAdminDisabled))
+
+	if s.admin.cfg.protected() {
+		s.adminRouter = withRequestID(s.adminAuth(s.buildAdminRouter()))
+	}
 
-	s.pathPattern = initStringParam(args, PathPatternEnvironmentProperty, PathPatternAttribute, DefaultPathPattern)
-	s.readFunc = initStringParam(args, ServiceReadFuncEnvironmentProperty, ServiceReadFuncAttribute, ReadDefaultFunc)
-	s.insertFunc = initStringParam(args, ServiceInsertFuncEnvironmentProperty, ServiceInsertFuncAttribute, InsertDefaultFunc)
-	s.updateFunc = initStringParam(args, ServiceUpdateFuncEnvironmentProperty, ServiceUpdateFuncAttribute, UpdateDefaultFunc)
-	s.deleteFunc = initStringParam(args, ServiceDeleteFuncEnvironmentProperty, ServiceDeleteFuncAttribute, DeleteDefaultFunc)
-	s.scanFunc = initStringParam(args, ServiceScanFuncEnvironmentProperty, ServiceScanFuncAttribute, ScanDefaultFunc)
+	s.startup.finish()
+	atomic.StoreInt32(&s.ready, 1)
 
 	s.logger.Debug("service successfully initialized")
 
+	return s, nil
+}
+
+//abort frees resources of the service which failed to initialize
+func (s *Service) abort(err error) (*Service, error) {
+	if s.driverInit {
+		s.driver.Free()
+	}
+
+	s.slow.close()
+	s.admin.close()
+
+	if s.traceExporter != nil {
+		s.traceExporter.Close()
+	}
+
+	return nil, err
+}
+
+//Handler returns the data routes; can be mounted into any http server
+func (s *Service) Handler() http.Handler {
+	return s.router
+}
+
+//AdminHandler returns the administrative routes which require the configured token or client
+//certificate; without both it denies all requests. It should not be mounted where the data routes are
+//reachable by everyone
+func (s *Service) AdminHandler() http.Handler {
+	return s.adminRouter
+}
+
+//Run listens on the service port and on the admin port, if any, until ctx is done
+func (s *Service) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%v", s.port),
+		Handler: s.router,
+	}
+
+	servers := []*http.Server{srv}
+	errs := make(chan error, 2)
+
+	s.timestart = time.Now().Unix()
+
+	go func() {
+		s.logger.Info("Listening at localhost%v \n", srv.Addr)
+
+		errs <- srv.ListenAndServe()
+	}()
+
+	if s.admin.cfg.Port > 0 {
+		adminSrv := &http.Server{
+			Addr:      s.admin.addr(),
+			Handler:   s.adminRouter,
+			TLSConfig: s.admin.tls,
+		}
+
+		servers = append(servers, adminSrv)
+
+		go func() {
+			if adminSrv.TLSConfig != nil {
+				s.logger.Info("Admin listening at https://%v", adminSrv.Addr)
+				errs <- adminSrv.ListenAndServeTLS("", "")
+				return
+			}
+
+			s.logger.Info("Admin listening at http://%v", adminSrv.Addr)
+			errs <- adminSrv.ListenAndServe()
+		}()
+	}
+
+	var failed error
+
+	select {
+	case failed = <-errs:
+	case <-ctx.Done():
+	}
+
+	// readiness fails while connections are drained
+	atomic.StoreInt32(&s.ready, 0)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil && failed == nil {
+			failed = err
+		}
+	}
+
+	if failed != nil {
+		return failed
+	}
+
+	for range servers {
+		if err := <-errs; err != http.ErrServerClosed {
+			return err
+		}
+	}
+
 	return nil
 }
 
-//Start s.e.
-func (s *Service) Start() {
-	defer s.Stop()
+//Close frees the driver; the service must not be used after
+func (s *Service) Close() error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		atomic.StoreInt32(&s.ready, 0)
+		s.flushMetrics()
+		err = s.driver.Free()
 
+		if serr := s.slow.close(); err == nil {
+			err = serr
+		}
+
+		if aerr := s.admin.close(); err == nil {
+			err = aerr
+		}
+
+		if s.tracer != nil {
+			if terr := s.tracer.Close(); err == nil {
+				err = terr
+			}
+		}
+
+		s.logger.Info("Service stoped")
+	})
+
+	return err
+}
+
+func (s *Service) buildRouter() http.Handler {
 	r := mux.NewRouter()
 
 	r.NotFoundHandler = http.HandlerFunc(s.Handle404)
@@ -118,46 +341,82 @@ func (s *Service) Start() {
 	r.HandleFunc(s.pathPattern, s.handle)
 	r.HandleFunc(s.pathPattern+"/", s.handle)
 
+	r.HandleFunc("/healthz", s.handleHealthz)
+	r.HandleFunc("/readyz", s.handleReadyz)
+
+	r.HandleFunc("/api", s.handleRoot)
+	r.HandleFunc("/api/", s.handleRoot)
+
+	return r
+}
+
+//buildAdminRouter builds routes of the admin listener: destructive and diagnostic ones
+func (s *Service) buildAdminRouter() http.Handler {
+	r := mux.NewRouter()
+
+	r.NotFoundHandler = http.HandlerFunc(s.Handle404)
+
 	r.HandleFunc("/api/driver/clean", s.handleClean)
 	r.HandleFunc("/api/driver/clean/", s.handleClean)
 
 	r.HandleFunc("/api/vars", s.handleVars)
 	r.HandleFunc("/api/vars/", s.handleVars)
 
-	r.HandleFunc("/api", s.handleRoot)
-	r.HandleFunc("/api/", s.handleRoot)
+	r.HandleFunc("/api/config", s.handleConfig)
+	r.HandleFunc("/api/config/", s.handleConfig)
 
-	//todo add port arg
+	r.HandleFunc("/api/partitions", s.handlePartitions)
+	r.HandleFunc("/api/partitions/", s.handlePartitions)
+	r.HandleFunc("/api/partitions/{partition}", s.handlePartitions)
 
-	path := fmt.Sprintf(":%v", s.port)
+	r.HandleFunc("/api/hot", s.handleHot)
+	r.HandleFunc("/api/hot/", s.handleHot)
 
-	go func() {
-		s.logger.Log("Listening at localhost%v \n", path)
+	r.HandleFunc("/api/slow", s.handleSlow)
+	r.HandleFunc("/api/slow/", s.handleSlow)
 
-		s.timestart = time.Now().Unix()
+	r.HandleFunc("/api/log", s.handleLog)
+	r.HandleFunc("/api/log/", s.handleLog)
 
-		http.ListenAndServe(path, r)
-	}()
+	r.HandleFunc("/api/metrics", s.handleAdminMetrics)
+	r.HandleFunc("/api/metrics/", s.handleAdminMetrics)
 
-	// Setting up signal capturing
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	r.HandleFunc("/api/histograms", s.handleHistograms)
+	r.HandleFunc("/api/histograms/", s.handleHistograms)
 
-	// Waiting for SIGINT (pkill -2)
-	<-stop
+	r.HandleFunc("/healthz", s.handleHealthz)
+	r.HandleFunc("/readyz", s.handleReadyz)
 
-	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return r
 }
 
-//Stop  s.e.
-func (s *Service) Stop() {
-	s.flushMetrics()
-	s.driver.Free()
-	s.logger.Log("Service stoped")
+//handleMetrics responds counters and latencies; it is read-only as it is served on the data port
+func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	total, window := s.latency.histograms(false)
+
+	s.writeMetrics(w, s.metrics(total, window))
 }
 
-func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
+//handleAdminMetrics adds hot keys to the metrics; reset query parameter starts a new latency window
+func (s *Service) handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	reset, _ := strconv.ParseBool(r.URL.Query().Get("reset"))
+	total, window := s.latency.histograms(reset)
+
+	resp := s.metrics(total, window)
+
+	if s.hot != nil {
+		latest := s.hot.latest()
+
+		resp[mnHotReadPartitions] = latest.Read.Partitions
+		resp[mnHotWritePartitions] = latest.Write.Partitions
+		resp[mnHotReadKeys] = latest.Read.Keys
+		resp[mnHotWriteKeys] = latest.Write.Keys
+	}
+
+	s.writeMetrics(w, resp)
+}
+
+func (s *Service) metrics(total, window *LatencyHistograms) map[string]interface{} {
 	resp := map[string]interface{}{}
 
 	resp["Status"] = 200
@@ -168,11 +427,54 @@ func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	resp[mnBatchInterval] = 0
 	resp[mnHcCnt] = s.getMetricHcCnt()
 	resp[mnHcDurNs] = s.getMetricHcDurNs()
-	resp[mnPartitionsSize] = s.getPartitionsSize()
 	resp[mnPutCount] = s.getPutCount()
 	resp[mnCacheViewCnt] = s.getCacheViewCnt()
 	resp[mnNotCacheViewCnt] = s.getNotCacheViewCnt()
+	resp[mnSavedBytes] = s.getSavedBytes()
+	resp[mnSlowOpCnt] = atomic.LoadInt64(&s.SlowOpCnt)
+
+	resp[mnLatency] = total.Summary()
+	resp[mnLatencyWindow] = window.Summary()
+	resp[mnLatencyWindowStart] = window.Start
+
+	s.addPartitionsMetrics(resp)
+
+	return resp
+}
 
+//addPartitionsMetrics adds totals and the largest partitions, if the driver tracks statistics
+func (s *Service) addPartitionsMetrics(resp map[string]interface{}) {
+	stats, err := s.partitionsStats()
+
+	if err != nil {
+		if err != ErrStatsNotTracked {
+			s.logger.Warn("partition statistics: %v", err)
+		}
+
+		resp[mnPartitionsSize] = 0
+		return
+	}
+
+	var records, size int64
+
+	for _, p := range stats {
+		records += p.Records
+		size += p.Bytes
+	}
+
+	largest := largestPartitions(stats, LargestPartitionsCount)
+
+	for i, p := range largest {
+		largest[i] = &PartitionStats{Partition: p.Partition, Records: p.Records, Bytes: p.Bytes}
+	}
+
+	resp[mnPartitionsSize] = size
+	resp[mnPartitionsCount] = len(stats)
+	resp[mnPartitionsRecords] = records
+	resp[mnLargestPartitions] = largest
+}
+
+func (s *Service) writeMetrics(w http.ResponseWriter, resp map[string]interface{}) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
@@ -188,13 +490,210 @@ func (s *Service) handleMetrics(w http.ResponseWriter, r *http.Request) {
 
 }
 
+//partitionsStats returns statistics of all partitions, if the driver tracks them
+func (s *Service) partitionsStats() ([]*PartitionStats, error) {
+	p, ok := s.driver.(PartitionStatsProvider)
+
+	if !ok {
+		return nil, ErrStatsNotTracked
+	}
+
+	return p.PartitionsStats()
+}
+
+//handlePartitions responds statistics of the partition given in path or of all partitions, the largest
+//first; limit query parameter bounds their number
+func (s *Service) handlePartitions(w http.ResponseWriter, r *http.Request) {
+	var (
+		resp interface{}
+		err  error
+	)
+
+	p, ok := s.driver.(PartitionStatsProvider)
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("driver %v does not track partition statistics", s.driver.Name()), http.StatusNotImplemented)
+		return
+	}
+
+	if partition, given := mux.Vars(r)["partition"]; given {
+		id, perr := strconv.ParseInt(partition, 10, 64)
+
+		if perr != nil {
+			http.Error(w, fmt.Sprintf("partition %q is not a number", partition), http.StatusBadRequest)
+			return
+		}
+
+		resp, err = p.PartitionStats(id)
+	} else {
+		var stats []*PartitionStats
+
+		if stats, err = p.PartitionsStats(); err == nil {
+			limit := len(stats)
+
+			if l := r.URL.Query().Get("limit"); l != "" {
+				if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+					http.Error(w, fmt.Sprintf("limit %q is malformed", l), http.StatusBadRequest)
+					return
+				}
+			}
+
+			resp = largestPartitions(stats, limit)
+		}
+	}
+
+	if err == ErrStatsNotTracked {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(resp)
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//handleHot responds the most accessed partitions and keys of all windows or of the one given by window
+//query parameter, e.g. ?window=1m0s
+func (s *Service) handleHot(w http.ResponseWriter, r *http.Request) {
+	if s.hot == nil {
+		http.Error(w, "hot keys are not tracked; see "+HotTopAttribute, http.StatusNotImplemented)
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+
+	if window != "" {
+		if d, err := time.ParseDuration(window); err == nil {
+			window = d.String()
+		}
+	}
+
+	windows, err := s.hot.report(window)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bytes, err := json.Marshal(windows)
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//handleSlow responds the latest slow operations, the latest first; limit query parameter bounds their
+//number
+func (s *Service) handleSlow(w http.ResponseWriter, r *http.Request) {
+	limit := s.slow.cfg.Size
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("limit %q is malformed", l), http.StatusBadRequest)
+			return
+		}
+	}
+
+	total, entries := s.slow.list(limit)
+
+	bytes, err := json.Marshal(map[string]interface{}{"Total": total, "Entries": entries})
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//handleLog responds level and format of the logger; PUT or POST with level query parameter changes the
+//level, e.g. ?level=debug
+func (s *Service) handleLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		level, err := ParseLevel(r.URL.Query().Get("level"))
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if old := s.logger.Level(); old != level {
+			s.logger.SetLevel(level)
+			s.audit(r, adminSubject(r), AuditLogLevel, fmt.Sprintf("%v -> %v", old, level), http.StatusOK, nil)
+		}
+	}
+
+	bytes, err := json.Marshal(map[string]string{"Level": s.logger.Level().String(), "Format": s.logger.Format()})
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//handleHistograms responds raw latency histograms since start and of the current window, which can be
+//merged with histograms of other instances; reset query parameter starts a new window
+func (s *Service) handleHistograms(w http.ResponseWriter, r *http.Request) {
+	reset, _ := strconv.ParseBool(r.URL.Query().Get("reset"))
+	total, window := s.latency.histograms(reset)
+
+	bytes, err := json.Marshal(map[string]interface{}{"Total": total, "Window": window})
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
+//handleClean removes all records of the driver by POST or DELETE; the action is audited
 func (s *Service) handleClean(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, fmt.Sprintf("clean is not allowed by %v", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
 	res := s.driver.Clean(nil)
 
+	var cleanErr error
+
 	if res.Error != "" {
 		s.logger.Error("DB driver clean error: %v", res.Error)
+		cleanErr = fmt.Errorf("%v", res.Error)
 	}
 
+	s.audit(r, adminSubject(r), AuditClean, "driver "+s.driver.Name(), int(res.Status), cleanErr)
+
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
@@ -221,6 +720,7 @@ func (s *Service) handleRoot(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Starts at: %v. \n", time.Unix(s.timestart, 0))
 	fmt.Fprintf(w, "Path pattern: %v. \n", s.pathPattern)
 	fmt.Fprintf(w, "\n %v", s.driver.Info())
+	fmt.Fprintf(w, "\nAvailable drivers: \n\n%v", describeDrivers())
 }
 
 func (s *Service) handleVars(w http.ResponseWriter, r *http.Request) {
@@ -235,12 +735,48 @@ func (s *Service) handleVars(w http.ResponseWriter, r *http.Request) {
 
 	if len(envs) > 0 {
 
+		secrets := s.config.secretEnvs()
+
 		for k, e := range envs {
-			fmt.Fprintf(w, "%v. %v;\n", k, e)
+			fmt.Fprintf(w, "%v. %v;\n", k, redactEnv(e, secrets))
 		}
 	}
 }
 
+func (s *Service) handleConfig(w http.ResponseWriter, r *http.Request) {
+	type configEntry struct {
+		Name      string
+		Value     string
+		Source    Source
+		Attribute string
+		Env       string
+	}
+
+	effective := s.config.Effective()
+	resp := make([]configEntry, len(effective))
+
+	for i, v := range effective {
+		resp[i] = configEntry{
+			Name:      v.Param.Name,
+			Value:     v.Value,
+			Source:    v.Source,
+			Attribute: v.Param.Attribute,
+			Env:       v.Param.Env,
+		}
+	}
+
+	bytes, err := json.Marshal(resp)
+
+	if err != nil {
+		s.logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(bytes)
+}
+
 func (s *Service) handle(w http.ResponseWriter, r *http.Request) {
 	startHc := time.Now()
 
@@ -261,29 +797,48 @@ func (s *Service) handle(w http.ResponseWriter, r *http.Request) {
 
 	wsid := params["wsid"]
 
-	req, err := buildRequest(r)
+	id := requestID(r)
+
+	log := s.logger.With("requestId", id)
+
+	span := s.startSpan(r, f, id)
+	defer span.finish()
+
+	decode := span.child("buildRequest")
+	req, body, err := buildRequest(r)
+	decode.set("http.request_content_length", len(body))
+	decode.finish()
 
 	if err != nil {
-		s.logger.Error(err.Error())
+		log.Error(err.Error())
+		span.fail(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	s.logger.Debug("Handle request: ")
-	s.logger.Debug("%v", r.URL.Path)
-	s.logger.Debug("%v", req)
+	log.Debug("Handle request %v: %s", r.URL.Path, body)
 
+	req.RequestID = id
 	req.Partition, err = strconv.ParseInt(wsid, 10, 64)
 
 	if err != nil {
-		s.logger.Error(err.Error())
+		log.Error(err.Error())
+		span.fail(err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var res *DBResponse
 
+	op := span.child("driver " + f)
+
+	if op != nil {
+		op.set("db.partition", req.Partition)
+		req = req.WithContext(contextWithSpan(r.Context(), op))
+	}
+
 	startBatch := time.Now()
+	decodeDur := startBatch.Sub(startHc)
 
 	switch f {
 	case s.readFunc:
@@ -296,22 +851,71 @@ func (s *Service) handle(w http.ResponseWriter, r *http.Request) {
 		res = s.driver.Scan(req)
 	case s.deleteFunc:
 		res = s.driver.Delete(req)
+	case s.incrementFunc:
+		i, ok := s.driver.(Incrementer)
+
+		if !ok {
+			s.notImplemented(w, log, span, f)
+			return
+		}
+
+		res = i.Increment(req)
+	case s.lookupFunc:
+		l, ok := s.driver.(Lookuper)
+
+		if !ok {
+			s.notImplemented(w, log, span, f)
+			return
+		}
+
+		res = l.Lookup(req)
 	default:
 		str := fmt.Sprintf("Func %q not allowed!", f)
-		s.logger.Error(str)
+		log.Error(str)
+		span.fail(str)
 		http.Error(w, str, http.StatusInternalServerError)
 		return
 	}
 
-	atomic.AddInt64(&s.BatchDurationNS, time.Since(startBatch).Nanoseconds())
+	driverDur := time.Since(startBatch)
+
+	op.set("db.status", res.Status)
+	op.set("db.records", len(res.Records))
+	op.fail(res.Error)
+	op.finishAt(startBatch.Add(driverDur))
+
+	atomic.AddInt64(&s.BatchDurationNS, driverDur.Nanoseconds())
+
+	s.trackHot(f, req)
 
 	if res.Error != "" {
-		s.logger.Error("DB driver proccessing error: %v", res.Error)
+		log.Error("DB driver proccessing error: %v (%v, partition %v)", res.Error, driverDur, req.Partition)
 	}
 
+	startEncode := time.Now()
 	bytes := res.stringify()
 
-	s.logger.Debug("Response: %v", string(bytes))
+	if s.slow.slow(f, driverDur) {
+		op := newSlowOp(f, req, res, body, s.slow.cfg.Sample)
+		op.TotalNs = time.Since(startHc).Nanoseconds()
+		op.DecodeNs = decodeDur.Nanoseconds()
+		op.DriverNs = driverDur.Nanoseconds()
+		op.EncodeNs = time.Since(startEncode).Nanoseconds()
+
+		if span != nil {
+			op.TraceID = span.TraceID
+		}
+
+		atomic.AddInt64(&s.SlowOpCnt, 1)
+
+		if err := s.slow.add(op); err != nil {
+			log.Error("slow log: %v", err)
+		}
+	}
+
+	if log.Enabled(DebugLevel) {
+		log.Debug("Response: %s", bytes)
+	}
 
 	atomic.AddInt64(&s.HcDurNs, time.Since(startHc).Nanoseconds())
 
@@ -320,51 +924,112 @@ func (s *Service) handle(w http.ResponseWriter, r *http.Request) {
 	atomic.AddInt64(&s.BatchCount, 1)
 	atomic.AddInt64(&s.CacheViewCnt, 0)
 	atomic.AddInt64(&s.NotCacheViewCnt, 1)
+	atomic.AddInt64(&s.SavedBytes, res.SavedBytes)
 
 	w.Header().Add("Content-Type", "application/json")
 	w.Write(bytes)
+
+	s.latency.record(f, time.Since(startHc), driverDur)
 }
 
-func (s *Service) getServicePort(args map[string]string) (int64, error) {
-	port := initIntParam(args, ServicePortEnvironmentProperty, ServicePortAttribute, DefaultPort)
+//notImplemented responds 501 to the function which the driver does not support
+func (s *Service) notImplemented(w http.ResponseWriter, log *Logger, span *Span, f string) {
+	str := fmt.Sprintf("driver %v does not support %v", s.driver.Name(), f)
+	log.Warn(str)
+	span.fail(str)
+	http.Error(w, str, http.StatusNotImplemented)
+}
+
+//trackHot counts accesses of the request; scans and lookups count the partition only, as they do not
+//address records by key
+func (s *Service) trackHot(f string, r *DBRequest) {
+	if s.hot == nil {
+		return
+	}
 
-	if port < 0 && port > 65535 {
-		return 0, fmt.Errorf("port value should be greater than 0 and less than 65535")
+	switch f {
+	case s.readFunc, s.deleteFunc:
+		s.hot.add(f == s.deleteFunc, r)
+	case s.insertFunc, s.updateFunc, s.incrementFunc:
+		s.hot.add(true, r)
+	case s.scanFunc, s.lookupFunc:
+		s.hot.add(false, &DBRequest{Partition: r.Partition})
 	}
+}
+
+type requestIDKey struct{}
+
+//withRequestID gives every request an ID: the one given in X-Request-ID header or a new random one; the
+//ID is returned in the header and kept in the request context, see requestID
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
 
-	s.logger.Debug("Selected service port: %v", port)
+		if id == "" {
+			id = randomID(8)
+		}
+
+		w.Header().Set(RequestIDHeader, id)
 
-	return port, nil
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
 }
 
-func (s *Service) getServiceDriver(args map[string]string) (driver DBDriver, err error) {
-	driverName := initStringParam(args, ServiceDriverEnvironmentProperty, ServiceDriverAttribute, "cas")
+//requestID returns ID of the request given by withRequestID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
 
-	s.logger.Debug("Selected db driver: %q", driverName)
+	return id
+}
 
-	switch driverName {
-	case "cas":
-		return &CasandraDriver{logger: s.logger}, nil
-	case "casp":
-		return &CasandraPartitionedDriver{logger: s.logger}, nil
-	case "light":
-		return &LightDriver{logger: s.logger}, nil
-	case "mem":
-		return &MemoryDriver{logger: s.logger}, nil
-	default:
-		return nil, fmt.Errorf("wrong driver is given. Available: cas, light, mem")
+//startSpan starts the span of the data request if the service traces requests and the request is
+//sampled; it is nil otherwise
+func (s *Service) startSpan(r *http.Request, f, id string) *Span {
+	if s.tracer == nil {
+		return nil
 	}
+
+	span := s.tracer.start("HTTP "+r.Method+" "+f, r.Header.Get(TraceParentHeader))
+	span.set("http.method", r.Method)
+	span.set("http.target", r.URL.Path)
+	span.set("request.id", id)
+	span.set("driver", s.driverName)
+
+	return span
+}
+
+func (s *Service) checkServicePort() error {
+	if s.port < 1 || s.port > 65535 {
+		return fmt.Errorf("port value should be from 1 to 65535")
+	}
+
+	s.logger.Debug("Selected service port: %v", s.port)
+
+	return nil
+}
+
+func (s *Service) getServiceDriver() (driver DBDriver, err error) {
+	driverName := s.driverName
+
+	if s.noop {
+		driverName = NopDriverName
+	}
+
+	s.logger.Debug("Selected db driver: %q", driverName)
+
+	return newDriver(driverName, s.logger)
 }
 
 func (s *Service) flushMetrics() {
 	atomic.StoreInt64(&s.EventCount, 0)
 	atomic.StoreInt64(&s.BatchCount, 0)
 	atomic.StoreInt64(&s.BatchDurationNS, 0)
-	atomic.StoreInt64(&s.PartitionsSize, 1)
 	atomic.StoreInt64(&s.HcCnt, 0)
 	atomic.StoreInt64(&s.HcDurNs, 0)
 	atomic.StoreInt64(&s.CacheViewCnt, 0)
 	atomic.StoreInt64(&s.NotCacheViewCnt, 0)
+	atomic.StoreInt64(&s.SavedBytes, 0)
+	atomic.StoreInt64(&s.SlowOpCnt, 0)
 }
 
 func (s *Service) getPutCount() int64 {
@@ -379,6 +1044,10 @@ func (s *Service) getNotCacheViewCnt() int64 {
 	return atomic.LoadInt64(&s.NotCacheViewCnt)
 }
 
+func (s *Service) getSavedBytes() int64 {
+	return atomic.LoadInt64(&s.SavedBytes)
+}
+
 func (s *Service) getBatchCount() int64 {
 	return atomic.LoadInt64(&s.BatchCount)
 }
@@ -387,10 +1056,6 @@ func (s *Service) getBatchDuration() int64 {
 	return atomic.LoadInt64(&s.BatchDurationNS)
 }
 
-func (s *Service) getPartitionsSize() int64 {
-	return atomic.LoadInt64(&s.PartitionsSize)
-}
-
 func (s *Service) getMetricHcCnt() int64 {
 	return atomic.LoadInt64(&s.HcCnt)
 }
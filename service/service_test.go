@@ -5,25 +5,193 @@
 package service
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_Multyinit(t *testing.T) {
-	{
-		go initCassandraDriver(t)
-		go initCassandraDriver(t)
+	if os.Getenv(HostsEnvironmentProperty) == "" {
+		t.Skipf("%v is not set", HostsEnvironmentProperty)
+	}
+
+	// drivers are initialized concurrently by parallel subtests, which may fail fatally
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			initCassandraDriver(t)
+		})
 	}
 }
 
 func initCassandraDriver(t *testing.T) {
 	t.Log("Initiating cassandra driver")
 
-	d := CasandraDriver{}
-	args := map[string]string{}
+	d := CasandraDriver{logger: &Logger{}}
+
+	cfg, err := LoadConfig(nil, os.LookupEnv)
+
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	err := d.Init(args)
+	err = d.Init(cfg)
 
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	d.Free()
+}
+
+//testFreeDriver counts frees; its initialization fails when fail is set
+type testFreeDriver struct {
+	LightDriver
+	fail  bool
+	freed int
+}
+
+func (d *testFreeDriver) Init(cfg *Config) error {
+	if d.fail {
+		return fmt.Errorf("init failed")
+	}
+
+	return nil
+}
+
+func (d *testFreeDriver) Free() error {
+	d.freed++
+	return nil
+}
+
+func Test_abort(t *testing.T) {
+	tests := []struct {
+		name     string
+		initFail bool
+		opt      Option
+		freed    int
+	}{
+		{"failed check of initialized driver", false, WithInitCheck(func(DBDriver) error { return fmt.Errorf("check failed") }), 1},
+		{"failed option", false, WithHealthTimeout(0), 0},
+		{"failed driver init", true, WithInitCheck(func(DBDriver) error { return nil }), 0},
+	}
+
+	for _, test := range tests {
+		d := &testFreeDriver{LightDriver: LightDriver{logger: &Logger{}}, fail: test.initFail}
+
+		s, err := New(WithDriverInstance(d), test.opt)
+		assert.Nil(t, s, test.name)
+		assert.NotNil(t, err, test.name)
+		assert.Equal(t, test.freed, d.freed, test.name)
+	}
+}
+
+//testBasicDriver supports the functions of DBDriver only
+type testBasicDriver struct {
+	DBDriver
+}
+
+func Test_OptionalDriverFuncs(t *testing.T) {
+	s, err := New(WithDriverInstance(testBasicDriver{&LightDriver{logger: &Logger{}}}))
+	assert.Nil(t, err)
+	defer s.Close()
+
+	for _, f := range []string{IncrementDefaultFunc, LookupDefaultFunc} {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/api/r/z/u/a/s/1/m/c/"+f, strings.NewReader(`{}`)))
+		assert.Equal(t, 501, rec.Code, f)
+		assert.Contains(t, rec.Body.String(), "does not support "+f)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/api/r/z/u/a/s/1/m/c/YcsbView", strings.NewReader(`{}`)))
+	assert.Equal(t, 200, rec.Code)
+}
+
+//funcPath is the path of the data function in the partition
+func funcPath(partition int64, f string) string {
+	return fmt.Sprintf("/api/r/z/u/a/s/%v/m/c/%v", partition, f)
+}
+
+//serve serves the request by the handler
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return rec
+}
+
+//testAdminToken protects administrative routes of test services
+const testAdminToken = "secret"
+
+//withTestAdmin builds administrative routes of the test service, protected by testAdminToken
+func withTestAdmin() Option {
+	return WithAdmin(AdminConfig{Host: DefaultAdminHost, Token: testAdminToken})
+}
+
+//serveAdmin serves the request with testAdminToken by the handler
+func serveAdmin(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	return rec
+}
+
+func Test_FuncNames(t *testing.T) {
+	body := `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"ClusterKey":{"value":"1"},"Values":{"field0":"a"}}]}`
+	view := `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"ClusterKey":{"value":"1"}}]}`
+
+	// embedded services keep records apart; the record is inserted by the first one only
+	tests := []struct {
+		names   FuncNames
+		insert  string
+		read    string
+		denied  string
+		records string
+	}{
+		{FuncNames{}, "YcsbAdd", "YcsbView", "", `"field0":"a"`},
+		{FuncNames{Insert: "Add", Read: "View"}, "", "View", "YcsbAdd", `"Records":[null]`},
+	}
+
+	for _, test := range tests {
+		s, err := New(WithDriver("mem"), WithFuncNames(test.names))
+		assert.Nil(t, err)
+		defer s.Close()
+
+		if test.insert != "" {
+			rec := serve(s.Handler(), "POST", funcPath(1, test.insert), body)
+			assert.Equal(t, 200, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"Status":200`)
+		}
+
+		if test.denied != "" {
+			assert.Contains(t, serve(s.Handler(), "POST", funcPath(1, test.denied), body).Body.String(), "not allowed")
+		}
+
+		assert.Contains(t, serve(s.Handler(), "POST", funcPath(1, test.read), view).Body.String(), test.records)
+	}
+}
+
+func Test_NewUnknownDriver(t *testing.T) {
+	_, err := New(WithDriver("unknown"))
+	assert.NotNil(t, err)
+}
+
+func Test_NewPort(t *testing.T) {
+	for _, port := range []int64{0, -1, 65536} {
+		_, err := New(WithDriver("mem"), WithPort(port))
+		assert.NotNil(t, err, port)
+	}
+
+	s, err := New(WithDriver("mem"), WithPort(65535))
+	assert.Nil(t, err)
+	s.Close()
 }
@@ -0,0 +1,335 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultSlowThreshold s.e.
+const DefaultSlowThreshold = 100 * time.Millisecond
+
+//DefaultSlowBufferSize s.e.
+const DefaultSlowBufferSize = 100
+
+//DefaultSlowSampleSize s.e.
+const DefaultSlowSampleSize = 256
+
+//DefaultSlowFileMaxSize s.e.
+const DefaultSlowFileMaxSize = 10
+
+//DefaultSlowFileBackups s.e.
+const DefaultSlowFileBackups = 3
+
+//SlowLogConfig configures the slow operation log
+type SlowLogConfig struct {
+	//Threshold is the default threshold of driver calls; 0 - the log is off
+	Threshold time.Duration
+	//Thresholds override Threshold per function name
+	Thresholds map[string]time.Duration
+	//Size is the number of entries kept in memory
+	Size int
+	//Sample is the max number of request body bytes kept in an entry
+	Sample int
+
+	//File, when given, gets entries as JSON lines; it is rotated to File.1, File.2... when it exceeds
+	//FileMaxBytes, FileBackups files are kept
+	File         string
+	FileMaxBytes int64
+	FileBackups  int
+}
+
+//DefaultSlowLogConfig s.e.
+func DefaultSlowLogConfig() SlowLogConfig {
+	return SlowLogConfig{
+		Threshold:    DefaultSlowThreshold,
+		Size:         DefaultSlowBufferSize,
+		Sample:       DefaultSlowSampleSize,
+		FileMaxBytes: DefaultSlowFileMaxSize << 20,
+		FileBackups:  DefaultSlowFileBackups,
+	}
+}
+
+//SlowOp is a driver call which took longer than the threshold of its function; timing is split into
+//reading and decoding of the request, the driver call and encoding of the response
+type SlowOp struct {
+	Time      time.Time
+	RequestID string
+	TraceID   string `json:",omitempty"`
+	Function  string
+	Partition int64
+	ViewType  string `json:",omitempty"`
+	Key       string `json:",omitempty"`
+	Views     int
+	Status    int64
+	Error     string `json:",omitempty"`
+	Records   int
+
+	TotalNs  int64
+	DecodeNs int64
+	DriverNs int64
+	EncodeNs int64
+
+	//Payload is the request body truncated to the sample size; PayloadBytes is its full size
+	Payload      string `json:",omitempty"`
+	PayloadBytes int
+}
+
+//slowLog keeps the latest slow operations in a ring buffer and optionally writes them to a file
+type slowLog struct {
+	cfg SlowLogConfig
+
+	mu      sync.Mutex
+	entries []*SlowOp
+	next    int
+	total   int64
+	file    *rotatingFile
+}
+
+func newSlowLog(cfg SlowLogConfig) (*slowLog, error) {
+	if cfg.Size <= 0 {
+		return nil, fmt.Errorf("slow log size %v is not positive", cfg.Size)
+	}
+
+	l := &slowLog{cfg: cfg, entries: make([]*SlowOp, 0, cfg.Size)}
+
+	if cfg.File != "" {
+		f, err := openRotatingFile(cfg.File, cfg.FileMaxBytes, cfg.FileBackups)
+
+		if err != nil {
+			return nil, err
+		}
+
+		l.file = f
+	}
+
+	return l, nil
+}
+
+//threshold returns the threshold of the function; 0 - calls of the function are not logged
+func (l *slowLog) threshold(function string) time.Duration {
+	if t, ok := l.cfg.Thresholds[function]; ok {
+		return t
+	}
+
+	return l.cfg.Threshold
+}
+
+//slow tells whether the call of the function took longer than its threshold
+func (l *slowLog) slow(function string, d time.Duration) bool {
+	t := l.threshold(function)
+
+	return t > 0 && d > t
+}
+
+//add records the operation; errors of the file are returned, the entry is kept in memory anyway
+func (l *slowLog) add(op *SlowOp) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total++
+
+	if len(l.entries) < l.cfg.Size {
+		l.entries = append(l.entries, op)
+	} else {
+		l.entries[l.next] = op
+	}
+
+	l.next = (l.next + 1) % l.cfg.Size
+
+	if l.file == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(op)
+
+	if err != nil {
+		return err
+	}
+
+	return l.file.write(append(b, '\n'))
+}
+
+//list returns at most limit entries, the latest first
+func (l *slowLog) list(limit int) (int64, []*SlowOp) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit > len(l.entries) {
+		limit = len(l.entries)
+	}
+
+	list := make([]*SlowOp, 0, limit)
+
+	for i := 1; i <= limit; i++ {
+		list = append(list, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+
+	return l.total, list
+}
+
+func (l *slowLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	return l.file.close()
+}
+
+//newSlowOp describes the call of the function with the request and its response
+func newSlowOp(function string, r *DBRequest, res *DBResponse, body []byte, sample int) *SlowOp {
+	op := &SlowOp{
+		Time:         time.Now(),
+		RequestID:    r.RequestID,
+		Function:     function,
+		Partition:    r.Partition,
+		Status:       res.Status,
+		Error:        res.Error,
+		Records:      len(res.Records),
+		PayloadBytes: len(body),
+	}
+
+	views := make([]*ViewView, 0, len(r.ViewViews)+len(r.ViewMods))
+
+	for i := range r.ViewViews {
+		views = append(views, &r.ViewViews[i])
+	}
+
+	for i := range r.ViewMods {
+		views = append(views, &r.ViewMods[i].ViewView)
+	}
+
+	op.Views = len(views)
+
+	if len(views) > 0 {
+		op.ViewType = views[0].ViewType
+		op.Key, _ = buildKey(views[0].PartitionKey, views[0].ClusterKey)
+	}
+
+	if len(body) > sample {
+		body = body[:sample]
+	}
+
+	op.Payload = strings.ToValidUTF8(string(body), "")
+
+	return op
+}
+
+//parseSlowThresholds parses per function thresholds given as function=duration pairs
+func parseSlowThresholds(list []string) (map[string]time.Duration, error) {
+	thresholds := map[string]time.Duration{}
+
+	for _, item := range list {
+		i := strings.Index(item, "=")
+
+		if i <= 0 {
+			return nil, fmt.Errorf("slow threshold %q is not function=duration", item)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(item[i+1:]))
+
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("slow threshold %q: duration is malformed", item)
+		}
+
+		thresholds[strings.TrimSpace(item[:i])] = d
+	}
+
+	return thresholds, nil
+}
+
+//rotatingFile appends to a file; when the file would exceed maxBytes it is renamed to path.1, former
+//path.1 to path.2 and so on, up to backups files
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	backups  int
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxBytes int64, backups int) (*rotatingFile, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("max size of file %v is not positive", path)
+	}
+
+	r := &rotatingFile{path: path, maxBytes: maxBytes, backups: backups}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.f, r.size = f, info.Size()
+
+	return nil
+}
+
+func (r *rotatingFile) write(b []byte) error {
+	if r.size > 0 && r.size+int64(len(b)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+
+	return err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+
+	if r.backups > 0 {
+		for i := r.backups - 1; i > 0; i-- {
+			from := fmt.Sprintf("%v.%v", r.path, i)
+
+			if _, err := os.Stat(from); err == nil {
+				if err := os.Rename(from, fmt.Sprintf("%v.%v", r.path, i+1)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *rotatingFile) close() error {
+	return r.f.Close()
+}
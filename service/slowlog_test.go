@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_slowLog(t *testing.T) {
+	{
+		l, err := newSlowLog(SlowLogConfig{Threshold: time.Second, Thresholds: map[string]time.Duration{"YcsbScan": 0, "YcsbView": time.Millisecond}, Size: 2})
+		assert.Nil(t, err)
+
+		assert.True(t, l.slow("YcsbAdd", 2*time.Second))
+		assert.False(t, l.slow("YcsbAdd", time.Second))
+		assert.False(t, l.slow("YcsbScan", time.Hour))
+		assert.True(t, l.slow("YcsbView", 2*time.Millisecond))
+
+		for _, f := range []string{"a", "b", "c"} {
+			assert.Nil(t, l.add(&SlowOp{Function: f}))
+		}
+
+		total, list := l.list(5)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, 2, len(list))
+		assert.Equal(t, "c", list[0].Function)
+		assert.Equal(t, "b", list[1].Function)
+
+		_, list = l.list(1)
+		assert.Equal(t, "c", list[0].Function)
+	}
+
+	{
+		_, err := newSlowLog(SlowLogConfig{})
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_newSlowOp(t *testing.T) {
+	r := &DBRequest{Partition: 5, ViewMods: []ViewMod{{ViewView: ViewView{ViewType: "usertable", PartitionKey: map[string]interface{}{"value": "user1"}}}}}
+	res := &DBResponse{Status: 400, Error: "failed"}
+
+	op := newSlowOp("YcsbAdd", r, res, []byte(`{"ViewMods":[]}`), 5)
+
+	assert.Equal(t, int64(5), op.Partition)
+	assert.Equal(t, "usertable", op.ViewType)
+	assert.Equal(t, "user1", op.Key)
+	assert.Equal(t, 1, op.Views)
+	assert.Equal(t, `{"Vie`, op.Payload)
+	assert.Equal(t, 15, op.PayloadBytes)
+	assert.Equal(t, "failed", op.Error)
+}
+
+func Test_parseSlowThresholds(t *testing.T) {
+	th, err := parseSlowThresholds([]string{"YcsbScan=500ms", "YcsbView = 0"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]time.Duration{"YcsbScan": 500 * time.Millisecond, "YcsbView": 0}, th)
+
+	_, err = parseSlowThresholds([]string{"YcsbScan"})
+	assert.NotNil(t, err)
+
+	_, err = parseSlowThresholds([]string{"YcsbScan=x"})
+	assert.NotNil(t, err)
+}
+
+func Test_rotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slowlog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "slow.log")
+	f, err := openRotatingFile(path, 10, 2)
+	assert.Nil(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		assert.Nil(t, f.write([]byte(line)))
+	}
+
+	assert.Nil(t, f.close())
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// size of the existing file is taken into account
+	f, err = openRotatingFile(path, 10, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, f.write([]byte("fifth\n")))
+	assert.Nil(t, f.close())
+	assert.True(t, strings.HasPrefix(read(path+".1"), "fourth"))
+}
+
+func Test_SlowHandler(t *testing.T) {
+	body := `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Values":{"field0":"a"}}]}`
+	view := `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"}}]}`
+
+	tests := []struct {
+		cfg     SlowLogConfig
+		resp    []string
+		metrics string
+	}{
+		{
+			SlowLogConfig{Threshold: time.Nanosecond, Thresholds: map[string]time.Duration{"YcsbView": 0}, Size: 10, Sample: 13},
+			[]string{`"Total":1`, `"Function":"YcsbAdd","Partition":7,"ViewType":"usertable","Key":"user1"`, `"Payload":"{\"ViewMods\":[","PayloadBytes":96`},
+			`"slowOpCnt":1`,
+		},
+		{SlowLogConfig{Threshold: time.Hour, Size: 10}, []string{`"Total":0`}, `"slowOpCnt":0`},
+	}
+
+	for _, test := range tests {
+		s, err := New(WithDriver("mem"), WithSlowLog(test.cfg), withTestAdmin())
+		assert.Nil(t, err)
+		defer s.Close()
+
+		serve(s.Handler(), "POST", funcPath(7, "YcsbAdd"), body)
+		serve(s.Handler(), "POST", funcPath(7, "YcsbView"), view)
+
+		rec := serveAdmin(s.AdminHandler(), "GET", "/api/slow?limit=5", "")
+		assert.Equal(t, 200, rec.Code)
+
+		for _, resp := range test.resp {
+			assert.Contains(t, rec.Body.String(), resp)
+		}
+
+		assert.Contains(t, serve(s.Handler(), "GET", funcPath(1, "YcsbMetric"), "").Body.String(), test.metrics)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+//LargestPartitionsCount is the number of the largest partitions reported in metrics
+const LargestPartitionsCount = 10
+
+//ErrStatsNotTracked is returned by drivers which are configured not to track partition statistics
+var ErrStatsNotTracked = errors.New("partition statistics are not tracked; see " + PartitionStatsAttribute)
+
+//PartitionStatsProvider is implemented by drivers which track partition statistics
+type PartitionStatsProvider interface {
+	//PartitionStats returns statistics of the partition; empty for unknown partitions
+	PartitionStats(partition int64) (*PartitionStats, error)
+	//PartitionsStats returns statistics of all the partitions holding records
+	PartitionsStats() ([]*PartitionStats, error)
+}
+
+//PartitionStats holds number and size of records of a partition; size of a record is the JSON size
+//of its values
+type PartitionStats struct {
+	Partition int64
+	Records   int64
+	Bytes     int64
+	Views     map[string]*ViewStats `json:",omitempty"`
+}
+
+//ViewStats s.e.
+type ViewStats struct {
+	Records int64
+	Bytes   int64
+}
+
+//add counts records and bytes of the view, negative for removed ones
+func (s *PartitionStats) add(view string, records, bytes int64) {
+	if s.Views == nil {
+		s.Views = map[string]*ViewStats{}
+	}
+
+	v, ok := s.Views[view]
+
+	if !ok {
+		v = &ViewStats{}
+		s.Views[view] = v
+	}
+
+	v.Records += records
+	v.Bytes += bytes
+	s.Records += records
+	s.Bytes += bytes
+
+	if v.Records <= 0 && v.Bytes <= 0 {
+		delete(s.Views, view)
+	}
+}
+
+func (s *PartitionStats) copy() *PartitionStats {
+	c := &PartitionStats{Partition: s.Partition, Records: s.Records, Bytes: s.Bytes, Views: make(map[string]*ViewStats, len(s.Views))}
+
+	for k, v := range s.Views {
+		c.Views[k] = &ViewStats{Records: v.Records, Bytes: v.Bytes}
+	}
+
+	return c
+}
+
+//recordSize is the JSON size of record values
+func recordSize(values map[string]interface{}) int64 {
+	b, _ := json.Marshal(values)
+	return int64(len(b))
+}
+
+//statsDelta is the change of record count and size by a write of values over the record of the given
+//size; nil values - the record is deleted
+func statsDelta(existed bool, size int64, values map[string]interface{}) (int64, int64) {
+	var records, bytes int64
+
+	if existed {
+		records, bytes = -1, -size
+	}
+
+	if values != nil {
+		records++
+		bytes += recordSize(values)
+	}
+
+	return records, bytes
+}
+
+//largestPartitions returns at most n partitions ordered by size, then by number of records
+func largestPartitions(stats []*PartitionStats, n int) []*PartitionStats {
+	sorted := append([]*PartitionStats{}, stats...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+
+		if sorted[i].Records != sorted[j].Records {
+			return sorted[i].Records > sorted[j].Records
+		}
+
+		return sorted[i].Partition < sorted[j].Partition
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PartitionStats(t *testing.T) {
+	{
+		records, bytes := statsDelta(false, 0, map[string]interface{}{"a": 1})
+		assert.Equal(t, int64(1), records)
+		assert.Equal(t, int64(7), bytes)
+
+		records, bytes = statsDelta(true, 7, map[string]interface{}{"a": 10})
+		assert.Equal(t, int64(0), records)
+		assert.Equal(t, int64(1), bytes)
+
+		records, bytes = statsDelta(true, 8, nil)
+		assert.Equal(t, int64(-1), records)
+		assert.Equal(t, int64(-8), bytes)
+	}
+
+	{
+		s := &PartitionStats{Partition: 1}
+		s.add("a", 2, 20)
+		s.add("b", 1, 5)
+		s.add("a", -2, -20)
+
+		assert.Equal(t, int64(1), s.Records)
+		assert.Equal(t, int64(5), s.Bytes)
+		assert.Equal(t, map[string]*ViewStats{"b": {Records: 1, Bytes: 5}}, s.Views)
+	}
+
+	{
+		stats := []*PartitionStats{{Partition: 1, Bytes: 10}, {Partition: 2, Bytes: 30}, {Partition: 3, Bytes: 10, Records: 2}}
+		largest := largestPartitions(stats, 2)
+
+		assert.Equal(t, 2, len(largest))
+		assert.Equal(t, int64(2), largest[0].Partition)
+		assert.Equal(t, int64(3), largest[1].Partition)
+		assert.Equal(t, int64(1), stats[0].Partition)
+	}
+}
+
+func Test_PartitionsHandler(t *testing.T) {
+	body := `{"ViewMods":[{"ViewType":"usertable","PartitionKey":{"value":"user1"},"Values":{"field0":"a"}}]}`
+
+	// metrics of both ports report partition totals read from the driver
+	tests := []struct {
+		driver  string
+		path    string
+		code    int
+		resp    string
+		metrics string
+	}{
+		{"mem", "/api/partitions/7", 200, `"Partition":7,"Records":1,"Bytes":14`, `"partitionsCount":1,"partitionsRecords":1,"partitionsSize":14`},
+		{"mem", "/api/partitions?limit=x", 400, "", `"largestPartitions":[{"Partition":7,"Records":1,"Bytes":14}]`},
+		{"mem", "/api/metrics", 200, `"largestPartitions":[{"Partition":7,"Records":1,"Bytes":14}]`, `"partitionsSize":14`},
+		{"light", "/api/partitions", 501, "", `"partitionsSize":0`},
+	}
+
+	for _, test := range tests {
+		s, err := New(WithDriver(test.driver), withTestAdmin())
+		assert.Nil(t, err)
+		defer s.Close()
+
+		serve(s.Handler(), "POST", funcPath(7, "YcsbAdd"), body)
+
+		rec := serveAdmin(s.AdminHandler(), "GET", test.path, "")
+		assert.Equal(t, test.code, rec.Code, test.path)
+		assert.Contains(t, rec.Body.String(), test.resp, test.path)
+
+		assert.Contains(t, serve(s.Handler(), "GET", funcPath(1, "YcsbMetric"), "").Body.String(), test.metrics, test.path)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Trace exporters
+const (
+	TraceExporterNone = "none"
+	TraceExporterFile = "file"
+	TraceExporterOTLP = "otlp"
+)
+
+//DefaultTraceFile s.e.
+const DefaultTraceFile = "traces.json"
+
+//DefaultTraceEndpoint is the default OTLP/HTTP traces endpoint of a local collector
+const DefaultTraceEndpoint = "http://localhost:4318/v1/traces"
+
+//TraceParentHeader is the W3C trace context header
+const TraceParentHeader = "traceparent"
+
+const (
+	traceQueueSize   = 4096
+	traceBatchSize   = 512
+	traceBatchPeriod = time.Second
+)
+
+//TraceExporter sends finished spans to a tracing backend
+type TraceExporter interface {
+	Export(spans []*Span) error
+	Close() error
+}
+
+//Span is a timed operation of a trace; IDs are hex encoded as in traceparent
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string `json:",omitempty"`
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{} `json:",omitempty"`
+	Error      string                 `json:",omitempty"`
+
+	tracer *Tracer
+	mu     sync.Mutex
+}
+
+//Tracer starts spans of sampled requests and exports finished spans in batches in background
+type Tracer struct {
+	exporter TraceExporter
+	sample   int
+	logger   *Logger
+
+	queue   chan *Span
+	done    chan struct{}
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+//NewTracer creates a tracer which samples the given percent of requests without sampled parent
+func NewTracer(exporter TraceExporter, samplePercent int, logger *Logger) *Tracer {
+	t := &Tracer{exporter: exporter, sample: samplePercent, logger: logger, queue: make(chan *Span, traceQueueSize), done: make(chan struct{})}
+
+	t.wg.Add(1)
+	go t.run()
+
+	return t
+}
+
+//start starts a root span of the request; traceparent, when valid, gives the trace, the parent span and
+//the sampling decision. nil span - the request is not sampled.
+func (t *Tracer) start(name, traceparent string) *Span {
+	traceID, parentID, sampled, ok := parseTraceParent(traceparent)
+
+	if !ok {
+		traceID, parentID, sampled = randomID(16), "", t.sampled()
+	}
+
+	if !sampled {
+		return nil
+	}
+
+	return &Span{TraceID: traceID, SpanID: randomID(8), ParentID: parentID, Name: name, Start: time.Now(), tracer: t}
+}
+
+func (t *Tracer) sampled() bool {
+	if t.sample >= 100 {
+		return true
+	}
+
+	if t.sample <= 0 {
+		return false
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(100))
+
+	return err == nil && n.Int64() < int64(t.sample)
+}
+
+//record queues the finished span; spans are dropped when the exporter does not keep up
+func (t *Tracer) record(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(traceBatchPeriod)
+	defer ticker.Stop()
+
+	var batch []*Span
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := t.exporter.Export(batch); err != nil {
+			t.logger.Error("trace export of %v spans: %v", len(batch), err)
+		}
+
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			if batch = append(batch, s); len(batch) >= traceBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+//Dropped is the number of spans dropped as the queue was full
+func (t *Tracer) Dropped() int64 {
+	return atomic.LoadInt64(&t.dropped)
+}
+
+//Close exports queued spans and closes the exporter
+func (t *Tracer) Close() error {
+	close(t.done)
+	t.wg.Wait()
+
+	return t.exporter.Close()
+}
+
+//child starts a span of the operation within the span
+func (s *Span) child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+
+	return &Span{TraceID: s.TraceID, SpanID: randomID(8), ParentID: s.SpanID, Name: name, Start: time.Now(), tracer: s.tracer}
+}
+
+//set sets an attribute of the span
+func (s *Span) set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = map[string]interface{}{}
+	}
+
+	s.Attributes[key] = value
+}
+
+func (s *Span) fail(err string) {
+	if s == nil || err == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Error = err
+}
+
+//finish ends the span and records it
+func (s *Span) finish() {
+	s.finishAt(time.Now())
+}
+
+//finishAt ends the span at the given time and records it
+func (s *Span) finishAt(end time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.End = end
+	s.tracer.record(s)
+}
+
+type spanContextKey struct{}
+
+func contextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, s)
+}
+
+//spanFromContext returns the span of the context; nil when the context is not traced
+func spanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+
+	return s
+}
+
+//parseTraceParent parses W3C traceparent header: version-traceid-parentid-flags
+func parseTraceParent(h string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return "", "", false, false
+	}
+
+	if !validTraceID(parts[1], 32) || !validTraceID(parts[2], 16) || len(parts[3]) != 2 {
+		return "", "", false, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+
+	if err != nil {
+		return "", "", false, false
+	}
+
+	return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+//validTraceID tells whether the id is lowercase hex of the length and not all zeros
+func validTraceID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func randomID(bytes int) string {
+	b := make([]byte, bytes)
+
+	if _, err := rand.Read(b); err != nil {
+		// time based ids are unique enough for a trace
+		return fmt.Sprintf("%0*x", bytes*2, time.Now().UnixNano())[:bytes*2]
+	}
+
+	return hex.EncodeToString(b)
+}
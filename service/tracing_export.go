@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const traceServiceName = "reference-crud-app"
+
+//fileTraceExporter appends spans to a file, one JSON object per line
+type fileTraceExporter struct {
+	f *os.File
+}
+
+//NewFileTraceExporter s.e.
+func NewFileTraceExporter(path string) (TraceExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileTraceExporter{f: f}, nil
+}
+
+func (e *fileTraceExporter) Export(spans []*Span) error {
+	w := bufio.NewWriter(e.f)
+	enc := json.NewEncoder(w)
+
+	for _, s := range spans {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func (e *fileTraceExporter) Close() error {
+	return e.f.Close()
+}
+
+//otlpTraceExporter posts spans to an OTLP/HTTP endpoint in JSON encoding
+type otlpTraceExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+//NewOTLPTraceExporter s.e.
+func NewOTLPTraceExporter(endpoint string) TraceExporter {
+	return &otlpTraceExporter{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *otlpTraceExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(spans))
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v responded %v: %s", e.endpoint, resp.Status, msg)
+	}
+
+	return nil
+}
+
+func (e *otlpTraceExporter) Close() error {
+	return nil
+}
+
+//otlpRequest builds ExportTraceServiceRequest of OTLP JSON encoding
+func otlpRequest(spans []*Span) map[string]interface{} {
+	list := make([]interface{}, len(spans))
+
+	for i, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.TraceID,
+			"spanId":            s.SpanID,
+			"name":              s.Name,
+			"kind":              otlpSpanKind(s),
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+		}
+
+		if s.ParentID != "" {
+			span["parentSpanId"] = s.ParentID
+		}
+
+		if s.Error != "" {
+			// STATUS_CODE_ERROR
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Error}
+		}
+
+		list[i] = span
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource":   map[string]interface{}{"attributes": otlpAttributes(map[string]interface{}{"service.name": traceServiceName, "service.version": ServiceVersion})},
+				"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]interface{}{"name": traceServiceName}, "spans": list}},
+			},
+		},
+	}
+}
+
+//otlpSpanKind is SPAN_KIND_SERVER for request spans, SPAN_KIND_CLIENT for queries and SPAN_KIND_INTERNAL
+//for others
+func otlpSpanKind(s *Span) int {
+	switch {
+	case s.Attributes["http.method"] != nil:
+		return 2
+	case s.Attributes["db.system"] != nil:
+		return 3
+	}
+
+	return 1
+}
+
+func otlpAttributes(attrs map[string]interface{}) []interface{} {
+	list := make([]interface{}, 0, len(attrs))
+
+	for k, v := range attrs {
+		var value map[string]interface{}
+
+		switch t := v.(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": t}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(t)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(t, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": t}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(t)}
+		}
+
+		list = append(list, map[string]interface{}{"key": k, "value": value})
+	}
+
+	return list
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTraceExporter struct {
+	mu     sync.Mutex
+	spans  []*Span
+	closed bool
+}
+
+func (e *testTraceExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+
+	return nil
+}
+
+func (e *testTraceExporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+func (e *testTraceExporter) byName() map[string]*Span {
+	spans := map[string]*Span{}
+
+	for _, s := range e.spans {
+		spans[s.Name] = s
+	}
+
+	return spans
+}
+
+func Test_parseTraceParent(t *testing.T) {
+	traceID, parentID, sampled, ok := parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.True(t, sampled)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "00f067aa0ba902b7", parentID)
+
+	_, _, sampled, ok = parseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.True(t, ok)
+	assert.False(t, sampled)
+
+	// future versions may add fields
+	_, _, _, ok = parseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.True(t, ok)
+
+	for _, h := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1",
+	} {
+		_, _, _, ok = parseTraceParent(h)
+		assert.False(t, ok, h)
+	}
+}
+
+func Test_Tracer(t *testing.T) {
+	e := &testTraceExporter{}
+	tracer := NewTracer(e, 0, &Logger{})
+
+	{
+		assert.Nil(t, tracer.start("not sampled", ""))
+		assert.Nil(t, tracer.start("not sampled parent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"))
+
+		root := tracer.start("root", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		child := root.child("child")
+		child.set("db.partition", int64(1))
+		child.fail("failed")
+		child.finish()
+
+		ctx := contextWithSpan(context.Background(), child)
+		start := time.Now()
+		casandraQueryObserver{}.ObserveQuery(ctx, gocql.ObservedQuery{Statement: "select * from t", Start: start, End: start.Add(time.Millisecond), Err: errors.New("timeout")})
+		casandraQueryObserver{}.ObserveQuery(context.Background(), gocql.ObservedQuery{Statement: "not traced"})
+
+		root.finish()
+	}
+
+	assert.Nil(t, tracer.Close())
+	assert.True(t, e.closed)
+
+	spans := e.byName()
+	assert.Equal(t, 3, len(e.spans))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans["root"].TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", spans["root"].ParentID)
+	assert.Equal(t, spans["root"].SpanID, spans["child"].ParentID)
+	assert.Equal(t, "failed", spans["child"].Error)
+	assert.Equal(t, spans["child"].SpanID, spans["cassandra SELECT"].ParentID)
+	assert.Equal(t, time.Millisecond, spans["cassandra SELECT"].End.Sub(spans["cassandra SELECT"].Start))
+	assert.Equal(t, "timeout", spans["cassandra SELECT"].Error)
+}
+
+func Test_OTLPTraceExporter(t *testing.T) {
+	var body map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer srv.Close()
+
+	start := time.Unix(1, 0)
+	span := &Span{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Name: "HTTP POST YcsbView", Start: start, End: start.Add(time.Second), Attributes: map[string]interface{}{"http.method": "POST"}, Error: "failed"}
+
+	assert.Nil(t, NewOTLPTraceExporter(srv.URL).Export([]*Span{span}))
+
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	s := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+
+	assert.Equal(t, "00f067aa0ba902b7", s["spanId"])
+	assert.Equal(t, "1000000000", s["startTimeUnixNano"])
+	assert.Equal(t, float64(2), s["kind"])
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "failed"}, s["status"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "http.method", "value": map[string]interface{}{"stringValue": "POST"}}}, s["attributes"])
+
+	assert.NotNil(t, NewOTLPTraceExporter(srv.URL+"/missing\x00").Export([]*Span{span}))
+}
+
+func Test_TracingHandler(t *testing.T) {
+	body := `{"ViewViews":[{"ViewType":"usertable","PartitionKey":{"value":"user1"}}]}`
+
+	tests := []struct {
+		sample      int
+		traceParent string
+		spans       int
+		traceID     string
+	}{
+		{0, "", 0, ""},
+		{0, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", 3, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{100, "", 3, ""},
+	}
+
+	for _, test := range tests {
+		e := &testTraceExporter{}
+
+		s, err := New(WithDriver("mem"), WithTracing(e, test.sample))
+		assert.Nil(t, err)
+
+		req := httptest.NewRequest("POST", funcPath(7, "YcsbView"), strings.NewReader(body))
+
+		if test.traceParent != "" {
+			req.Header.Set(TraceParentHeader, test.traceParent)
+		}
+
+		s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Nil(t, s.Close())
+		assert.Equal(t, test.spans, len(e.spans))
+
+		if test.spans == 0 {
+			continue
+		}
+
+		spans := e.byName()
+		assert.Equal(t, spans["HTTP POST YcsbView"].SpanID, spans["buildRequest"].ParentID)
+		assert.Equal(t, spans["HTTP POST YcsbView"].SpanID, spans["driver YcsbView"].ParentID)
+		assert.Equal(t, int64(7), spans["driver YcsbView"].Attributes["db.partition"])
+
+		if test.traceID != "" {
+			assert.Equal(t, test.traceID, spans["HTTP POST YcsbView"].TraceID)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2019-present Heeus authors
+ */
+
+package service
+
+import (
+	"fmt"
+	"os"
+)
+
+//MaxTTL is the largest TTL cassandra accepts: 20 years in seconds
+const MaxTTL = 630720000
+
+//viewTTLs holds default TTLs (seconds) of the views declared in the scheme
+type viewTTLs map[string]int64
+
+//loadOptionalScheme reads the scheme of drivers which keep records of any views; default scheme
+//file may be absent, views have no TTLs and indexes then
+func loadOptionalScheme(cfg *Config) (*Scheme, error) {
+	path := cfg.String(SchemeParam)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) && cfg.Source(SchemeParam) == SourceDefault {
+		return &Scheme{}, nil
+	}
+
+	return LoadScheme(path)
+}
+
+func schemeTTLs(s *Scheme) viewTTLs {
+	ttls := viewTTLs{}
+
+	for _, v := range s.Views {
+		if v.TTL > 0 {
+			ttls[v.Name] = v.TTL
+		}
+	}
+
+	return ttls
+}
+
+//ttl returns TTL of an insert: the given one or the view default; 0 means the record does not expire.
+//Every insert sets expiry of the written record, as cassandra does.
+func (t viewTTLs) ttl(view *ViewMod) (int64, error) {
+	if err := checkTTL(view.TTL); err != nil {
+		return 0, err
+	}
+
+	if view.TTL > 0 {
+		return view.TTL, nil
+	}
+
+	return t[view.ViewType], nil
+}
+
+//modifyTTL returns TTL of an update or increment: the given one, otherwise the remaining TTL of the
+//existing record, so that a patch does not make an expiring record persistent, or the view default of
+//a created one
+func (t viewTTLs) modifyTTL(view *ViewMod, exists bool, remaining int64) (int64, error) {
+	if err := checkTTL(view.TTL); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case view.TTL > 0:
+		return view.TTL, nil
+	case exists:
+		return remaining, nil
+	}
+
+	return t[view.ViewType], nil
+}
+
+func checkTTL(ttl int64) error {
+	if ttl < 0 || ttl > MaxTTL {
+		return fmt.Errorf("TTL %v malformed: 0..%v seconds expected", ttl, MaxTTL)
+	}
+
+	return nil
+}
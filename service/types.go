@@ -5,12 +5,14 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 )
 
 //DBDriver s.e.
 type DBDriver interface {
-	Init(args map[string]string) error
+	Init(cfg *Config) error
 	Free() error
 	Clean(r *DBRequest) *DBResponse
 	Read(r *DBRequest) *DBResponse
@@ -18,21 +20,79 @@ type DBDriver interface {
 	Update(r *DBRequest) *DBResponse
 	Scan(r *DBRequest) *DBResponse
 	Delete(r *DBRequest) *DBResponse
+	//Ping checks that the storage serves requests; ctx limits the check
+	Ping(ctx context.Context) error
 	Name() string
 	Info() string
 }
 
+//SchemaMigrator is implemented by drivers which keep a versioned schema; see Migrate
+type SchemaMigrator interface {
+	Migrate(cfg *Config, command string, dryRun bool, w io.Writer) error
+}
+
+//Incrementer is implemented by drivers which support increments; the service responds 501 to increments
+//otherwise
+type Incrementer interface {
+	//Increment adds ViewMod values (deltas) to numeric fields atomically; response records hold new values
+	Increment(r *DBRequest) *DBResponse
+}
+
+//Lookuper is implemented by drivers which support secondary indexes; the service responds 501 to lookups
+//otherwise
+type Lookuper interface {
+	//Lookup returns records of the partition by value of an indexed field; see ViewView.Index
+	Lookup(r *DBRequest) *DBResponse
+}
+
+//HostsReporter is implemented by drivers of clusters; /healthz and /readyz report its hosts
+type HostsReporter interface {
+	Hosts() []HostStatus
+}
+
 //ViewView  s.e.
 type ViewView struct {
 	ViewType     string
 	PartitionKey map[string]interface{}
 	ClusterKey   map[string]interface{}
+
+	//Fields are returned by Read and Scan (projection); all fields when empty
+	Fields []string `json:",omitempty"`
+
+	//Limit and PageState are used by Scan, ClusterKey is the (inclusive) start of a scan then
+	Limit     int
+	PageState string `json:",omitempty"`
+
+	//Index is an indexed field and IndexValue its value looked up by Lookup
+	Index      string      `json:",omitempty"`
+	IndexValue interface{} `json:",omitempty"`
+
+	//Filter selects records returned by Scan; see Condition
+	Filter *Condition `json:",omitempty"`
+
+	//Aggregates make Scan return aggregated values of all records from the start instead of a page:
+	//a record per value of GroupBy field, if given
+	Aggregates []Aggregate `json:",omitempty"`
+	GroupBy    string      `json:",omitempty"`
 }
 
 //ViewMod s.e.
 type ViewMod struct {
 	ViewView
-	Values map[string]interface{}
+
+	//Values are merged into the record by Update as JSON merge patch (RFC 7396): null removes a field,
+	//objects merge recursively; with Replace they replace record values instead
+	Values  map[string]interface{}
+	Replace bool `json:",omitempty"`
+
+	//TTL is time to live of the written record in seconds; 0 - view default from the scheme, if any
+	TTL int64 `json:",omitempty"`
+
+	//Ops are collection operations applied by Update after Values
+	Ops []CollectionOp `json:",omitempty"`
+
+	//If makes Update conditional on current field values; see Condition
+	If *Condition `json:",omitempty"`
 }
 
 //DBRequest s.e.
@@ -40,6 +100,28 @@ type DBRequest struct {
 	Partition int64
 	ViewViews []ViewView
 	ViewMods  []ViewMod
+
+	//RequestID identifies the request in logs; taken from the X-Request-ID header
+	RequestID string `json:"-"`
+
+	ctx context.Context
+}
+
+//Context returns the context of the request; drivers trace their queries within the span of the context
+func (r *DBRequest) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
+//WithContext returns a shallow copy of the request with the context
+func (r *DBRequest) WithContext(ctx context.Context) *DBRequest {
+	c := *r
+	c.ctx = ctx
+
+	return &c
 }
 
 //DBResponse s.e.
@@ -47,6 +129,12 @@ type DBResponse struct {
 	Status  int64
 	Error   string
 	Records []*Record
+
+	//PageState is given to the next Scan to continue; empty when scan is complete
+	PageState string `json:",omitempty"`
+
+	//SavedBytes is the size of values omitted by projections; reported in metrics, not sent
+	SavedBytes int64 `json:"-"`
 }
 
 //Record s.e.
@@ -54,6 +142,9 @@ type Record struct {
 	Key     string
 	Values  map[string]interface{}
 	Version int
+
+	//TTL is remaining time to live in seconds; 0 - record does not expire
+	TTL int64 `json:",omitempty"`
 }
 
 func (r *DBResponse) stringify() []byte {
@@ -24,3 +24,34 @@ func Test_mapArgs(t *testing.T) {
 		assert.Equal(t, args["-doit"], "no")
 	}
 }
+
+func Test_redactEnv(t *testing.T) {
+	{
+		secrets := map[string]bool{"DB_CREDS": true}
+
+		assert.Equal(t, "DB_PASSWORD="+redacted, redactEnv("DB_PASSWORD=secret", secrets))
+		assert.Equal(t, "DB_CREDS="+redacted, redactEnv("DB_CREDS=secret", secrets))
+		assert.Equal(t, "API_TOKEN="+redacted, redactEnv("API_TOKEN=a=b", secrets))
+		assert.Equal(t, "DB_PASSWORD=", redactEnv("DB_PASSWORD=", secrets))
+		assert.Equal(t, "DB_USER=cassandra", redactEnv("DB_USER=cassandra", secrets))
+		assert.Equal(t, "STRIPE_API_KEY="+redacted, redactEnv("STRIPE_API_KEY=sk", secrets))
+		assert.Equal(t, "DB_URL=postgres://app:"+redacted+"@db:5432/app?x=a@b", redactEnv("DB_URL=postgres://app:p@ss@db:5432/app?x=a@b", secrets))
+		assert.Equal(t, "HOME_URL=https://user@example.com/", redactEnv("HOME_URL=https://user@example.com/", secrets))
+	}
+}
+
+func Test_buildKey(t *testing.T) {
+	{
+		key, err := buildKey(map[string]interface{}{"id": "u1"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "u1", key)
+	}
+
+	{
+		for i := 0; i < 10; i++ {
+			key, err := buildKey(map[string]interface{}{"b": "2", "a": "1"}, map[string]interface{}{"d": 4, "c": 3.5})
+			assert.Nil(t, err)
+			assert.Equal(t, "123.54", key)
+		}
+	}
+}